@@ -0,0 +1,43 @@
+// Command consistency-convert converts transactional logs between the JSON
+// format of consistency.JsonFileLogger and the binary format of
+// consistency.BinaryFileLogger.
+//
+// Usage:
+//
+//	consistency-convert -to binary -in events.json -out events.bin
+//	consistency-convert -to json -in events.bin -out events.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/andygeiss/cloud-native-utils/consistency"
+)
+
+func main() {
+	in := flag.String("in", "", "path of the source log file")
+	out := flag.String("out", "", "path of the destination log file")
+	to := flag.String("to", "binary", "destination format (binary or json)")
+	flag.Parse()
+
+	if *in == "" || *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch *to {
+	case "binary":
+		err = consistency.ConvertJsonToBinary(*in, *out)
+	case "json":
+		err = consistency.ConvertBinaryToJson(*in, *out)
+	default:
+		err = fmt.Errorf("unknown format %q", *to)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
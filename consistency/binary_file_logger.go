@@ -0,0 +1,220 @@
+package consistency
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+var (
+	ErrChecksumMismatch = errors.New("record checksum mismatch")
+	ErrCorruptRecord    = errors.New("corrupt record")
+	ErrRecordTooLarge   = errors.New("record too large")
+)
+
+const (
+	// binaryHeaderSize is the size of the length and checksum prefix of a record.
+	binaryHeaderSize = 8
+	// binaryMaxRecordSize limits the payload size to detect corrupted length prefixes.
+	binaryMaxRecordSize = 64 << 20 // 64 MiB
+)
+
+// castagnoli is the CRC32C table used to checksum records.
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// BinaryFileLogger is a file-based implementation of the Logger interface.
+// It writes length-prefixed records with a CRC32C checksum per record,
+// so that a torn write at the end of the file is detected and truncated on recovery.
+//
+// Each record consists of a 4-byte little-endian payload length, a 4-byte
+// little-endian CRC32C checksum of the payload and the JSON-encoded event.
+type BinaryFileLogger[K, V any] struct {
+	*fileLogger[K, V]
+}
+
+// NewBinaryFileLogger initializes a new BinaryFileLogger for the given file path.
+func NewBinaryFileLogger[K, V any](file string) *BinaryFileLogger[K, V] {
+	return NewBinaryFileLoggerWithOptions[K, V](file, FileLoggerOptions{})
+}
+
+// NewBinaryFileLoggerWithOptions initializes a new BinaryFileLogger for the given file path
+// and flushes written events to disk according to the given options.
+func NewBinaryFileLoggerWithOptions[K, V any](file string, options FileLoggerOptions) *BinaryFileLogger[K, V] {
+	return &BinaryFileLogger[K, V]{
+		fileLogger: newFileLogger[K, V](file, binaryFormat[K, V]{upcasters: options.Upcasters}, options),
+	}
+}
+
+// binaryFormat stores events as length-prefixed and checksummed records.
+type binaryFormat[K, V any] struct {
+	upcasters Upcasters // Upcasters applied on read.
+}
+
+// newEncoder returns a function writing a single record to w.
+func (binaryFormat[K, V]) newEncoder(w io.Writer) func(event Event[K, V]) error {
+	return func(event Event[K, V]) error {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		return writeBinaryRecord(w, payload)
+	}
+}
+
+// newDecoder returns a function reading a single record from r.
+func (f binaryFormat[K, V]) newDecoder(r io.Reader) func(event *Event[K, V]) error {
+	return func(event *Event[K, V]) error {
+		payload, err := readBinaryRecord(r)
+		if err != nil {
+			return err
+		}
+		decoded, err := unmarshalEvent[K, V](payload, f.upcasters)
+		*event = decoded
+		return err
+	}
+}
+
+// recover reads the log file to determine the last sequence number.
+// An incomplete or corrupted record at the end of the file is the result of
+// a torn write and is truncated, so that new records can be appended safely.
+// Corrupted records followed by valid records are reported as an error.
+func (binaryFormat[K, V]) recover(file string) (uint64, error) {
+	// Open the file for reading and truncating.
+	f, err := os.OpenFile(file, os.O_RDWR, 0600) //nolint:gosec // file path is controlled by caller
+	if err != nil {
+		// If the file doesn't exist, it's fine; this means no previous events.
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	// Read records and remember the end of the last valid one.
+	reader := bufio.NewReader(f)
+	var lastSeq uint64
+	var offset int64
+	for {
+		payload, err := readBinaryRecord(reader)
+		if errors.Is(err, io.EOF) {
+			return lastSeq, nil
+		}
+		if err != nil {
+			torn, tornErr := isTornTail(f, offset, info.Size())
+			if tornErr != nil {
+				return 0, tornErr
+			}
+			if !torn {
+				// An incomplete record followed by valid ones has a corrupted length prefix.
+				if errors.Is(err, io.ErrUnexpectedEOF) {
+					return 0, ErrCorruptRecord
+				}
+				return 0, err
+			}
+			// Truncate the torn tail.
+			return lastSeq, f.Truncate(offset)
+		}
+		var event sequenceOnly
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return 0, err
+		}
+		// Update lastSeq if the event's sequence number is higher.
+		if event.Sequence > lastSeq {
+			lastSeq = event.Sequence
+		}
+		offset += binaryHeaderSize + int64(len(payload))
+	}
+}
+
+// isTornTail reports whether the invalid record at the given offset is the
+// result of a torn write, which is only the case if it was the last write.
+// A valid record following it, e.g. after a corrupted length prefix, shows
+// that the log is corrupted instead. Zero bytes, which some file systems leave
+// behind after a crash, are never decoded as a valid record.
+//
+// The file is read once through a buffer, and the scan stops after the maximum
+// record size, since the record following the invalid one must start before.
+func isTornTail(f *os.File, offset, size int64) (bool, error) {
+	const bufferSize = 64 << 10
+	limit := offset + binaryHeaderSize + binaryMaxRecordSize
+	reader := bufio.NewReaderSize(io.NewSectionReader(f, offset+1, size-offset-1), bufferSize)
+	for pos := offset + 1; pos <= limit && pos+binaryHeaderSize < size; pos++ {
+		header, err := reader.Peek(binaryHeaderSize)
+		if err != nil {
+			return false, err
+		}
+		length := int64(binary.LittleEndian.Uint32(header[0:4]))
+		checksum := binary.LittleEndian.Uint32(header[4:8])
+		if length > 0 && length <= binaryMaxRecordSize && pos+binaryHeaderSize+length <= size {
+			var payload []byte
+			if binaryHeaderSize+length <= bufferSize {
+				record, err := reader.Peek(int(binaryHeaderSize + length))
+				if err != nil {
+					return false, err
+				}
+				payload = record[binaryHeaderSize:]
+			} else {
+				payload = make([]byte, length)
+				if _, err := f.ReadAt(payload, pos+binaryHeaderSize); err != nil {
+					return false, err
+				}
+			}
+			if crc32.Checksum(payload, castagnoli) == checksum {
+				return false, nil
+			}
+		}
+		if _, err := reader.Discard(1); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// readBinaryRecord reads the payload of a single record from r.
+// It returns io.EOF if there are no more records and io.ErrUnexpectedEOF
+// if the record is incomplete.
+func readBinaryRecord(r io.Reader) ([]byte, error) {
+	var header [binaryHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	length := binary.LittleEndian.Uint32(header[0:4])
+	checksum := binary.LittleEndian.Uint32(header[4:8])
+	if length == 0 || length > binaryMaxRecordSize {
+		return nil, ErrCorruptRecord
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if crc32.Checksum(payload, castagnoli) != checksum {
+		return nil, ErrChecksumMismatch
+	}
+	return payload, nil
+}
+
+// writeBinaryRecord writes the payload as a single record to w.
+func writeBinaryRecord(w io.Writer, payload []byte) error {
+	if len(payload) > binaryMaxRecordSize {
+		return ErrRecordTooLarge
+	}
+	var header [binaryHeaderSize]byte
+	binary.LittleEndian.PutUint32(header[0:4], uint32(len(payload))) //nolint:gosec // length is limited by binaryMaxRecordSize
+	binary.LittleEndian.PutUint32(header[4:8], crc32.Checksum(payload, castagnoli))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
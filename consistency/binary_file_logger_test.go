@@ -0,0 +1,152 @@
+package consistency_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/consistency"
+)
+
+func appendBytes(file string, data []byte) {
+	f, _ := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0600) //nolint:gosec // test helper with controlled file path
+	_, _ = f.Write(data)
+	_ = f.Close()
+}
+
+func Test_BinaryFileLogger_With_CorruptRecordBeforeTail_Should_ReturnError(t *testing.T) {
+	// Arrange
+	logFile := "binary_file_corrupt.log"
+	defer func() { _ = os.Remove(logFile) }()
+	logger := consistency.NewBinaryFileLogger[string, string](logFile)
+	logger.WritePut("key1", "value1")
+	logger.WritePut("key2", "value2")
+	_ = logger.Close()
+	data, _ := os.ReadFile(logFile)
+	data[10] ^= 0xff // Flip a payload byte of the first record.
+	_ = os.WriteFile(logFile, data, 0600)
+
+	// Act
+	logger = consistency.NewBinaryFileLogger[string, string](logFile)
+	err := logger.Close()
+
+	// Assert
+	assert.That(t, "err must be correct", err, consistency.ErrChecksumMismatch)
+}
+
+func Test_BinaryFileLogger_With_CorruptRecordBeforeTail_Should_FailWrites(t *testing.T) {
+	// Arrange
+	logFile := "binary_file_corrupt_writes.log"
+	defer func() { _ = os.Remove(logFile) }()
+	logger := consistency.NewBinaryFileLogger[string, string](logFile)
+	logger.WritePut("key1", "value1")
+	logger.WritePut("key2", "value2")
+	_ = logger.Close()
+	data, _ := os.ReadFile(logFile)
+	data[10] ^= 0xff // Flip a payload byte of the first record.
+	_ = os.WriteFile(logFile, data, 0600)
+	logger = consistency.NewBinaryFileLogger[string, string](logFile)
+	defer func() { _ = logger.Close() }()
+
+	// Act
+	err := logger.WritePutContext(context.Background(), "key3", "value3")
+	info, _ := os.Stat(logFile)
+
+	// Assert
+	assert.That(t, "err must be correct", err, consistency.ErrChecksumMismatch)
+	assert.That(t, "file must not be changed", info.Size(), int64(len(data)))
+}
+
+func Test_BinaryFileLogger_With_CorruptLengthBeforeTail_Should_KeepRecords(t *testing.T) {
+	// Arrange
+	logFile := "binary_file_corrupt_length.log"
+	defer func() { _ = os.Remove(logFile) }()
+	logger := consistency.NewBinaryFileLogger[string, string](logFile)
+	logger.WritePut("key1", "value1")
+	logger.WritePut("key2", "value2")
+	logger.WritePut("key3", "value3")
+	_ = logger.Close()
+	data, _ := os.ReadFile(logFile)
+	data[0] = 0xff // Let the length prefix of the first record point past the end of the file.
+	data[1] = 0xff
+	data[2] = 0xff
+	_ = os.WriteFile(logFile, data, 0600)
+
+	// Act
+	logger = consistency.NewBinaryFileLogger[string, string](logFile)
+	err := logger.Close()
+	info, _ := os.Stat(logFile)
+
+	// Assert
+	assert.That(t, "err must be correct", err, consistency.ErrCorruptRecord)
+	assert.That(t, "file must not be truncated", info.Size(), int64(len(data)))
+}
+
+func Test_BinaryFileLogger_With_Roundtrip_Should_ReadAllEvents(t *testing.T) {
+	// Arrange
+	logFile := "binary_file_roundtrip.log"
+	defer func() { _ = os.Remove(logFile) }()
+	logger := consistency.NewBinaryFileLoggerWithOptions[string, int](logFile, consistency.FileLoggerOptions{
+		SyncPolicy: consistency.SyncPolicyAlways,
+	})
+	ctx := context.Background()
+	_ = logger.WritePutContext(ctx, "key1", 1)
+	_ = logger.WritePutContext(ctx, "key2", 2)
+	_ = logger.WriteDeleteContext(ctx, "key1")
+	_ = logger.Close()
+
+	// Act
+	events, err := collectEvents(logger.ReadEvents())
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "events length must be 3", len(events), 3)
+	assert.That(t, "value must be correct", events[1].Value, 2)
+	assert.That(t, "event type must be delete", events[2].EventType, consistency.EventTypeDelete)
+	assert.That(t, "sequence must be correct", events[2].Sequence, uint64(3))
+}
+
+func Test_BinaryFileLogger_With_TornTail_Should_TruncateAndContinue(t *testing.T) {
+	// Arrange
+	logFile := "binary_file_torn_tail.log"
+	defer func() { _ = os.Remove(logFile) }()
+	logger := consistency.NewBinaryFileLogger[string, string](logFile)
+	logger.WritePut("key1", "value1")
+	logger.WritePut("key2", "value2")
+	_ = logger.Close()
+	appendBytes(logFile, []byte{42, 0, 0, 0, 1, 2, 3, 4, '{', '"'})
+
+	// Act
+	logger = consistency.NewBinaryFileLogger[string, string](logFile)
+	logger.WritePut("key3", "value3")
+	errClose := logger.Close()
+	events, err := collectEvents(logger.ReadEvents())
+
+	// Assert
+	assert.That(t, "close err must be nil", errClose, nil)
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "events length must be 3", len(events), 3)
+	assert.That(t, "sequence must be continued", events[2].Sequence, uint64(3))
+}
+
+func Test_BinaryFileLogger_With_ZeroTail_Should_TruncateAndContinue(t *testing.T) {
+	// Arrange
+	logFile := "binary_file_zero_tail.log"
+	defer func() { _ = os.Remove(logFile) }()
+	logger := consistency.NewBinaryFileLogger[string, string](logFile)
+	logger.WritePut("key1", "value1")
+	_ = logger.Close()
+	appendBytes(logFile, make([]byte, 64))
+
+	// Act
+	logger = consistency.NewBinaryFileLogger[string, string](logFile)
+	logger.WritePut("key2", "value2")
+	errClose := logger.Close()
+	events, err := collectEvents(logger.ReadEvents())
+
+	// Assert
+	assert.That(t, "close err must be nil", errClose, nil)
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "events length must be 2", len(events), 2)
+}
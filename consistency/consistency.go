@@ -1,4 +1,7 @@
 // Package consistency implements transactional log management with `Event`
 // and `EventType` abstractions, and supports file-based persistence using
-// `JsonFileLogger` for reliable data storage.
+// `JsonFileLogger` or the checksummed `BinaryFileLogger` as well as SQL-based
+// persistence using `SqliteLogger` and `PostgresLogger` for reliable data storage.
+// The `EventStore` implementations `InMemoryEventStore`, `SqliteEventStore` and
+// `PostgresEventStore` keep a stream per aggregate with expected-version appends.
 package consistency
@@ -0,0 +1,64 @@
+package consistency
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+)
+
+// ConvertBinaryToJson converts a binary log file written by BinaryFileLogger
+// into a JSON log file readable by JsonFileLogger. Keys and values are copied
+// without decoding, so the concrete types do not need to be known.
+func ConvertBinaryToJson(src, dst string) error {
+	return convertFile(src, dst, binaryFormat[json.RawMessage, json.RawMessage]{}, jsonFormat[json.RawMessage, json.RawMessage]{})
+}
+
+// ConvertJsonToBinary converts a JSON log file written by JsonFileLogger
+// into a binary log file readable by BinaryFileLogger. Keys and values are
+// copied without decoding, so the concrete types do not need to be known.
+func ConvertJsonToBinary(src, dst string) error {
+	return convertFile(src, dst, jsonFormat[json.RawMessage, json.RawMessage]{}, binaryFormat[json.RawMessage, json.RawMessage]{})
+}
+
+// convertFile reads all records from src and writes them to dst using the given formats.
+// The destination file is replaced and synced to disk before returning.
+func convertFile(src, dst string, from, to recordFormat[json.RawMessage, json.RawMessage]) error {
+	in, err := os.Open(src) //nolint:gosec // file path is controlled by caller
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600) //nolint:gosec // file path is controlled by caller
+	if err != nil {
+		return err
+	}
+	defer func() { _ = out.Close() }()
+
+	// Copy each record from the source to the destination format.
+	decode := from.newDecoder(bufio.NewReader(in))
+	writer := bufio.NewWriter(out)
+	encode := to.newEncoder(writer)
+	for {
+		var event RawEvent
+		if err := decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+		if err := encode(event); err != nil {
+			return err
+		}
+	}
+
+	// Ensure that the converted file is durable.
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,53 @@
+package consistency_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/consistency"
+)
+
+func Test_Convert_With_JsonToBinaryAndBack_Should_PreserveEvents(t *testing.T) {
+	// Arrange
+	jsonFile := "convert_source.log"
+	binaryFile := "convert_binary.log"
+	resultFile := "convert_result.log"
+	defer func() {
+		_ = os.Remove(jsonFile)
+		_ = os.Remove(binaryFile)
+		_ = os.Remove(resultFile)
+	}()
+	logger := consistency.NewJsonFileLogger[string, map[string]int](jsonFile)
+	logger.WritePut("key1", map[string]int{"a": 1})
+	logger.WriteDelete("key1")
+	_ = logger.Close()
+
+	// Act
+	err := consistency.ConvertJsonToBinary(jsonFile, binaryFile)
+	err2 := consistency.ConvertBinaryToJson(binaryFile, resultFile)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	binaryLogger := consistency.NewBinaryFileLogger[string, map[string]int](binaryFile)
+	defer func() { _ = binaryLogger.Close() }()
+	binaryEvents, _ := collectEvents(binaryLogger.ReadEvents())
+	assert.That(t, "binary events length must be 2", len(binaryEvents), 2)
+	assert.That(t, "binary value must be correct", binaryEvents[0].Value["a"], 1)
+	source, _ := os.ReadFile(jsonFile)
+	result, _ := os.ReadFile(resultFile)
+	assert.That(t, "json files must be equal", string(result), string(source))
+}
+
+func Test_Convert_With_MissingSource_Should_ReturnError(t *testing.T) {
+	// Arrange
+	binaryFile := "convert_missing.log"
+	defer func() { _ = os.Remove(binaryFile) }()
+
+	// Act
+	err := consistency.ConvertJsonToBinary("missing.log", binaryFile)
+
+	// Assert
+	assert.That(t, "err must not be nil", err != nil, true)
+}
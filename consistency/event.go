@@ -1,5 +1,12 @@
 package consistency
 
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/security"
+)
+
 // EventType represents the type of an event in the transactional log.
 type EventType byte
 
@@ -8,12 +15,40 @@ const (
 	EventTypeDelete EventType = iota
 	// EventTypePut indicates a put (write) operation.
 	EventTypePut
+	// EventTypeCustom indicates a domain specific event identified by its name.
+	EventTypeCustom
 )
 
 // Event represents an entry in the transactional log.
+// Only the key, value, sequence and event type are required. The remaining
+// fields are omitted from the JSON encoding if they are not set, so that logs
+// written by earlier versions remain readable.
 type Event[K, V any] struct {
-	Key       K         `json:"key"`        // The key associated with the event.
-	Value     V         `json:"value"`      // The value associated with the event (only for Put).
-	Sequence  uint64    `json:"sequence"`   // The sequence number of the event, ensuring order.
-	EventType EventType `json:"event_type"` // The type of event (e.g., Put or Delete).
+	Key           K                 `json:"key"`                      // The key associated with the event.
+	Value         V                 `json:"value"`                    // The value associated with the event (only for Put and Custom).
+	Sequence      uint64            `json:"sequence"`                 // The sequence number of the event, ensuring order.
+	EventType     EventType         `json:"event_type"`               // The type of event (e.g., Put or Delete).
+	ID            string            `json:"id,omitempty"`             // The unique ID of the event.
+	Name          string            `json:"name,omitempty"`           // The name of a custom event type.
+	Version       uint64            `json:"version,omitempty"`        // The version of the aggregate identified by the key after this event.
+	SchemaVersion int               `json:"schema_version,omitempty"` // The schema version of the value.
+	Timestamp     time.Time         `json:"timestamp,omitzero"`       // The time the event was written.
+	Actor         string            `json:"actor,omitempty"`          // The user or service that caused the event.
+	CausationID   string            `json:"causation_id,omitempty"`   // The ID of the event or message that caused this event.
+	CorrelationID string            `json:"correlation_id,omitempty"` // The ID shared by all events of a workflow.
+	Metadata      map[string]string `json:"metadata,omitempty"`       // Arbitrary metadata.
+}
+
+// RawEvent is an event with undecoded key and value.
+// It is used to migrate events of older schema versions on read.
+type RawEvent = Event[json.RawMessage, json.RawMessage]
+
+// prepareEvent sets the ID and timestamp of an event if they are missing.
+func prepareEvent[K, V any](event *Event[K, V]) {
+	if event.ID == "" {
+		event.ID = security.GenerateID()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
 }
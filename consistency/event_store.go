@@ -0,0 +1,118 @@
+package consistency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrWrongExpectedVersion = errors.New("wrong expected version")
+)
+
+// ExpectedVersionAny disables the version check of AppendToStream.
+// An expected version of 0 requires that the stream does not exist yet.
+const ExpectedVersionAny = ^uint64(0)
+
+// EventStore is an interface that defines the operations for an event store
+// with a stream per aggregate. Each event has a version within its stream,
+// starting at 1, and a position within the store, which is stored as its
+// sequence number and also starts at 1.
+type EventStore[K, V any] interface {
+	// AppendToStream appends events to a stream if its current version equals the expected version.
+	// It returns ErrWrongExpectedVersion otherwise. The key, version and sequence of the events are assigned by the store.
+	AppendToStream(ctx context.Context, streamID K, expectedVersion uint64, events ...Event[K, V]) error
+	// ReadStream reads the events of a stream starting at the given version in a streaming manner.
+	ReadStream(ctx context.Context, streamID K, version uint64) (<-chan Event[K, V], <-chan error)
+	// ReadAll reads the events of all streams starting at the given position in a streaming manner.
+	ReadAll(ctx context.Context, position uint64) (<-chan Event[K, V], <-chan error)
+	// SubscribeToAll reads the events of all streams starting at the given position and then waits
+	// for appended events until the context is canceled.
+	SubscribeToAll(ctx context.Context, position uint64) (<-chan Event[K, V], <-chan error)
+}
+
+// storeNotifier signals subscribers that events have been appended.
+type storeNotifier struct {
+	appended chan struct{}
+	mutex    sync.Mutex
+}
+
+// newStoreNotifier creates a new storeNotifier instance.
+func newStoreNotifier() *storeNotifier {
+	return &storeNotifier{appended: make(chan struct{})}
+}
+
+// notify wakes up all subscribers waiting for appended events.
+func (a *storeNotifier) notify() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	close(a.appended)
+	a.appended = make(chan struct{})
+}
+
+// wait returns a channel which is closed when events have been appended.
+func (a *storeNotifier) wait() <-chan struct{} {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.appended
+}
+
+// prepareStreamEvents assigns the stream ID, the versions following the given version
+// and the positions following the given position to the events.
+func prepareStreamEvents[K, V any](streamID K, version, position uint64, events []Event[K, V]) []Event[K, V] {
+	prepared := make([]Event[K, V], len(events))
+	for i, event := range events {
+		prepareEvent(&event)
+		event.Key = streamID
+		event.Version = version + uint64(i) + 1   //nolint:gosec // index is never negative
+		event.Sequence = position + uint64(i) + 1 //nolint:gosec // index is never negative
+		prepared[i] = event
+	}
+	return prepared
+}
+
+// subscribeToAll streams the events read from the position and reads again whenever
+// events have been appended or the poll interval elapsed, until the context is canceled.
+// A poll interval of zero disables polling.
+func subscribeToAll[K, V any](ctx context.Context, position uint64, read func(context.Context, uint64) (<-chan Event[K, V], <-chan error), notifier *storeNotifier, interval time.Duration) (<-chan Event[K, V], <-chan error) {
+	errorCh := make(chan error, 1)
+	eventCh := make(chan Event[K, V], 100)
+	// Launch a goroutine to follow the store asynchronously.
+	go func() {
+		defer close(errorCh)
+		defer close(eventCh)
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			// Wait for the appended events before reading to not miss any of them.
+			appended := notifier.wait()
+			events, errs := read(ctx, position)
+			for event := range events {
+				select {
+				case eventCh <- event:
+					position = event.Sequence + 1
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err := <-errs; err != nil {
+				if ctx.Err() == nil {
+					errorCh <- err
+				}
+				return
+			}
+			select {
+			case <-appended:
+			case <-tick:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return eventCh, errorCh
+}
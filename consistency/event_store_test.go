@@ -0,0 +1,90 @@
+package consistency_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/consistency"
+)
+
+func Test_InMemoryEventStore_With_ExpectedVersion_Should_AppendToStream(t *testing.T) {
+	// Arrange
+	store := consistency.NewInMemoryEventStore[string, int]()
+	ctx := context.Background()
+
+	// Act
+	err := store.AppendToStream(ctx, "order-1", 0, consistency.Event[string, int]{Value: 1}, consistency.Event[string, int]{Value: 2})
+	err2 := store.AppendToStream(ctx, "order-2", 0, consistency.Event[string, int]{Value: 3})
+	err3 := store.AppendToStream(ctx, "order-1", 2, consistency.Event[string, int]{Value: 4})
+	events, err4 := collectEvents(store.ReadStream(ctx, "order-1", 2))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "err3 must be nil", err3, nil)
+	assert.That(t, "err4 must be nil", err4, nil)
+	assert.That(t, "events length must be 2", len(events), 2)
+	assert.That(t, "first version must be 2", events[0].Version, uint64(2))
+	assert.That(t, "first position must be 2", events[0].Sequence, uint64(2))
+	assert.That(t, "second version must be 3", events[1].Version, uint64(3))
+	assert.That(t, "second position must be 4", events[1].Sequence, uint64(4))
+	assert.That(t, "second key must be the stream id", events[1].Key, "order-1")
+}
+
+func Test_InMemoryEventStore_With_WrongExpectedVersion_Should_ReturnError(t *testing.T) {
+	// Arrange
+	store := consistency.NewInMemoryEventStore[string, int]()
+	ctx := context.Background()
+	_ = store.AppendToStream(ctx, "order-1", 0, consistency.Event[string, int]{Value: 1})
+
+	// Act
+	err := store.AppendToStream(ctx, "order-1", 0, consistency.Event[string, int]{Value: 2})
+	err2 := store.AppendToStream(ctx, "order-1", consistency.ExpectedVersionAny, consistency.Event[string, int]{Value: 3})
+
+	// Assert
+	assert.That(t, "err must be ErrWrongExpectedVersion", err, consistency.ErrWrongExpectedVersion)
+	assert.That(t, "err2 must be nil", err2, nil)
+}
+
+func Test_InMemoryEventStore_With_ReadAll_Should_ReturnEventsFromPosition(t *testing.T) {
+	// Arrange
+	store := consistency.NewInMemoryEventStore[string, int]()
+	ctx := context.Background()
+	_ = store.AppendToStream(ctx, "order-1", 0, consistency.Event[string, int]{Value: 1})
+	_ = store.AppendToStream(ctx, "order-2", 0, consistency.Event[string, int]{Value: 2})
+	_ = store.AppendToStream(ctx, "order-1", 1, consistency.Event[string, int]{Value: 3})
+
+	// Act
+	events, err := collectEvents(store.ReadAll(ctx, 2))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "events length must be 2", len(events), 2)
+	assert.That(t, "first key must be order-2", events[0].Key, "order-2")
+	assert.That(t, "second value must be 3", events[1].Value, 3)
+}
+
+func Test_InMemoryEventStore_With_SubscribeToAll_Should_ReceiveAppendedEvents(t *testing.T) {
+	// Arrange
+	store := consistency.NewInMemoryEventStore[string, int]()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_ = store.AppendToStream(ctx, "order-1", 0, consistency.Event[string, int]{Value: 1})
+	eventCh, errorCh := store.SubscribeToAll(ctx, 0)
+
+	// Act
+	first := <-eventCh
+	_ = store.AppendToStream(ctx, "order-2", 0, consistency.Event[string, int]{Value: 2})
+	second := <-eventCh
+	cancel()
+	_, open := <-eventCh
+
+	// Assert
+	assert.That(t, "first value must be 1", first.Value, 1)
+	assert.That(t, "second value must be 2", second.Value, 2)
+	assert.That(t, "second position must be 2", second.Sequence, uint64(2))
+	assert.That(t, "event channel must be closed", open, false)
+	assert.That(t, "err must be nil", <-errorCh, nil)
+}
@@ -80,3 +80,14 @@ func Test_EventType_With_PutConstant_Should_BeOne(t *testing.T) {
 	// Assert
 	assert.That(t, "put event type must be 1", result, 1)
 }
+
+func Test_EventType_With_CustomConstant_Should_BeTwo(t *testing.T) {
+	// Arrange
+	eventType := consistency.EventTypeCustom
+
+	// Act
+	result := int(eventType)
+
+	// Assert
+	assert.That(t, "custom event type must be 2", result, 2)
+}
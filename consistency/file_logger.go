@@ -0,0 +1,388 @@
+package consistency
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	ErrLoggerClosed = errors.New("logger closed")
+)
+
+// writeRequest is a queued event and an optional channel for its acknowledgement.
+type writeRequest[K, V any] struct {
+	event Event[K, V]
+	done  chan error
+}
+
+// FileLoggerOptions configures a JsonFileLogger or BinaryFileLogger.
+type FileLoggerOptions struct {
+	// SyncPolicy defines when events are flushed to disk. Default: SyncPolicyNever.
+	SyncPolicy SyncPolicy
+
+	// SyncInterval is the flush interval of SyncPolicyBatch. Default: 10ms.
+	SyncInterval time.Duration
+
+	// Upcasters migrate events of older schema versions on read. Default: none.
+	Upcasters Upcasters
+}
+
+// recordFormat encodes and decodes the records of a log file.
+type recordFormat[K, V any] interface {
+	// newEncoder returns a function writing a single record to w.
+	newEncoder(w io.Writer) func(event Event[K, V]) error
+	// newDecoder returns a function reading a single record from r.
+	// It returns io.EOF after the last record.
+	newDecoder(r io.Reader) func(event *Event[K, V]) error
+	// recover prepares an existing file for appending and returns its last sequence number.
+	recover(file string) (uint64, error)
+}
+
+// fileLogger is the format independent implementation of the Logger interface
+// shared by JsonFileLogger and BinaryFileLogger.
+type fileLogger[K, V any] struct {
+	errorCh      chan error              // Channel for propagating errors to the caller.
+	eventCh      chan writeRequest[K, V] // Channel for queuing events to be written.
+	file         string                  // Path to the log file.
+	format       recordFormat[K, V]      // Format of the records in the file.
+	options      FileLoggerOptions       // Durability options.
+	lastSequence uint64                  // Sequence number of the last written event, owned by run.
+	size         int64                   // Size of the written file, owned by run.
+	closed       bool                    // Indicates whether Close has been called.
+	wg           sync.WaitGroup          // WaitGroup for ensuring graceful shutdown.
+	mutex        sync.Mutex              // Mutex to protect shared resources.
+	closeOnce    sync.Once               // Ensures the Close method is called only once.
+}
+
+// newFileLogger initializes a new fileLogger for the given file path and format.
+func newFileLogger[K, V any](file string, format recordFormat[K, V], options FileLoggerOptions) *fileLogger[K, V] {
+	if options.SyncInterval <= 0 {
+		options.SyncInterval = DefaultSyncInterval
+	}
+	errorCh := make(chan error, 1)
+	eventCh := make(chan writeRequest[K, V], 100) // Buffered channel for queuing events.
+	logger := &fileLogger[K, V]{
+		errorCh: errorCh,
+		eventCh: eventCh,
+		file:    file,
+		format:  format,
+		options: options,
+	}
+
+	// Ensure that the directory and file exist.
+	_ = os.Mkdir(filepath.Dir(file), 0755)
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		_ = os.WriteFile(file, []byte(""), 0600)
+	}
+
+	// Load the last sequence number from the file.
+	lastSeq, err := format.recover(file)
+	logger.wg.Add(1)
+	if err != nil {
+		// Don't append to a file that can't be recovered, but fail every write with the error.
+		logger.reportError(err)
+		go func() {
+			defer logger.wg.Done()
+			logger.fail(err)
+		}()
+		return logger
+	}
+	logger.lastSequence = lastSeq
+
+	// Start the event processing goroutine.
+	go logger.run()
+	return logger
+}
+
+// fail acknowledges every queued event with the error until the logger is closed.
+func (a *fileLogger[K, V]) fail(err error) {
+	for req := range a.eventCh {
+		acknowledge(req, err)
+	}
+}
+
+// reportError propagates an error to the caller without blocking.
+// Only the first error is kept until it has been received.
+func (a *fileLogger[K, V]) reportError(err error) {
+	select {
+	case a.errorCh <- err:
+	default:
+	}
+}
+
+// run processes events from the event channel and writes them to the file.
+// Events that are already queued are written together (group commit),
+// so that a single flush acknowledges many concurrent writers.
+func (a *fileLogger[K, V]) run() {
+	// Mark the goroutine as done when this method exits.
+	defer a.wg.Done()
+	// Open the log file for appending or create it if it doesn't exist.
+	file, err := os.OpenFile(a.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		a.reportError(err) // Report the error if the file can't be opened.
+		a.fail(err)
+		return
+	}
+	defer func() { _ = file.Close() }()
+	info, err := file.Stat()
+	if err != nil {
+		a.reportError(err)
+		a.fail(err)
+		return
+	}
+	a.size = info.Size()
+	// Encoder for writing each event to a record buffer before it is added to the buffered file.
+	var record bytes.Buffer
+	encode := a.format.newEncoder(&record)
+	writer := bufio.NewWriter(file)
+
+	// The ticker is only used for batched flushes.
+	var tick <-chan time.Time
+	if a.options.SyncPolicy == SyncPolicyBatch {
+		ticker := time.NewTicker(a.options.SyncInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	// Requests that were written but are waiting for the next batched flush.
+	var pending []writeRequest[K, V]
+	for {
+		select {
+		case req, ok := <-a.eventCh:
+			if !ok {
+				// Flush the remaining events before exiting.
+				a.commit(file, pending, a.options.SyncPolicy != SyncPolicyNever, nil)
+				return
+			}
+			batch, err := a.write(file, writer, encode, &record, a.collect(req))
+			switch {
+			case err != nil:
+				a.commit(file, batch, false, err)
+			case a.options.SyncPolicy == SyncPolicyBatch:
+				pending = append(pending, batch...)
+			default:
+				a.commit(file, batch, a.options.SyncPolicy == SyncPolicyAlways, nil)
+			}
+		case <-tick:
+			if len(pending) > 0 {
+				a.commit(file, pending, true, nil)
+				pending = nil
+			}
+		}
+	}
+}
+
+// collect gathers the given request and all requests that are already queued.
+func (a *fileLogger[K, V]) collect(req writeRequest[K, V]) []writeRequest[K, V] {
+	batch := []writeRequest[K, V]{req}
+	for len(batch) < cap(a.eventCh) {
+		select {
+		case next, ok := <-a.eventCh:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, next)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// write assigns the next sequence numbers to a batch of events, encodes them and
+// hands them over to the operating system. Requests whose events can't be encoded
+// are acknowledged with the error right away and don't consume a sequence number.
+// If the batch can't be written, the file is truncated to its previous size, so that
+// the sequence numbers of the batch are assigned again. It returns the written requests.
+func (a *fileLogger[K, V]) write(file *os.File, writer *bufio.Writer, encode func(event Event[K, V]) error, record *bytes.Buffer, batch []writeRequest[K, V]) ([]writeRequest[K, V], error) {
+	written := make([]writeRequest[K, V], 0, len(batch))
+	sequence := a.lastSequence
+	size := a.size
+	for _, req := range batch {
+		req.event.Sequence = sequence + 1
+		record.Reset()
+		if err := encode(req.event); err != nil {
+			acknowledge(req, err)
+			continue
+		}
+		// Errors of the buffered writer are kept and returned by Flush.
+		n, _ := writer.Write(record.Bytes())
+		sequence++
+		size += int64(n)
+		written = append(written, req)
+	}
+	if err := writer.Flush(); err != nil {
+		// Discard partially written records and the failed state of the writer.
+		writer.Reset(file)
+		return written, errors.Join(err, file.Truncate(a.size))
+	}
+	a.lastSequence = sequence
+	a.size = size
+	return written, nil
+}
+
+// commit optionally syncs the file and acknowledges all requests of a batch.
+func (a *fileLogger[K, V]) commit(file *os.File, batch []writeRequest[K, V], sync bool, err error) {
+	if err == nil && sync {
+		err = file.Sync()
+	}
+	if err != nil {
+		a.reportError(err)
+	}
+	for _, req := range batch {
+		acknowledge(req, err)
+	}
+}
+
+// acknowledge notifies a waiting writer about the result of its request.
+func acknowledge[K, V any](req writeRequest[K, V], err error) {
+	if req.done != nil {
+		req.done <- err
+	}
+}
+
+// Close shuts down the logger, ensuring all pending events are written.
+func (a *fileLogger[K, V]) Close() error {
+	var closeErr error
+	// Ensure Close is executed only once.
+	a.closeOnce.Do(func() {
+		a.mutex.Lock()
+		a.closed = true
+		close(a.eventCh) // Signal the event processing loop to stop.
+		a.mutex.Unlock()
+		a.wg.Wait() // Wait for the processing goroutine to finish.
+		// Close the error channel and capture any errors that occurred.
+		close(a.errorCh)
+		for err := range a.errorCh {
+			closeErr = err
+		}
+	})
+	return closeErr
+}
+
+// Error returns a read-only channel for retrieving errors.
+func (a *fileLogger[K, V]) Error() <-chan error {
+	return a.errorCh
+}
+
+// ReadEvents reads events from the log file and returns two channels.
+// The method uses a goroutine to read events asynchronously, allowing the caller
+// to process events and handle errors as they are received.
+func (a *fileLogger[K, V]) ReadEvents() (<-chan Event[K, V], <-chan error) {
+	return a.ReadEventsFrom(context.Background(), 0)
+}
+
+// ReadEventsFrom reads all events with a sequence number greater than or equal to
+// the given sequence. Reading stops early if the context is done.
+func (a *fileLogger[K, V]) ReadEventsFrom(ctx context.Context, sequence uint64) (<-chan Event[K, V], <-chan error) {
+	errorCh := make(chan error, 1)
+	eventCh := make(chan Event[K, V], 100)
+	// Launch a goroutine to handle the file reading process asynchronously.
+	go func() {
+		defer close(errorCh)
+		defer close(eventCh)
+		// Open the log file for reading.
+		file, err := os.Open(a.file)
+		if err != nil {
+			errorCh <- err
+			return
+		}
+		defer func() { _ = file.Close() }()
+		// Create a decoder to read events from the file.
+		decode := a.format.newDecoder(bufio.NewReader(file))
+		// Read events in a loop until EOF or an error occurs.
+		for {
+			var event Event[K, V]
+			// Decode the next event from the file.
+			if err := decode(&event); err != nil {
+				if errors.Is(err, io.EOF) {
+					// Exit gracefully if all events have been read.
+					return
+				}
+				// Report any other decoding errors and terminate the loop.
+				errorCh <- err
+				return
+			}
+			// Skip events before the requested sequence.
+			if event.Sequence < sequence {
+				continue
+			}
+			// Send the successfully decoded event to the event channel.
+			select {
+			case eventCh <- event:
+			case <-ctx.Done():
+				errorCh <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return eventCh, errorCh
+}
+
+// WriteDelete writes a delete event to the log.
+func (a *fileLogger[K, V]) WriteDelete(key K) {
+	_ = a.enqueue(context.Background(), Event[K, V]{EventType: EventTypeDelete, Key: key}, nil)
+}
+
+// WriteDeleteContext writes a delete event to the log and waits until it has been
+// written according to the sync policy.
+func (a *fileLogger[K, V]) WriteDeleteContext(ctx context.Context, key K) error {
+	return a.writeAndWait(ctx, Event[K, V]{EventType: EventTypeDelete, Key: key})
+}
+
+// WritePut writes a put event to the log.
+func (a *fileLogger[K, V]) WritePut(key K, value V) {
+	_ = a.enqueue(context.Background(), Event[K, V]{EventType: EventTypePut, Key: key, Value: value}, nil)
+}
+
+// WritePutContext writes a put event to the log and waits until it has been
+// written according to the sync policy.
+func (a *fileLogger[K, V]) WritePutContext(ctx context.Context, key K, value V) error {
+	return a.writeAndWait(ctx, Event[K, V]{EventType: EventTypePut, Key: key, Value: value})
+}
+
+// WriteEvent writes an event with its metadata to the log and waits until it has
+// been written according to the sync policy. The sequence number is assigned by
+// the logger, a missing ID and timestamp are generated.
+func (a *fileLogger[K, V]) WriteEvent(ctx context.Context, event Event[K, V]) error {
+	return a.writeAndWait(ctx, event)
+}
+
+// enqueue queues the event for writing. The sequence number is assigned when the
+// event is written, so that it is only consumed by events in the file.
+func (a *fileLogger[K, V]) enqueue(ctx context.Context, event Event[K, V], done chan error) error {
+	a.mutex.Lock()         // Lock the logger to ensure thread-safe access.
+	defer a.mutex.Unlock() // Unlock the logger when the method exits.
+	if a.closed {
+		return ErrLoggerClosed
+	}
+	prepareEvent(&event)
+	select {
+	case a.eventCh <- writeRequest[K, V]{event: event, done: done}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// writeAndWait queues the event and waits for its acknowledgement.
+// If the context is done while waiting, the event may still be written.
+func (a *fileLogger[K, V]) writeAndWait(ctx context.Context, event Event[K, V]) error {
+	done := make(chan error, 1)
+	if err := a.enqueue(ctx, event, done); err != nil {
+		return err
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,99 @@
+package consistency
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// InMemoryEventStore is an in-memory implementation of the EventStore interface.
+// It is intended for tests and for single-process applications without persistence.
+type InMemoryEventStore[K comparable, V any] struct {
+	events   []Event[K, V]
+	notifier *storeNotifier
+	streams  map[K][]int // Indices of the events of each stream.
+	mutex    sync.RWMutex
+}
+
+// NewInMemoryEventStore creates a new instance of InMemoryEventStore.
+func NewInMemoryEventStore[K comparable, V any]() *InMemoryEventStore[K, V] {
+	return &InMemoryEventStore[K, V]{
+		notifier: newStoreNotifier(),
+		streams:  make(map[K][]int),
+	}
+}
+
+// AppendToStream appends events to a stream if its current version equals the expected version.
+func (a *InMemoryEventStore[K, V]) AppendToStream(ctx context.Context, streamID K, expectedVersion uint64, events ...Event[K, V]) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	version := uint64(len(a.streams[streamID]))
+	if expectedVersion != ExpectedVersionAny && expectedVersion != version {
+		return ErrWrongExpectedVersion
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	for _, event := range prepareStreamEvents(streamID, version, uint64(len(a.events)), events) {
+		a.streams[streamID] = append(a.streams[streamID], len(a.events))
+		a.events = append(a.events, event)
+	}
+	a.notifier.notify()
+	return nil
+}
+
+// ReadAll reads the events of all streams with a position greater than or equal to the given position.
+func (a *InMemoryEventStore[K, V]) ReadAll(ctx context.Context, position uint64) (<-chan Event[K, V], <-chan error) {
+	a.mutex.RLock()
+	var events []Event[K, V]
+	if start := max(position, 1) - 1; start < uint64(len(a.events)) {
+		events = slices.Clone(a.events[start:])
+	}
+	a.mutex.RUnlock()
+	return streamEvents(ctx, events)
+}
+
+// ReadStream reads the events of a stream with a version greater than or equal to the given version.
+func (a *InMemoryEventStore[K, V]) ReadStream(ctx context.Context, streamID K, version uint64) (<-chan Event[K, V], <-chan error) {
+	a.mutex.RLock()
+	var events []Event[K, V]
+	indices := a.streams[streamID]
+	if start := max(version, 1) - 1; start < uint64(len(indices)) {
+		for _, index := range indices[start:] {
+			events = append(events, a.events[index])
+		}
+	}
+	a.mutex.RUnlock()
+	return streamEvents(ctx, events)
+}
+
+// SubscribeToAll reads the events of all streams starting at the given position and then
+// waits for appended events until the context is canceled.
+func (a *InMemoryEventStore[K, V]) SubscribeToAll(ctx context.Context, position uint64) (<-chan Event[K, V], <-chan error) {
+	return subscribeToAll(ctx, position, a.ReadAll, a.notifier, 0)
+}
+
+// streamEvents sends the events to the returned channel until the context is canceled.
+func streamEvents[K, V any](ctx context.Context, events []Event[K, V]) (<-chan Event[K, V], <-chan error) {
+	errorCh := make(chan error, 1)
+	eventCh := make(chan Event[K, V], 100)
+	go func() {
+		defer close(errorCh)
+		defer close(eventCh)
+		for _, event := range events {
+			select {
+			case eventCh <- event:
+			case <-ctx.Done():
+				errorCh <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return eventCh, errorCh
+}
@@ -2,55 +2,70 @@ package consistency
 
 import (
 	"encoding/json"
+	"io"
 	"os"
-	"path/filepath"
-	"sync"
 )
 
 // JsonFileLogger is a file-based implementation of the Logger interface.
 // It writes events to a JSON-formatted file for persistence.
 type JsonFileLogger[K, V any] struct {
-	errorCh      chan error       // Channel for propagating errors to the caller.
-	eventCh      chan Event[K, V] // Channel for queuing events to be written.
-	file         string           // Path to the log file.
-	lastSequence uint64           // Sequence number of the last event.
-	wg           sync.WaitGroup   // WaitGroup for ensuring graceful shutdown.
-	mutex        sync.Mutex       // Mutex to protect shared resources.
-	closeOnce    sync.Once        // Ensures the Close method is called only once.
+	*fileLogger[K, V]
 }
 
 // NewJsonFileLogger initializes a new JsonFileLogger for the given file path.
 func NewJsonFileLogger[K, V any](file string) *JsonFileLogger[K, V] {
-	errorCh := make(chan error, 1)
-	eventCh := make(chan Event[K, V], 100) // Buffered channel for queuing events.
-	logger := &JsonFileLogger[K, V]{
-		errorCh: errorCh,
-		eventCh: eventCh,
-		file:    file,
+	return NewJsonFileLoggerWithOptions[K, V](file, FileLoggerOptions{})
+}
+
+// NewJsonFileLoggerWithOptions initializes a new JsonFileLogger for the given file path
+// and flushes written events to disk according to the given options.
+func NewJsonFileLoggerWithOptions[K, V any](file string, options FileLoggerOptions) *JsonFileLogger[K, V] {
+	return &JsonFileLogger[K, V]{
+		fileLogger: newFileLogger[K, V](file, jsonFormat[K, V]{upcasters: options.Upcasters}, options),
 	}
+}
+
+// jsonFormat stores events as newline-delimited JSON.
+type jsonFormat[K, V any] struct {
+	upcasters Upcasters // Upcasters applied on read.
+}
 
-	// Ensure that the directory and file exist.
-	_ = os.Mkdir(filepath.Dir(file), 0755)
-	if _, err := os.Stat(file); os.IsNotExist(err) {
-		_ = os.WriteFile(file, []byte(""), 0600)
+// newEncoder returns a function writing a single JSON line to w.
+func (jsonFormat[K, V]) newEncoder(w io.Writer) func(event Event[K, V]) error {
+	encoder := json.NewEncoder(w)
+	return func(event Event[K, V]) error {
+		return encoder.Encode(event)
 	}
+}
 
-	// Load the last sequence number from the file.
-	lastSeq, err := loadLastSequence[K, V](file)
-	if err != nil {
-		errorCh <- err // Report error if unable to read sequence number.
-	} else {
-		logger.lastSequence = lastSeq
+// newDecoder returns a function reading a single JSON value from r.
+func (f jsonFormat[K, V]) newDecoder(r io.Reader) func(event *Event[K, V]) error {
+	decoder := json.NewDecoder(r)
+	return func(event *Event[K, V]) error {
+		var data json.RawMessage
+		if err := decoder.Decode(&data); err != nil {
+			return err
+		}
+		decoded, err := unmarshalEvent[K, V](data, f.upcasters)
+		*event = decoded
+		return err
 	}
+}
 
-	// Start the event processing goroutine.
-	logger.wg.Add(1)
-	go logger.run()
-	return logger
+// recover reads the log file to determine the last sequence number.
+func (jsonFormat[K, V]) recover(file string) (uint64, error) {
+	return loadLastSequence(file)
+}
+
+// sequenceOnly is used to decode the sequence number of an event.
+type sequenceOnly struct {
+	Sequence uint64 `json:"sequence"`
 }
 
 // loadLastSequence reads the log file to determine the last sequence number.
-func loadLastSequence[K, V any](file string) (uint64, error) {
+// Only the sequence numbers are decoded, so that events of older schema versions
+// don't need to be upcasted.
+func loadLastSequence(file string) (uint64, error) {
 	// Open the file for reading.
 	f, err := os.Open(file) //nolint:gosec // file path is controlled by caller
 	if err != nil {
@@ -66,7 +81,7 @@ func loadLastSequence[K, V any](file string) (uint64, error) {
 	decoder := json.NewDecoder(f)
 	var lastSeq uint64
 	for {
-		var event Event[K, V]
+		var event sequenceOnly
 		if err := decoder.Decode(&event); err != nil {
 			if err.Error() == "EOF" {
 				break // End of file, stop reading.
@@ -81,110 +96,3 @@ func loadLastSequence[K, V any](file string) (uint64, error) {
 
 	return lastSeq, nil
 }
-
-// run processes events from the event channel and writes them to the file.
-func (a *JsonFileLogger[K, V]) run() {
-	// Mark the goroutine as done when this method exits.
-	defer a.wg.Done()
-	// Open the log file for appending or create it if it doesn't exist.
-	file, err := os.OpenFile(a.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
-	if err != nil {
-		a.errorCh <- err // Report the error if the file can't be opened.
-		return
-	}
-	defer func() { _ = file.Close() }()
-	// JSON encoder for writing events to the file.
-	encoder := json.NewEncoder(file)
-	// Process events from the event channel.
-	for event := range a.eventCh {
-		if err := encoder.Encode(event); err != nil {
-			a.errorCh <- err
-			return
-		}
-	}
-}
-
-// Close shuts down the logger, ensuring all pending events are written.
-func (a *JsonFileLogger[K, V]) Close() error {
-	var closeErr error
-	// Ensure Close is executed only once.
-	a.closeOnce.Do(func() {
-		close(a.eventCh) // Signal the event processing loop to stop.
-		a.wg.Wait()      // Wait for the processing goroutine to finish.
-		// Close the error channel and capture any errors that occurred.
-		close(a.errorCh)
-		for err := range a.errorCh {
-			closeErr = err
-		}
-	})
-	return closeErr
-}
-
-// Error returns a read-only channel for retrieving errors.
-func (a *JsonFileLogger[K, V]) Error() <-chan error {
-	return a.errorCh
-}
-
-// ReadEvents reads events from the log file and returns two channels.
-// The method uses a goroutine to read events asynchronously, allowing the caller
-// to process events and handle errors as they are received.
-func (a *JsonFileLogger[K, V]) ReadEvents() (<-chan Event[K, V], <-chan error) {
-	errorCh := make(chan error, 1)
-	eventCh := make(chan Event[K, V], 100)
-	// Launch a goroutine to handle the file reading process asynchronously.
-	go func() {
-		defer close(errorCh)
-		defer close(eventCh)
-		// Open the log file for reading.
-		file, err := os.Open(a.file)
-		if err != nil {
-			errorCh <- err
-			return
-		}
-		defer func() { _ = file.Close() }()
-		// Create a JSON decoder to read events from the file.
-		decoder := json.NewDecoder(file)
-		// Read events in a loop until EOF or an error occurs.
-		for {
-			var event Event[K, V]
-			// Decode the next event from the file.
-			if err := decoder.Decode(&event); err != nil {
-				if err.Error() == "EOF" {
-					// Exit gracefully if all events have been read.
-					return
-				}
-				// Report any other decoding errors and terminate the loop.
-				errorCh <- err
-				return
-			}
-			// Send the successfully decoded event to the event channel.
-			eventCh <- event
-		}
-	}()
-	return eventCh, errorCh
-}
-
-// WriteDelete writes a delete event to the log.
-func (a *JsonFileLogger[K, V]) WriteDelete(key K) {
-	a.mutex.Lock()         // Lock the logger to ensure thread-safe access.
-	defer a.mutex.Unlock() // Unlock the logger when the method exits.
-	a.lastSequence++       // Increment the sequence number for this event.
-	a.eventCh <- Event[K, V]{
-		Sequence:  a.lastSequence,
-		EventType: EventTypeDelete,
-		Key:       key,
-	}
-}
-
-// WritePut writes a put event to the log.
-func (a *JsonFileLogger[K, V]) WritePut(key K, value V) {
-	a.mutex.Lock()         // Lock the logger to ensure thread-safe access.
-	defer a.mutex.Unlock() // Unlock the logger when the method exits.
-	a.lastSequence++       // Increment the sequence number for this event.
-	a.eventCh <- Event[K, V]{
-		Sequence:  a.lastSequence,
-		EventType: EventTypePut,
-		Key:       key,
-		Value:     value,
-	}
-}
@@ -1,8 +1,11 @@
 package consistency_test
 
 import (
+	"context"
 	"encoding/json"
+	"math"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -120,3 +123,140 @@ func Test_JsonFileLogger_With_WritePutAndDelete_Should_LogAllEvents(t *testing.T
 		assert.That(t, "sequence must be correct", events[i].Sequence, uint64(i)+1) //nolint:gosec // test code with controlled loop bounds
 	}
 }
+
+func Test_JsonFileLogger_With_ClosedLogger_Should_ReturnErrLoggerClosed(t *testing.T) {
+	// Arrange
+	logFile := "json_file_closed.log"
+	defer func() { _ = os.Remove(logFile) }()
+	logger := consistency.NewJsonFileLogger[string, string](logFile)
+	_ = logger.Close()
+
+	// Act
+	err := logger.WritePutContext(context.Background(), "key1", "value1")
+
+	// Assert
+	assert.That(t, "err must be correct", err, consistency.ErrLoggerClosed)
+}
+
+func Test_JsonFileLogger_With_ContextCanceled_Should_ReturnContextError(t *testing.T) {
+	// Arrange
+	logFile := "json_file_context_canceled.log"
+	defer func() { _ = os.Remove(logFile) }()
+	logger := consistency.NewJsonFileLogger[string, string](logFile)
+	defer func() { _ = logger.Close() }()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Act
+	err := logger.WritePutContext(ctx, "key1", "value1")
+
+	// Assert
+	assert.That(t, "err must be correct", err, context.Canceled)
+}
+
+func Test_JsonFileLogger_With_InvalidPathContext_Should_ReturnError(t *testing.T) {
+	// Arrange
+	logFile := "json_file_logger_test.go/json_file_context_error.log"
+	logger := consistency.NewJsonFileLogger[string, string](logFile)
+	defer func() { _ = logger.Close() }()
+
+	// Act
+	err := logger.WritePutContext(context.Background(), "key1", "value1")
+
+	// Assert
+	assert.That(t, "err must not be nil", err != nil, true)
+}
+
+func Test_JsonFileLogger_With_SyncPolicyAlways_Should_PersistBeforeReturning(t *testing.T) {
+	// Arrange
+	logFile := "json_file_sync_always.log"
+	defer func() { _ = os.Remove(logFile) }()
+	logger := consistency.NewJsonFileLoggerWithOptions[string, string](logFile, consistency.FileLoggerOptions{
+		SyncPolicy: consistency.SyncPolicyAlways,
+	})
+	defer func() { _ = logger.Close() }()
+	ctx := context.Background()
+
+	// Act
+	err := logger.WritePutContext(ctx, "key1", "value1")
+	err2 := logger.WriteDeleteContext(ctx, "key1")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	events, _ := decodeJson[string, string](logFile)
+	assert.That(t, "events length must be 2", len(events), 2)
+	assert.That(t, "event type must be delete", events[1].EventType, consistency.EventTypeDelete)
+}
+
+func Test_JsonFileLogger_With_SyncPolicyBatch_Should_GroupConcurrentWrites(t *testing.T) {
+	// Arrange
+	logFile := "json_file_sync_batch.log"
+	defer func() { _ = os.Remove(logFile) }()
+	logger := consistency.NewJsonFileLoggerWithOptions[string, int](logFile, consistency.FileLoggerOptions{
+		SyncPolicy:   consistency.SyncPolicyBatch,
+		SyncInterval: 5 * time.Millisecond,
+	})
+	defer func() { _ = logger.Close() }()
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+
+	// Act
+	for i := range 50 {
+		wg.Go(func() {
+			errs <- logger.WritePutContext(ctx, "key", i)
+		})
+	}
+	wg.Wait()
+	close(errs)
+
+	// Assert
+	for err := range errs {
+		assert.That(t, "err must be nil", err, nil)
+	}
+	events, _ := decodeJson[string, int](logFile)
+	assert.That(t, "events length must be 50", len(events), 50)
+	for i := range 50 {
+		assert.That(t, "sequence must be ordered", events[i].Sequence, uint64(i)+1) //nolint:gosec // test code with controlled loop bounds
+	}
+}
+
+func Test_JsonFileLogger_With_ReadEventsFrom_Should_SkipEarlierEvents(t *testing.T) {
+	// Arrange
+	logFile := "json_file_read_events_from.log"
+	defer func() { _ = os.Remove(logFile) }()
+	logger := consistency.NewJsonFileLogger[string, string](logFile)
+	logger.WritePut("key1", "value1")
+	logger.WritePut("key2", "value2")
+	logger.WritePut("key3", "value3")
+	_ = logger.Close()
+
+	// Act
+	events, err := collectEvents(logger.ReadEventsFrom(context.Background(), 2))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "events length must be 2", len(events), 2)
+	assert.That(t, "first key must be correct", events[0].Key, "key2")
+}
+
+func Test_JsonFileLogger_With_UnencodableEvent_Should_KeepSequence(t *testing.T) {
+	// Arrange
+	logFile := "json_file_unencodable_event.log"
+	defer func() { _ = os.Remove(logFile) }()
+	logger := consistency.NewJsonFileLogger[string, float64](logFile)
+	ctx := context.Background()
+
+	// Act
+	err := logger.WritePutContext(ctx, "key1", math.NaN())
+	err2 := logger.WritePutContext(ctx, "key2", 2)
+	_ = logger.Close()
+
+	// Assert
+	assert.That(t, "err must not be nil", err != nil, true)
+	assert.That(t, "err2 must be nil", err2, nil)
+	events, _ := decodeJson[string, float64](logFile)
+	assert.That(t, "events length must be 1", len(events), 1)
+	assert.That(t, "sequence must be 1", events[0].Sequence, uint64(1))
+}
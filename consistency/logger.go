@@ -1,13 +1,25 @@
 package consistency
 
+import "context"
+
 // Logger is an interface that defines the operations for a transactional log.
+// SQL loggers have committed an event when it has been written. File loggers have
+// only handed it over to the operating system, unless their SyncPolicy flushes it to disk.
 type Logger[K, V any] interface {
 	// Close closes the logger and ensures all pending events are processed.
 	Close() error
 	// WriteDelete writes a delete event to the log.
 	WriteDelete(key K)
+	// WriteDeleteContext writes a delete event to the log and returns after it has been written.
+	WriteDeleteContext(ctx context.Context, key K) error
 	// WritePut writes a put event to the log.
 	WritePut(key K, value V)
+	// WritePutContext writes a put event to the log and returns after it has been written.
+	WritePutContext(ctx context.Context, key K, value V) error
+	// WriteEvent writes an event with its metadata to the log and returns after it has been written.
+	WriteEvent(ctx context.Context, event Event[K, V]) error
 	// ReadEvents reads events from the log in a streaming manner.
 	ReadEvents() (<-chan Event[K, V], <-chan error)
+	// ReadEventsFrom reads events starting at the given sequence number in a streaming manner.
+	ReadEventsFrom(ctx context.Context, sequence uint64) (<-chan Event[K, V], <-chan error)
 }
@@ -0,0 +1,107 @@
+package consistency
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// postgresStreamQueries are the statements used by PostgresEventStore to append events.
+var postgresStreamQueries = sqlStreamQueries{
+	version:  "SELECT COALESCE(MAX(version), 0) FROM event_store WHERE stream_id = $1",
+	position: "SELECT COALESCE(MAX(position), 0) FROM event_store",
+	insert:   "INSERT INTO event_store (position, stream_id, version, event_type, value, header) VALUES ($1, $2, $3, $4, $5, $6)",
+}
+
+// PostgresEventStore is a PostgreSQL-based implementation of the EventStore interface.
+// It appends events to the event_store table with a gap-free position.
+// The table is locked for writers during each append, so that appends of other
+// replicas are serialized. Their events are received by SubscribeToAll after
+// the poll interval.
+type PostgresEventStore[K, V any] struct {
+	db       *sql.DB
+	notifier *storeNotifier
+	options  SqlEventStoreOptions
+	mutex    sync.Mutex // Mutex to serialize writers of this process.
+}
+
+// NewPostgresEventStore creates a new instance of PostgresEventStore.
+func NewPostgresEventStore[K, V any](db *sql.DB) *PostgresEventStore[K, V] {
+	return NewPostgresEventStoreWithOptions[K, V](db, SqlEventStoreOptions{})
+}
+
+// NewPostgresEventStoreWithOptions creates a new instance of PostgresEventStore with the given options.
+func NewPostgresEventStoreWithOptions[K, V any](db *sql.DB, options SqlEventStoreOptions) *PostgresEventStore[K, V] {
+	options.PollInterval = sqlPollInterval(options)
+	return &PostgresEventStore[K, V]{
+		db:       db,
+		notifier: newStoreNotifier(),
+		options:  options,
+	}
+}
+
+// AppendToStream appends events to a stream if its current version equals the expected version.
+// The events are committed within a single transaction.
+func (a *PostgresEventStore[K, V]) AppendToStream(ctx context.Context, streamID K, expectedVersion uint64, events ...Event[K, V]) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Ensure that the table is not modified concurrently.
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	// Ensure that the version and position are derived and used atomically by using a transaction.
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Serialize writers of all replicas while readers are still allowed.
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE event_store IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return err
+	}
+
+	if err := appendSqlStream(ctx, tx, postgresStreamQueries, streamID, expectedVersion, events); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	a.notifier.notify()
+	return nil
+}
+
+// Init initializes the table. Existing events are kept.
+func (a *PostgresEventStore[K, V]) Init(ctx context.Context) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Create the table.
+	_, err := a.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS event_store (position BIGINT PRIMARY KEY, stream_id TEXT NOT NULL, version BIGINT NOT NULL, event_type SMALLINT NOT NULL, value TEXT NOT NULL, header TEXT NOT NULL DEFAULT '{}', UNIQUE (stream_id, version));")
+	return err
+}
+
+// ReadAll reads the events of all streams with a position greater than or equal to the given position.
+func (a *PostgresEventStore[K, V]) ReadAll(ctx context.Context, position uint64) (<-chan Event[K, V], <-chan error) {
+	return readSqlEventPages(ctx, a.db, "SELECT position, event_type, stream_id, value, header FROM event_store WHERE position >= $1 ORDER BY position LIMIT $2", a.options.Upcasters, position, nextPosition[K, V])
+}
+
+// ReadStream reads the events of a stream with a version greater than or equal to the given version.
+func (a *PostgresEventStore[K, V]) ReadStream(ctx context.Context, streamID K, version uint64) (<-chan Event[K, V], <-chan error) {
+	encodedStreamID, err := encodeStreamID(streamID)
+	if err != nil {
+		return failedEvents[K, V](err)
+	}
+	return readSqlEventPages(ctx, a.db, "SELECT position, event_type, stream_id, value, header FROM event_store WHERE stream_id = $1 AND version >= $2 ORDER BY position LIMIT $3", a.options.Upcasters, version, nextVersion[K, V], encodedStreamID)
+}
+
+// SubscribeToAll reads the events of all streams starting at the given position and then
+// waits for appended events until the context is canceled.
+func (a *PostgresEventStore[K, V]) SubscribeToAll(ctx context.Context, position uint64) (<-chan Event[K, V], <-chan error) {
+	return subscribeToAll(ctx, position, a.ReadAll, a.notifier, a.options.PollInterval)
+}
@@ -0,0 +1,44 @@
+package consistency_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/consistency"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func Test_PostgresEventStore_With_WrongExpectedVersion_Should_ReturnError(t *testing.T) {
+	// Arrange
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set, skipping PostgreSQL tests")
+	}
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, "DROP TABLE IF EXISTS event_store;"); err != nil {
+		t.Fatal(err)
+	}
+	store := consistency.NewPostgresEventStore[string, int](db)
+	if err := store.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_ = store.AppendToStream(ctx, "order-1", 0, consistency.Event[string, int]{Value: 1})
+
+	// Act
+	err = store.AppendToStream(ctx, "order-1", 0, consistency.Event[string, int]{Value: 2})
+	events, err2 := collectEvents(store.ReadStream(ctx, "order-1", 1))
+
+	// Assert
+	assert.That(t, "err must be ErrWrongExpectedVersion", err, consistency.ErrWrongExpectedVersion)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "events length must be 1", len(events), 1)
+	assert.That(t, "version must be 1", events[0].Version, uint64(1))
+}
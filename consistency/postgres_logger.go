@@ -0,0 +1,161 @@
+package consistency
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// PostgresLogger is a PostgreSQL-based implementation of the Logger interface.
+// It appends events to the event_log table with a gap-free sequence.
+// The table is locked for writers during each append, so that concurrent writers
+// of other replicas are serialized instead of producing gaps like a SERIAL column.
+type PostgresLogger[K, V any] struct {
+	db        *sql.DB
+	options   SqlLoggerOptions
+	errorCh   chan error // Channel for propagating errors of WritePut and WriteDelete.
+	closed    bool       // Indicates whether Close has been called.
+	mutex     sync.Mutex // Mutex to serialize writers of this process.
+	closeOnce sync.Once  // Ensures the Close method is called only once.
+}
+
+// NewPostgresLogger creates a new instance of PostgresLogger.
+func NewPostgresLogger[K, V any](db *sql.DB) *PostgresLogger[K, V] {
+	return NewPostgresLoggerWithOptions[K, V](db, SqlLoggerOptions{})
+}
+
+// NewPostgresLoggerWithOptions creates a new instance of PostgresLogger with the given options.
+func NewPostgresLoggerWithOptions[K, V any](db *sql.DB, options SqlLoggerOptions) *PostgresLogger[K, V] {
+	return &PostgresLogger[K, V]{
+		db:      db,
+		errorCh: make(chan error, 1),
+		options: options,
+	}
+}
+
+// Close marks the logger as closed and returns the last unreported error.
+// The database connection is owned by the caller and stays open.
+func (a *PostgresLogger[K, V]) Close() error {
+	var closeErr error
+	a.closeOnce.Do(func() {
+		a.mutex.Lock()
+		a.closed = true
+		close(a.errorCh)
+		a.mutex.Unlock()
+		for err := range a.errorCh {
+			closeErr = err
+		}
+	})
+	return closeErr
+}
+
+// Error returns a read-only channel for retrieving errors of WritePut and WriteDelete.
+func (a *PostgresLogger[K, V]) Error() <-chan error {
+	return a.errorCh
+}
+
+// Init initializes the table. Existing events are kept.
+func (a *PostgresLogger[K, V]) Init(ctx context.Context) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Create the table.
+	_, err := a.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS event_log (sequence BIGINT PRIMARY KEY, event_type SMALLINT NOT NULL, key TEXT NOT NULL, value TEXT NOT NULL, header TEXT NOT NULL DEFAULT '{}');")
+	return err
+}
+
+// ReadEvents reads all events from the table.
+func (a *PostgresLogger[K, V]) ReadEvents() (<-chan Event[K, V], <-chan error) {
+	return a.ReadEventsFrom(context.Background(), 0)
+}
+
+// ReadEventsFrom reads all events with a sequence number greater than or equal to the given sequence.
+func (a *PostgresLogger[K, V]) ReadEventsFrom(ctx context.Context, sequence uint64) (<-chan Event[K, V], <-chan error) {
+	return readSqlEvents[K, V](ctx, a.db, "SELECT sequence, event_type, key, value, header FROM event_log WHERE sequence >= $1 ORDER BY sequence", a.options.Upcasters, sequence)
+}
+
+// WriteDelete writes a delete event to the log.
+func (a *PostgresLogger[K, V]) WriteDelete(key K) {
+	a.report(a.write(context.Background(), Event[K, V]{EventType: EventTypeDelete, Key: key}))
+}
+
+// WriteDeleteContext writes a delete event to the log and returns after it has been committed.
+func (a *PostgresLogger[K, V]) WriteDeleteContext(ctx context.Context, key K) error {
+	return a.write(ctx, Event[K, V]{EventType: EventTypeDelete, Key: key})
+}
+
+// WritePut writes a put event to the log.
+func (a *PostgresLogger[K, V]) WritePut(key K, value V) {
+	a.report(a.write(context.Background(), Event[K, V]{EventType: EventTypePut, Key: key, Value: value}))
+}
+
+// WritePutContext writes a put event to the log and returns after it has been committed.
+func (a *PostgresLogger[K, V]) WritePutContext(ctx context.Context, key K, value V) error {
+	return a.write(ctx, Event[K, V]{EventType: EventTypePut, Key: key, Value: value})
+}
+
+// WriteEvent writes an event with its metadata to the log and returns after it has been committed.
+// The sequence number is assigned by the logger, a missing ID and timestamp are generated.
+func (a *PostgresLogger[K, V]) WriteEvent(ctx context.Context, event Event[K, V]) error {
+	return a.write(ctx, event)
+}
+
+// report propagates an error to the caller without blocking.
+func (a *PostgresLogger[K, V]) report(err error) {
+	if err == nil {
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return
+	}
+	select {
+	case a.errorCh <- err:
+	default:
+	}
+}
+
+// write appends an event with the next sequence number to the table.
+func (a *PostgresLogger[K, V]) write(ctx context.Context, event Event[K, V]) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Ensure that the table is not modified concurrently.
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return ErrLoggerClosed
+	}
+
+	// Encode the key, value and header as JSON.
+	prepareEvent(&event)
+	encodedKey, encodedValue, encodedHeader, err := encodeSqlEvent(event)
+	if err != nil {
+		return err
+	}
+
+	// Ensure that the sequence is derived and used atomically by using a transaction.
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Serialize writers of all replicas while readers are still allowed.
+	_, err = tx.ExecContext(ctx, "LOCK TABLE event_log IN SHARE ROW EXCLUSIVE MODE")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO event_log (sequence, event_type, key, value, header) SELECT COALESCE(MAX(sequence), 0) + 1, $1, $2, $3, $4 FROM event_log", event.EventType, encodedKey, encodedValue, encodedHeader)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,41 @@
+package consistency_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/consistency"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func Test_PostgresLogger_With_ReadEventsFrom_Should_SkipEarlierEvents(t *testing.T) {
+	// Arrange
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set, skipping PostgreSQL tests")
+	}
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	ctx := context.Background()
+	_, _ = db.ExecContext(ctx, "DROP TABLE IF EXISTS event_log;")
+	logger := consistency.NewPostgresLogger[string, int](db)
+	if err := logger.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_ = logger.WritePutContext(ctx, "key1", 1)
+	_ = logger.WritePutContext(ctx, "key2", 2)
+
+	// Act
+	events, err := collectEvents(logger.ReadEventsFrom(ctx, 2))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "events length must be 1", len(events), 1)
+	assert.That(t, "sequence must be 2", events[0].Sequence, uint64(2))
+}
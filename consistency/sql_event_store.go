@@ -0,0 +1,153 @@
+package consistency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// sqlPageSize is the number of events read by a single query of a SQL event store.
+const sqlPageSize = 100
+
+// SqlEventStoreOptions configures a SqliteEventStore or PostgresEventStore.
+type SqlEventStoreOptions struct {
+	// PollInterval is the interval in which SubscribeToAll reads the events appended by other processes. Default: 1s.
+	PollInterval time.Duration
+
+	// Upcasters migrate events of older schema versions on read. Default: none.
+	Upcasters Upcasters
+}
+
+// sqlStreamQueries contains the dialect specific statements used to append events to a stream.
+type sqlStreamQueries struct {
+	version  string // Selects the version of the stream with the given ID.
+	position string // Selects the last position of the store.
+	insert   string // Inserts the position, stream ID, version, event type, value and header of an event.
+}
+
+// appendSqlStream appends the events to a stream within the transaction if its current
+// version equals the expected version.
+func appendSqlStream[K, V any](ctx context.Context, tx *sql.Tx, queries sqlStreamQueries, streamID K, expectedVersion uint64, events []Event[K, V]) error {
+	encodedStreamID, err := encodeStreamID(streamID)
+	if err != nil {
+		return err
+	}
+
+	// Compare the current with the expected version.
+	var version uint64
+	if err := tx.QueryRowContext(ctx, queries.version, encodedStreamID).Scan(&version); err != nil {
+		return err
+	}
+	if expectedVersion != ExpectedVersionAny && expectedVersion != version {
+		return ErrWrongExpectedVersion
+	}
+
+	// Insert the events following the last position.
+	var position uint64
+	if err := tx.QueryRowContext(ctx, queries.position).Scan(&position); err != nil {
+		return err
+	}
+	for _, event := range prepareStreamEvents(streamID, version, position, events) {
+		_, encodedValue, encodedHeader, err := encodeSqlEvent(event)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, queries.insert, event.Sequence, encodedStreamID, event.Version, event.EventType, encodedValue, encodedHeader)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeStreamID encodes the stream ID as JSON like the keys of the event log.
+func encodeStreamID[K any](streamID K) (string, error) {
+	encoded, err := json.Marshal(streamID)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// failedEvents returns closed channels reporting the error.
+func failedEvents[K, V any](err error) (<-chan Event[K, V], <-chan error) {
+	errorCh := make(chan error, 1)
+	eventCh := make(chan Event[K, V])
+	errorCh <- err
+	close(errorCh)
+	close(eventCh)
+	return eventCh, errorCh
+}
+
+// sqlPollInterval returns the poll interval of the options or its default.
+func sqlPollInterval(options SqlEventStoreOptions) time.Duration {
+	if options.PollInterval <= 0 {
+		return time.Second
+	}
+	return options.PollInterval
+}
+
+// querySqlEvents runs the query and decodes all resulting rows, which are closed before returning.
+func querySqlEvents[K, V any](ctx context.Context, db *sql.DB, query string, upcasters Upcasters, args ...any) ([]Event[K, V], error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var events []Event[K, V]
+	for rows.Next() {
+		event, err := scanSqlEvent[K, V](rows, upcasters)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// readSqlEventPages streams the events of the query in pages of sqlPageSize. The rows of a page
+// are closed before its events are sent, so that slow consumers do not keep a read transaction
+// open, which blocks writers. The query takes the cursor and the page size as its last arguments,
+// and next returns the cursor of the page following an event.
+func readSqlEventPages[K, V any](ctx context.Context, db *sql.DB, query string, upcasters Upcasters, cursor uint64, next func(Event[K, V]) uint64, args ...any) (<-chan Event[K, V], <-chan error) {
+	errorCh := make(chan error, 1)
+	eventCh := make(chan Event[K, V], sqlPageSize)
+	// Launch a goroutine to handle the queries asynchronously.
+	go func() {
+		defer close(errorCh)
+		defer close(eventCh)
+		for {
+			page, err := querySqlEvents[K, V](ctx, db, query, upcasters, append(slices.Clone(args), cursor, sqlPageSize)...)
+			if err != nil {
+				errorCh <- err
+				return
+			}
+			for _, event := range page {
+				select {
+				case eventCh <- event:
+				case <-ctx.Done():
+					errorCh <- ctx.Err()
+					return
+				}
+			}
+			if len(page) < sqlPageSize {
+				return
+			}
+			cursor = next(page[len(page)-1])
+		}
+	}()
+	return eventCh, errorCh
+}
+
+// nextPosition returns the position following the event.
+func nextPosition[K, V any](event Event[K, V]) uint64 {
+	return event.Sequence + 1
+}
+
+// nextVersion returns the stream version following the event.
+func nextVersion[K, V any](event Event[K, V]) uint64 {
+	return event.Version + 1
+}
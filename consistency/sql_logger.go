@@ -0,0 +1,118 @@
+package consistency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// SqlLoggerOptions configures a SqliteLogger or PostgresLogger.
+type SqlLoggerOptions struct {
+	// Upcasters migrate events of older schema versions on read. Default: none.
+	Upcasters Upcasters
+}
+
+// sqlEventHeader contains the metadata of an event stored in the header column.
+type sqlEventHeader struct {
+	ID            string            `json:"id,omitempty"`
+	Name          string            `json:"name,omitempty"`
+	Version       uint64            `json:"version,omitempty"`
+	SchemaVersion int               `json:"schema_version,omitempty"`
+	Timestamp     time.Time         `json:"timestamp,omitzero"`
+	Actor         string            `json:"actor,omitempty"`
+	CausationID   string            `json:"causation_id,omitempty"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+}
+
+// encodeSqlEvent encodes the key, value and header of an event as JSON strings.
+func encodeSqlEvent[K, V any](event Event[K, V]) (string, string, string, error) {
+	encodedKey, err := json.Marshal(event.Key)
+	if err != nil {
+		return "", "", "", err
+	}
+	encodedValue, err := json.Marshal(event.Value)
+	if err != nil {
+		return "", "", "", err
+	}
+	encodedHeader, err := json.Marshal(sqlEventHeader{
+		ID:            event.ID,
+		Name:          event.Name,
+		Version:       event.Version,
+		SchemaVersion: event.SchemaVersion,
+		Timestamp:     event.Timestamp,
+		Actor:         event.Actor,
+		CausationID:   event.CausationID,
+		CorrelationID: event.CorrelationID,
+		Metadata:      event.Metadata,
+	})
+	if err != nil {
+		return "", "", "", err
+	}
+	return string(encodedKey), string(encodedValue), string(encodedHeader), nil
+}
+
+// readSqlEvents runs the query with the given arguments and streams the resulting
+// rows as events. The query must select the sequence, event type, key, value and
+// header columns ordered by sequence.
+func readSqlEvents[K, V any](ctx context.Context, db *sql.DB, query string, upcasters Upcasters, args ...any) (<-chan Event[K, V], <-chan error) {
+	errorCh := make(chan error, 1)
+	eventCh := make(chan Event[K, V], 100)
+	// Launch a goroutine to handle the query asynchronously.
+	go func() {
+		defer close(errorCh)
+		defer close(eventCh)
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			errorCh <- err
+			return
+		}
+		defer func() { _ = rows.Close() }()
+		// Decode each row into an event.
+		for rows.Next() {
+			event, err := scanSqlEvent[K, V](rows, upcasters)
+			if err != nil {
+				errorCh <- err
+				return
+			}
+			// Send the decoded event to the event channel.
+			select {
+			case eventCh <- event:
+			case <-ctx.Done():
+				errorCh <- ctx.Err()
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			errorCh <- err
+		}
+	}()
+	return eventCh, errorCh
+}
+
+// scanSqlEvent decodes the current row into an event. The row must contain the
+// sequence, event type, key, value and header columns.
+func scanSqlEvent[K, V any](rows *sql.Rows, upcasters Upcasters) (Event[K, V], error) {
+	var raw RawEvent
+	var key, value, header string
+	if err := rows.Scan(&raw.Sequence, &raw.EventType, &key, &value, &header); err != nil {
+		return Event[K, V]{}, err
+	}
+	var h sqlEventHeader
+	if err := json.Unmarshal([]byte(header), &h); err != nil {
+		return Event[K, V]{}, err
+	}
+	raw.Key = json.RawMessage(key)
+	raw.Value = json.RawMessage(value)
+	raw.ID = h.ID
+	raw.Name = h.Name
+	raw.Version = h.Version
+	raw.SchemaVersion = h.SchemaVersion
+	raw.Timestamp = h.Timestamp
+	raw.Actor = h.Actor
+	raw.CausationID = h.CausationID
+	raw.CorrelationID = h.CorrelationID
+	raw.Metadata = h.Metadata
+	return decodeEvent[K, V](raw, upcasters)
+}
@@ -0,0 +1,105 @@
+package consistency
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// sqliteStreamQueries are the statements used by SqliteEventStore to append events.
+var sqliteStreamQueries = sqlStreamQueries{
+	version:  "SELECT COALESCE(MAX(version), 0) FROM event_store WHERE stream_id = ?",
+	position: "SELECT COALESCE(MAX(position), 0) FROM event_store",
+	insert:   "INSERT INTO event_store (position, stream_id, version, event_type, value, header) VALUES (?, ?, ?, ?, ?, ?)",
+}
+
+// SqliteEventStore is a SQLite-based implementation of the EventStore interface.
+// It appends events to the event_store table with a gap-free position.
+// Appends of other connections or processes are rejected by the unique stream
+// version, and their events are received by SubscribeToAll after the poll interval.
+//
+// Open the database with immediate transactions and a busy timeout, e.g.
+// "store.sqlite?_txlock=immediate&_pragma=busy_timeout(5000)", so that concurrent
+// appends wait for each other instead of failing with SQLITE_BUSY.
+type SqliteEventStore[K, V any] struct {
+	db       *sql.DB
+	notifier *storeNotifier
+	options  SqlEventStoreOptions
+	mutex    sync.Mutex // Mutex to serialize writers of this process.
+}
+
+// NewSqliteEventStore creates a new instance of SqliteEventStore.
+func NewSqliteEventStore[K, V any](db *sql.DB) *SqliteEventStore[K, V] {
+	return NewSqliteEventStoreWithOptions[K, V](db, SqlEventStoreOptions{})
+}
+
+// NewSqliteEventStoreWithOptions creates a new instance of SqliteEventStore with the given options.
+func NewSqliteEventStoreWithOptions[K, V any](db *sql.DB, options SqlEventStoreOptions) *SqliteEventStore[K, V] {
+	options.PollInterval = sqlPollInterval(options)
+	return &SqliteEventStore[K, V]{
+		db:       db,
+		notifier: newStoreNotifier(),
+		options:  options,
+	}
+}
+
+// AppendToStream appends events to a stream if its current version equals the expected version.
+// The events are committed within a single transaction.
+func (a *SqliteEventStore[K, V]) AppendToStream(ctx context.Context, streamID K, expectedVersion uint64, events ...Event[K, V]) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Ensure that the table is not modified concurrently.
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	// Ensure that the version and position are derived and used atomically by using a transaction.
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := appendSqlStream(ctx, tx, sqliteStreamQueries, streamID, expectedVersion, events); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	a.notifier.notify()
+	return nil
+}
+
+// Init initializes the table. Existing events are kept.
+func (a *SqliteEventStore[K, V]) Init(ctx context.Context) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Create the table.
+	_, err := a.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS event_store (position INTEGER PRIMARY KEY, stream_id TEXT NOT NULL, version INTEGER NOT NULL, event_type INTEGER NOT NULL, value TEXT NOT NULL, header TEXT NOT NULL DEFAULT '{}', UNIQUE (stream_id, version));")
+	return err
+}
+
+// ReadAll reads the events of all streams with a position greater than or equal to the given position.
+func (a *SqliteEventStore[K, V]) ReadAll(ctx context.Context, position uint64) (<-chan Event[K, V], <-chan error) {
+	return readSqlEventPages(ctx, a.db, "SELECT position, event_type, stream_id, value, header FROM event_store WHERE position >= ? ORDER BY position LIMIT ?", a.options.Upcasters, position, nextPosition[K, V])
+}
+
+// ReadStream reads the events of a stream with a version greater than or equal to the given version.
+func (a *SqliteEventStore[K, V]) ReadStream(ctx context.Context, streamID K, version uint64) (<-chan Event[K, V], <-chan error) {
+	encodedStreamID, err := encodeStreamID(streamID)
+	if err != nil {
+		return failedEvents[K, V](err)
+	}
+	return readSqlEventPages(ctx, a.db, "SELECT position, event_type, stream_id, value, header FROM event_store WHERE stream_id = ? AND version >= ? ORDER BY position LIMIT ?", a.options.Upcasters, version, nextVersion[K, V], encodedStreamID)
+}
+
+// SubscribeToAll reads the events of all streams starting at the given position and then
+// waits for appended events until the context is canceled.
+func (a *SqliteEventStore[K, V]) SubscribeToAll(ctx context.Context, position uint64) (<-chan Event[K, V], <-chan error) {
+	return subscribeToAll(ctx, position, a.ReadAll, a.notifier, a.options.PollInterval)
+}
@@ -0,0 +1,111 @@
+package consistency_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/consistency"
+	_ "modernc.org/sqlite"
+)
+
+func newSqliteEventStore(t *testing.T) (*consistency.SqliteEventStore[string, int], *sql.DB) {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "store.sqlite")+"?_txlock=immediate&_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	store := consistency.NewSqliteEventStoreWithOptions[string, int](db, consistency.SqlEventStoreOptions{PollInterval: 10 * time.Millisecond})
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return store, db
+}
+
+func Test_SqliteEventStore_With_ExpectedVersion_Should_AppendToStream(t *testing.T) {
+	// Arrange
+	store, _ := newSqliteEventStore(t)
+	ctx := context.Background()
+
+	// Act
+	err := store.AppendToStream(ctx, "order-1", 0, consistency.Event[string, int]{Value: 1, Name: "OrderPlaced", EventType: consistency.EventTypeCustom})
+	err2 := store.AppendToStream(ctx, "order-2", 0, consistency.Event[string, int]{Value: 2})
+	err3 := store.AppendToStream(ctx, "order-1", 1, consistency.Event[string, int]{Value: 3})
+	events, err4 := collectEvents(store.ReadStream(ctx, "order-1", 0))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "err3 must be nil", err3, nil)
+	assert.That(t, "err4 must be nil", err4, nil)
+	assert.That(t, "events length must be 2", len(events), 2)
+	assert.That(t, "first name must be correct", events[0].Name, "OrderPlaced")
+	assert.That(t, "first key must be the stream id", events[0].Key, "order-1")
+	assert.That(t, "second version must be 2", events[1].Version, uint64(2))
+	assert.That(t, "second position must be 3", events[1].Sequence, uint64(3))
+	assert.That(t, "second value must be 3", events[1].Value, 3)
+}
+
+func Test_SqliteEventStore_With_WrongExpectedVersion_Should_NotAppend(t *testing.T) {
+	// Arrange
+	store, _ := newSqliteEventStore(t)
+	ctx := context.Background()
+	_ = store.AppendToStream(ctx, "order-1", 0, consistency.Event[string, int]{Value: 1})
+
+	// Act
+	err := store.AppendToStream(ctx, "order-1", 0, consistency.Event[string, int]{Value: 2}, consistency.Event[string, int]{Value: 3})
+	events, err2 := collectEvents(store.ReadAll(ctx, 0))
+
+	// Assert
+	assert.That(t, "err must be ErrWrongExpectedVersion", err, consistency.ErrWrongExpectedVersion)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "events length must be 1", len(events), 1)
+}
+
+func Test_SqliteEventStore_With_OtherProcess_Should_PollAppendedEvents(t *testing.T) {
+	// Arrange
+	store, db := newSqliteEventStore(t)
+	other := consistency.NewSqliteEventStore[string, int](db)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	eventCh, errorCh := store.SubscribeToAll(ctx, 0)
+
+	// Act
+	_ = other.AppendToStream(ctx, "order-1", 0, consistency.Event[string, int]{Value: 1})
+	first := <-eventCh
+	_ = store.AppendToStream(ctx, "order-1", 1, consistency.Event[string, int]{Value: 2})
+	second := <-eventCh
+	cancel()
+
+	// Assert
+	assert.That(t, "first value must be 1", first.Value, 1)
+	assert.That(t, "second version must be 2", second.Version, uint64(2))
+	assert.That(t, "err must be nil", <-errorCh, nil)
+}
+
+func Test_SqliteEventStore_With_SeveralPages_Should_ReadAllEvents(t *testing.T) {
+	// Arrange
+	store, _ := newSqliteEventStore(t)
+	ctx := context.Background()
+	events := make([]consistency.Event[string, int], 250)
+	for i := range events {
+		events[i] = consistency.Event[string, int]{Value: i}
+	}
+	_ = store.AppendToStream(ctx, "order-1", 0, events...)
+
+	// Act
+	all, err := collectEvents(store.ReadAll(ctx, 0))
+	stream, err2 := collectEvents(store.ReadStream(ctx, "order-1", 101))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "all length must be 250", len(all), 250)
+	assert.That(t, "last value must be 249", all[249].Value, 249)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "stream length must be 150", len(stream), 150)
+	assert.That(t, "first version must be 101", stream[0].Version, uint64(101))
+}
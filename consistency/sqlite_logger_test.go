@@ -0,0 +1,164 @@
+package consistency_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/consistency"
+	_ "modernc.org/sqlite"
+)
+
+// openSqlite opens a SQLite database in a temporary directory and fails the test on errors.
+func openSqlite(t *testing.T, name string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func newSqliteLogger(t *testing.T) (*consistency.SqliteLogger[string, int], *sql.DB) {
+	t.Helper()
+	db := openSqlite(t, "logger.sqlite")
+	logger := consistency.NewSqliteLogger[string, int](db)
+	if err := logger.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return logger, db
+}
+
+func collectEvents[K, V any](eventCh <-chan consistency.Event[K, V], errorCh <-chan error) ([]consistency.Event[K, V], error) {
+	var events []consistency.Event[K, V]
+	for event := range eventCh {
+		events = append(events, event)
+	}
+	return events, <-errorCh
+}
+
+func Test_SqliteLogger_With_ClosedLogger_Should_ReturnErrLoggerClosed(t *testing.T) {
+	// Arrange
+	logger, _ := newSqliteLogger(t)
+	_ = logger.Close()
+
+	// Act
+	err := logger.WritePutContext(context.Background(), "key", 1)
+
+	// Assert
+	assert.That(t, "err must be correct", err, consistency.ErrLoggerClosed)
+}
+
+func Test_SqliteLogger_With_ConcurrentWriters_Should_HaveGapFreeSequence(t *testing.T) {
+	// Arrange
+	logger, _ := newSqliteLogger(t)
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+
+	// Act
+	for i := range 20 {
+		wg.Go(func() { errs <- logger.WritePutContext(ctx, "key", i) })
+		wg.Go(func() { errs <- logger.WriteDeleteContext(ctx, "key") })
+	}
+	wg.Wait()
+	close(errs)
+	events, err := collectEvents(logger.ReadEvents())
+
+	// Assert
+	for err := range errs {
+		assert.That(t, "write err must be nil", err, nil)
+	}
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "events length must be 40", len(events), 40)
+	for i := range events {
+		assert.That(t, "sequence must be gap-free", events[i].Sequence, uint64(i)+1) //nolint:gosec // test code with controlled loop bounds
+	}
+}
+
+func Test_SqliteLogger_With_SharedTable_Should_ContinueSequence(t *testing.T) {
+	// Arrange
+	logger, db := newSqliteLogger(t)
+	other := consistency.NewSqliteLogger[string, int](db)
+	ctx := context.Background()
+
+	// Act
+	err := logger.WritePutContext(ctx, "key1", 1)
+	err2 := other.WritePutContext(ctx, "key2", 2)
+	events, err3 := collectEvents(other.ReadEvents())
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "err3 must be nil", err3, nil)
+	assert.That(t, "events length must be 2", len(events), 2)
+	assert.That(t, "sequence must be continued", events[1].Sequence, uint64(2))
+}
+
+func Test_SqliteLogger_With_ReadEventsFrom_Should_SkipEarlierEvents(t *testing.T) {
+	// Arrange
+	logger, _ := newSqliteLogger(t)
+	logger.WritePut("key1", 1)
+	logger.WritePut("key2", 2)
+	logger.WriteDelete("key1")
+
+	// Act
+	events, err := collectEvents(logger.ReadEventsFrom(context.Background(), 2))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "events length must be 2", len(events), 2)
+	assert.That(t, "first key must be correct", events[0].Key, "key2")
+	assert.That(t, "first value must be correct", events[0].Value, 2)
+	assert.That(t, "second event type must be delete", events[1].EventType, consistency.EventTypeDelete)
+	assert.That(t, "close must not return an error", logger.Close(), nil)
+}
+
+func Test_SqliteLogger_With_UninitializedTable_Should_ReportError(t *testing.T) {
+	// Arrange
+	db := openSqlite(t, "logger.sqlite")
+	logger := consistency.NewSqliteLogger[string, int](db)
+
+	// Act
+	logger.WritePut("key", 1)
+
+	// Assert
+	err := <-logger.Error()
+	assert.That(t, "err must not be nil", err != nil, true)
+}
+
+func Test_SqliteLogger_With_WriteEvent_Should_PersistMetadata(t *testing.T) {
+	// Arrange
+	logger, _ := newSqliteLogger(t)
+	ctx := context.Background()
+
+	// Act
+	err := logger.WriteEvent(ctx, consistency.Event[string, int]{
+		Key:           "order-1",
+		Value:         42,
+		EventType:     consistency.EventTypeCustom,
+		Name:          "OrderPlaced",
+		ID:            "event-1",
+		Version:       3,
+		CausationID:   "command-1",
+		CorrelationID: "saga-1",
+		Metadata:      map[string]string{"tenant": "acme"},
+	})
+	events, err2 := collectEvents(logger.ReadEvents())
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "events length must be 1", len(events), 1)
+	assert.That(t, "id must be kept", events[0].ID, "event-1")
+	assert.That(t, "name must be correct", events[0].Name, "OrderPlaced")
+	assert.That(t, "version must be correct", events[0].Version, uint64(3))
+	assert.That(t, "causation id must be correct", events[0].CausationID, "command-1")
+	assert.That(t, "correlation id must be correct", events[0].CorrelationID, "saga-1")
+	assert.That(t, "metadata must be correct", events[0].Metadata["tenant"], "acme")
+	assert.That(t, "timestamp must be generated", events[0].Timestamp.IsZero(), false)
+}
@@ -0,0 +1,18 @@
+package consistency
+
+import "time"
+
+// SyncPolicy defines when written events are flushed to stable storage.
+type SyncPolicy byte
+
+const (
+	// SyncPolicyNever leaves flushing to the operating system.
+	SyncPolicyNever SyncPolicy = iota
+	// SyncPolicyAlways flushes after every group commit.
+	SyncPolicyAlways
+	// SyncPolicyBatch flushes at most once per sync interval.
+	SyncPolicyBatch
+)
+
+// DefaultSyncInterval is used by SyncPolicyBatch if no interval is configured.
+const DefaultSyncInterval = 10 * time.Millisecond
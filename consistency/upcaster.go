@@ -0,0 +1,83 @@
+package consistency
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+var (
+	ErrUpcasterVersion = errors.New("upcaster must increase the schema version")
+)
+
+// Upcaster migrates a raw event from an older schema version to a newer one.
+// It must return the event with an increased schema version.
+type Upcaster func(event RawEvent) (RawEvent, error)
+
+// Upcasters maps a schema version to the upcaster that migrates events of
+// this version. Upcasters are applied repeatedly on read until no upcaster
+// is registered for the schema version of the event.
+type Upcasters map[int]Upcaster
+
+// upcast applies the registered upcasters to the event.
+func (a Upcasters) upcast(event RawEvent) (RawEvent, error) {
+	for {
+		upcaster, ok := a[event.SchemaVersion]
+		if !ok {
+			return event, nil
+		}
+		migrated, err := upcaster(event)
+		if err != nil {
+			return event, err
+		}
+		if migrated.SchemaVersion <= event.SchemaVersion {
+			return event, ErrUpcasterVersion
+		}
+		event = migrated
+	}
+}
+
+// decodeEvent upcasts a raw event and decodes its key and value.
+func decodeEvent[K, V any](raw RawEvent, upcasters Upcasters) (Event[K, V], error) {
+	raw, err := upcasters.upcast(raw)
+	if err != nil {
+		return Event[K, V]{}, err
+	}
+	event := Event[K, V]{
+		Sequence:      raw.Sequence,
+		EventType:     raw.EventType,
+		ID:            raw.ID,
+		Name:          raw.Name,
+		Version:       raw.Version,
+		SchemaVersion: raw.SchemaVersion,
+		Timestamp:     raw.Timestamp,
+		Actor:         raw.Actor,
+		CausationID:   raw.CausationID,
+		CorrelationID: raw.CorrelationID,
+		Metadata:      raw.Metadata,
+	}
+	if len(raw.Key) > 0 {
+		if err := json.Unmarshal(raw.Key, &event.Key); err != nil {
+			return event, err
+		}
+	}
+	if len(raw.Value) > 0 {
+		if err := json.Unmarshal(raw.Value, &event.Value); err != nil {
+			return event, err
+		}
+	}
+	return event, nil
+}
+
+// unmarshalEvent decodes a JSON-encoded event and applies the upcasters if any.
+func unmarshalEvent[K, V any](data []byte, upcasters Upcasters) (Event[K, V], error) {
+	var event Event[K, V]
+	if len(upcasters) == 0 {
+		err := json.Unmarshal(data, &event)
+		return event, err
+	}
+	var raw RawEvent
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return event, err
+	}
+	return decodeEvent[K, V](raw, upcasters)
+}
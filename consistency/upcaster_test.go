@@ -0,0 +1,102 @@
+package consistency_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/consistency"
+)
+
+type userV2 struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+// upcastUserV1 splits the name of the first schema version into first and last name.
+func upcastUserV1(event consistency.RawEvent) (consistency.RawEvent, error) {
+	var name string
+	if err := json.Unmarshal(event.Value, &name); err != nil {
+		return event, err
+	}
+	first, last, _ := strings.Cut(name, " ")
+	event.Value, _ = json.Marshal(userV2{FirstName: first, LastName: last})
+	event.SchemaVersion = 2
+	return event, nil
+}
+
+func Test_Upcasters_With_LegacyJsonLog_Should_MigrateOnRead(t *testing.T) {
+	// Arrange
+	logFile := "upcaster_legacy.log"
+	defer func() { _ = os.Remove(logFile) }()
+	legacy := `{"key":"user-1","value":"Jane Doe","sequence":1,"event_type":1}` + "\n"
+	_ = os.WriteFile(logFile, []byte(legacy), 0600)
+	logger := consistency.NewJsonFileLoggerWithOptions[string, userV2](logFile, consistency.FileLoggerOptions{
+		Upcasters: consistency.Upcasters{0: upcastUserV1},
+	})
+	_ = logger.WriteEvent(context.Background(), consistency.Event[string, userV2]{
+		Key:           "user-2",
+		Value:         userV2{FirstName: "John", LastName: "Roe"},
+		EventType:     consistency.EventTypeCustom,
+		Name:          "UserRegistered",
+		SchemaVersion: 2,
+		Actor:         "admin",
+		CorrelationID: "correlation-1",
+		Metadata:      map[string]string{"source": "test"},
+	})
+	_ = logger.Close()
+
+	// Act
+	events, err := collectEvents(logger.ReadEvents())
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "events length must be 2", len(events), 2)
+	assert.That(t, "legacy value must be migrated", events[0].Value, userV2{FirstName: "Jane", LastName: "Doe"})
+	assert.That(t, "legacy schema version must be 2", events[0].SchemaVersion, 2)
+	assert.That(t, "sequence must be continued", events[1].Sequence, uint64(2))
+	assert.That(t, "name must be correct", events[1].Name, "UserRegistered")
+	assert.That(t, "actor must be correct", events[1].Actor, "admin")
+	assert.That(t, "metadata must be correct", events[1].Metadata["source"], "test")
+	assert.That(t, "id must be generated", events[1].ID != "", true)
+	assert.That(t, "timestamp must be generated", events[1].Timestamp.IsZero(), false)
+}
+
+func Test_Upcasters_With_FailingUpcaster_Should_ReturnError(t *testing.T) {
+	// Arrange
+	logger, db := newSqliteLogger(t)
+	_ = logger.WritePutContext(context.Background(), "key", 1)
+	errUpcast := errors.New("upcast failed")
+	upcasting := consistency.NewSqliteLoggerWithOptions[string, int](db, consistency.SqlLoggerOptions{
+		Upcasters: consistency.Upcasters{0: func(event consistency.RawEvent) (consistency.RawEvent, error) {
+			return event, errUpcast
+		}},
+	})
+
+	// Act
+	_, err := collectEvents(upcasting.ReadEvents())
+
+	// Assert
+	assert.That(t, "err must be correct", err, errUpcast)
+}
+
+func Test_Upcasters_With_UnchangedVersion_Should_ReturnError(t *testing.T) {
+	// Arrange
+	logger, db := newSqliteLogger(t)
+	_ = logger.WritePutContext(context.Background(), "key", 1)
+	upcasting := consistency.NewSqliteLoggerWithOptions[string, int](db, consistency.SqlLoggerOptions{
+		Upcasters: consistency.Upcasters{0: func(event consistency.RawEvent) (consistency.RawEvent, error) {
+			return event, nil
+		}},
+	})
+
+	// Act
+	_, err := collectEvents(upcasting.ReadEvents())
+
+	// Assert
+	assert.That(t, "err must be correct", err, consistency.ErrUpcasterVersion)
+}
@@ -0,0 +1,107 @@
+package event
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+)
+
+var (
+	ErrNoEventHandler = errors.New("no event handler registered")
+)
+
+// Aggregate is a domain object whose state is derived from its events.
+// It is implemented by embedding AggregateRoot.
+type Aggregate interface {
+	Root() *AggregateRoot
+}
+
+// AggregateRoot records the events of an aggregate and applies them to its state
+// by using the handlers registered with On. It is embedded into aggregates:
+//
+//	type Order struct {
+//		event.AggregateRoot
+//		Status string
+//	}
+//
+//	func NewOrder() *Order {
+//		o := &Order{}
+//		event.On(&o.AggregateRoot, func(e OrderPlaced) { o.Status = "placed" })
+//		return o
+//	}
+type AggregateRoot struct {
+	handlers map[reflect.Type]func(e Event)
+	id       string
+	pending  []Event
+	version  uint64
+}
+
+// On registers the handler applying events of type T to the state of an aggregate.
+// Events of a value or a pointer of the handled type are converted to T.
+func On[T Event](root *AggregateRoot, handler func(e T)) {
+	if root.handlers == nil {
+		root.handlers = make(map[reflect.Type]func(e Event))
+	}
+	root.handlers[baseType(reflect.TypeFor[T]())] = func(e Event) {
+		handler(convertEvent[T](e))
+	}
+}
+
+// Apply applies an event to the state of the aggregate and increments its version.
+// It is used to replay persisted events, while Record is used for new events.
+func (a *AggregateRoot) Apply(e Event) error {
+	if e == nil {
+		return ErrNilEvent
+	}
+	handler, ok := a.handlers[baseType(reflect.TypeOf(e))]
+	if !ok {
+		return ErrNoEventHandler
+	}
+	handler(e)
+	a.version++
+	return nil
+}
+
+// ClearPendingEvents removes the pending events after they have been persisted.
+func (a *AggregateRoot) ClearPendingEvents() {
+	a.pending = nil
+}
+
+// ID returns the ID of the aggregate.
+func (a *AggregateRoot) ID() string {
+	return a.id
+}
+
+// PendingEvents returns a copy of the recorded events, which are not persisted yet.
+func (a *AggregateRoot) PendingEvents() []Event {
+	return slices.Clone(a.pending)
+}
+
+// Record applies a new event to the state of the aggregate and adds it to the pending events.
+func (a *AggregateRoot) Record(e Event) error {
+	if err := a.Apply(e); err != nil {
+		return err
+	}
+	a.pending = append(a.pending, e)
+	return nil
+}
+
+// Root returns the aggregate root, so that embedding it implements Aggregate.
+func (a *AggregateRoot) Root() *AggregateRoot {
+	return a
+}
+
+// SetID sets the ID of the aggregate.
+func (a *AggregateRoot) SetID(id string) {
+	a.id = id
+}
+
+// Version returns the number of events applied to the aggregate, including the pending events.
+func (a *AggregateRoot) Version() uint64 {
+	return a.version
+}
+
+// PersistedVersion returns the version of the last persisted event of the aggregate.
+func (a *AggregateRoot) PersistedVersion() uint64 {
+	return a.version - uint64(len(a.pending))
+}
@@ -0,0 +1,171 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+
+	"github.com/andygeiss/cloud-native-utils/consistency"
+)
+
+// LoggerAggregateStore is an AggregateStore on top of a consistency.Logger.
+// Each append is written as a single custom log event with the aggregate ID as key,
+// the resulting aggregate version as version and the envelopes of the events as value,
+// so that an append is stored completely or not at all. Since the log cannot check
+// versions atomically, appends are serialized within the process, and the log must
+// not be written by other processes.
+//
+// The positions of the log events are indexed per aggregate, so that loading an
+// aggregate reads the log from its first event after the requested version only.
+type LoggerAggregateStore struct {
+	logger    consistency.Logger[string, json.RawMessage]
+	registry  *Registry
+	positions map[string][]loggerPosition // Positions of the log events per aggregate.
+	indexed   uint64                      // Sequence number of the last indexed log event.
+	versions  map[string]uint64           // Current versions, nil until read from the log.
+	mutex     sync.Mutex
+}
+
+// loggerPosition is the sequence number of a log event and the aggregate version it ends with.
+type loggerPosition struct {
+	sequence uint64
+	version  uint64
+}
+
+// NewLoggerAggregateStore creates a new LoggerAggregateStore instance.
+// The registry encodes and decodes the events.
+func NewLoggerAggregateStore(logger consistency.Logger[string, json.RawMessage], registry *Registry) *LoggerAggregateStore {
+	return &LoggerAggregateStore{logger: logger, registry: registry, positions: make(map[string][]loggerPosition)}
+}
+
+// AppendEvents appends events to an aggregate if its current version equals the expected version.
+func (a *LoggerAggregateStore) AppendEvents(ctx context.Context, aggregateID string, expectedVersion uint64, events []Event) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	// Read the versions of all aggregates from the log once.
+	if a.versions == nil {
+		if err := a.index(ctx); err != nil {
+			return err
+		}
+		versions := make(map[string]uint64, len(a.positions))
+		for id, positions := range a.positions {
+			versions[id] = positions[len(positions)-1].version
+		}
+		a.versions = versions
+	}
+	if a.versions[aggregateID] != expectedVersion {
+		return ErrConcurrencyConflict
+	}
+
+	// Encode all events into a single log event to avoid partial appends.
+	envelopes := make([]Envelope, 0, len(events))
+	for _, e := range events {
+		envelope, err := a.registry.Wrap(e)
+		if err != nil {
+			return err
+		}
+		envelopes = append(envelopes, envelope)
+	}
+	value, err := json.Marshal(envelopes)
+	if err != nil {
+		return err
+	}
+	version := expectedVersion + uint64(len(events))
+	err = a.logger.WriteEvent(ctx, consistency.Event[string, json.RawMessage]{
+		Key:       aggregateID,
+		Value:     value,
+		EventType: consistency.EventTypeCustom,
+		Version:   version,
+	})
+	if err != nil {
+		// The event may have been written nevertheless, e.g. if waiting timed out.
+		// Read the versions from the log again before the next append.
+		a.versions = nil
+		return err
+	}
+	a.versions[aggregateID] = version
+	return nil
+}
+
+// LoadEvents returns the events of an aggregate with a version greater than the given version.
+func (a *LoggerAggregateStore) LoadEvents(ctx context.Context, aggregateID string, afterVersion uint64) ([]Event, error) {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	a.mutex.Lock()
+	err := a.index(ctx)
+	positions := a.positions[aggregateID]
+	a.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the log from the first log event ending after the requested version.
+	first := slices.IndexFunc(positions, func(p loggerPosition) bool { return p.version > afterVersion })
+	if first < 0 {
+		return nil, nil
+	}
+	last := positions[len(positions)-1].sequence
+	var events []Event
+	err = a.read(ctx, positions[first].sequence, last, func(e consistency.Event[string, json.RawMessage]) error {
+		if e.Key != aggregateID || e.Version <= afterVersion {
+			return nil
+		}
+		var envelopes []Envelope
+		if err := json.Unmarshal(e.Value, &envelopes); err != nil {
+			return err
+		}
+		// The version of the log event is the version of its last event.
+		start := e.Version - uint64(len(envelopes)) + 1
+		for i, envelope := range envelopes {
+			if start+uint64(i) <= afterVersion { //nolint:gosec // index is never negative
+				continue
+			}
+			decoded, err := a.registry.Unwrap(envelope)
+			if err != nil {
+				return err
+			}
+			events = append(events, decoded)
+		}
+		return nil
+	})
+	return events, err
+}
+
+// index adds the positions of the log events written since the last call.
+func (a *LoggerAggregateStore) index(ctx context.Context) error {
+	return a.read(ctx, a.indexed+1, 0, func(e consistency.Event[string, json.RawMessage]) error {
+		a.positions[e.Key] = append(a.positions[e.Key], loggerPosition{sequence: e.Sequence, version: e.Version})
+		a.indexed = e.Sequence
+		return nil
+	})
+}
+
+// read calls fn for each custom event of the log from the given sequence number
+// up to the last sequence number, or up to the end of the log if it is zero.
+func (a *LoggerAggregateStore) read(ctx context.Context, from, last uint64, fn func(e consistency.Event[string, json.RawMessage]) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	eventCh, errorCh := a.logger.ReadEventsFrom(ctx, from)
+	for e := range eventCh {
+		if last > 0 && e.Sequence > last {
+			return nil
+		}
+		if e.EventType != consistency.EventTypeCustom {
+			continue
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return <-errorCh
+}
@@ -0,0 +1,158 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/andygeiss/cloud-native-utils/resource"
+)
+
+var (
+	ErrAggregateNotFound  = errors.New("aggregate not found")
+	ErrMissingAggregateID = errors.New("missing aggregate id")
+)
+
+// Snapshot is the JSON encoded state of an aggregate at a version.
+type Snapshot struct {
+	AggregateID string          `json:"aggregate_id"`
+	Version     uint64          `json:"version"`
+	State       json.RawMessage `json:"state"`
+}
+
+// AggregateRepositoryOptions configures an AggregateRepository.
+type AggregateRepositoryOptions struct {
+	// Publisher publishes the events after they have been appended. Default: none.
+	Publisher EventPublisher
+
+	// Snapshots stores the latest snapshot of each aggregate by its ID. Default: none.
+	Snapshots resource.Access[string, Snapshot]
+
+	// SnapshotEvery is the number of events after which a new snapshot is stored. Default: 100.
+	SnapshotEvery uint64
+
+	// OnError is called if storing a snapshot or publishing the events failed after the events
+	// have been appended. Save succeeds nevertheless, since the events must not be appended again. Default: none.
+	OnError func(ctx context.Context, aggregateID string, err error)
+}
+
+// AggregateRepository loads aggregates by replaying their events and saves their pending events
+// with optimistic concurrency. If snapshots are enabled, the exported fields of aggregates
+// are stored as JSON and only the events after the snapshot are replayed.
+type AggregateRepository[T Aggregate] struct {
+	factory func() T
+	options AggregateRepositoryOptions
+	store   AggregateStore
+}
+
+// NewAggregateRepository creates a new AggregateRepository instance.
+// The factory creates empty aggregates with registered event handlers.
+func NewAggregateRepository[T Aggregate](store AggregateStore, factory func() T) *AggregateRepository[T] {
+	return NewAggregateRepositoryWithOptions(store, factory, AggregateRepositoryOptions{})
+}
+
+// NewAggregateRepositoryWithOptions creates a new AggregateRepository instance with the given options.
+func NewAggregateRepositoryWithOptions[T Aggregate](store AggregateStore, factory func() T, options AggregateRepositoryOptions) *AggregateRepository[T] {
+	if options.SnapshotEvery == 0 {
+		options.SnapshotEvery = 100
+	}
+	return &AggregateRepository[T]{factory: factory, options: options, store: store}
+}
+
+// Load creates an aggregate and replays its events from the latest snapshot.
+// It returns ErrAggregateNotFound if the aggregate has no events.
+func (a *AggregateRepository[T]) Load(ctx context.Context, id string) (T, error) {
+	var zero T
+	aggregate := a.factory()
+	root := aggregate.Root()
+	root.SetID(id)
+
+	// Restore the latest snapshot, if any.
+	if a.options.Snapshots != nil {
+		snapshot, err := a.options.Snapshots.Read(ctx, id)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(snapshot.State, aggregate); err != nil {
+				return zero, err
+			}
+			root.version = snapshot.Version
+		case err.Error() != resource.ErrorResourceNotFound:
+			return zero, err
+		}
+	}
+
+	events, err := a.store.LoadEvents(ctx, id, root.version)
+	if err != nil {
+		return zero, err
+	}
+	for _, e := range events {
+		if err := root.Apply(e); err != nil {
+			return zero, err
+		}
+	}
+	if root.version == 0 {
+		return zero, ErrAggregateNotFound
+	}
+	return aggregate, nil
+}
+
+// Save appends the pending events of an aggregate, if its persisted version is still current.
+// It returns ErrConcurrencyConflict otherwise, and the aggregate must be loaded again.
+// Errors of storing the snapshot or publishing the events are passed to OnError.
+func (a *AggregateRepository[T]) Save(ctx context.Context, aggregate T) error {
+	root := aggregate.Root()
+	if root.ID() == "" {
+		return ErrMissingAggregateID
+	}
+	events := root.PendingEvents()
+	if len(events) == 0 {
+		return nil
+	}
+	persisted := root.PersistedVersion()
+	if err := a.store.AppendEvents(ctx, root.ID(), persisted, events); err != nil {
+		return err
+	}
+	root.ClearPendingEvents()
+
+	// Store a snapshot if the events crossed a multiple of SnapshotEvery.
+	if a.options.Snapshots != nil && persisted/a.options.SnapshotEvery != root.version/a.options.SnapshotEvery {
+		if err := a.snapshot(ctx, aggregate); err != nil {
+			a.report(ctx, root.ID(), err)
+		}
+	}
+
+	// Publish the events after they have been persisted.
+	if a.options.Publisher != nil {
+		for _, e := range events {
+			if err := a.options.Publisher.Publish(ctx, e); err != nil {
+				a.report(ctx, root.ID(), err)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// report passes an error to OnError, if set.
+func (a *AggregateRepository[T]) report(ctx context.Context, aggregateID string, err error) {
+	if a.options.OnError != nil {
+		a.options.OnError(ctx, aggregateID, err)
+	}
+}
+
+// snapshot stores the current state of the aggregate.
+func (a *AggregateRepository[T]) snapshot(ctx context.Context, aggregate T) error {
+	root := aggregate.Root()
+	state, err := json.Marshal(aggregate)
+	if err != nil {
+		return err
+	}
+	snapshot := Snapshot{AggregateID: root.ID(), Version: root.version, State: state}
+	if err := a.options.Snapshots.Update(ctx, root.ID(), snapshot); err != nil {
+		if err.Error() != resource.ErrorResourceNotFound {
+			return err
+		}
+		return a.options.Snapshots.Create(ctx, root.ID(), snapshot)
+	}
+	return nil
+}
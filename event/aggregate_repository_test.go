@@ -0,0 +1,329 @@
+package event_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/consistency"
+	"github.com/andygeiss/cloud-native-utils/event"
+	"github.com/andygeiss/cloud-native-utils/resource"
+	_ "modernc.org/sqlite"
+)
+
+// failingPublisher fails to publish events.
+type failingPublisher struct{ err error }
+
+func (a failingPublisher) Publish(ctx context.Context, e event.Event) error { return a.err }
+
+// failingLogger fails to write events after the first write.
+type failingLogger struct {
+	consistency.Logger[string, json.RawMessage]
+	writes int
+}
+
+func (a *failingLogger) WriteEvent(ctx context.Context, e consistency.Event[string, json.RawMessage]) error {
+	a.writes++
+	if a.writes > 1 {
+		return errors.New("write failed")
+	}
+	return a.Logger.WriteEvent(ctx, e)
+}
+
+// timedOutLogger writes events, but reports a timeout as if waiting for the acknowledgement expired.
+type timedOutLogger struct {
+	consistency.Logger[string, json.RawMessage]
+}
+
+func (a timedOutLogger) WriteEvent(ctx context.Context, e consistency.Event[string, json.RawMessage]) error {
+	if err := a.Logger.WriteEvent(ctx, e); err != nil {
+		return err
+	}
+	return context.DeadlineExceeded
+}
+
+// recordingLogger records the sequence numbers reads start at.
+type recordingLogger struct {
+	consistency.Logger[string, json.RawMessage]
+	reads []uint64
+}
+
+func (a *recordingLogger) ReadEventsFrom(ctx context.Context, sequence uint64) (<-chan consistency.Event[string, json.RawMessage], <-chan error) {
+	a.reads = append(a.reads, sequence)
+	return a.Logger.ReadEventsFrom(ctx, sequence)
+}
+
+func newTestOrderRegistry() *event.Registry {
+	registry := event.NewRegistry()
+	_ = registry.Register("OrderPlaced", 1, func() event.Event { return orderPlaced{} })
+	_ = registry.Register("OrderCancelled", 1, func() event.Event { return &orderCancelled{} })
+	return registry
+}
+
+func Test_AggregateRepository_With_SavedAggregate_Should_LoadState(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := event.NewAggregateRepository(event.NewInMemoryAggregateStore(), newTestOrder)
+	o := newTestOrder()
+	o.SetID("order-1")
+	_ = o.Record(orderPlaced{OrderID: "order-1", Amount: 42})
+	_ = o.Record(&orderCancelled{OrderID: "order-1"})
+
+	// Act
+	err := repo.Save(ctx, o)
+	loaded, err2 := repo.Load(ctx, "order-1")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "pending events must be empty", len(o.PendingEvents()), 0)
+	assert.That(t, "id must be correct", loaded.ID(), "order-1")
+	assert.That(t, "status must be cancelled", loaded.Status, "cancelled")
+	assert.That(t, "amount must be 42", loaded.Amount, 42)
+	assert.That(t, "version must be 2", loaded.Version(), uint64(2))
+}
+
+func Test_AggregateRepository_With_ConcurrentSave_Should_ReturnErrConcurrencyConflict(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := event.NewAggregateRepository(event.NewInMemoryAggregateStore(), newTestOrder)
+	o := newTestOrder()
+	o.SetID("order-1")
+	_ = o.Record(orderPlaced{OrderID: "order-1", Amount: 42})
+	_ = repo.Save(ctx, o)
+	first, _ := repo.Load(ctx, "order-1")
+	second, _ := repo.Load(ctx, "order-1")
+	_ = first.Record(orderPlaced{OrderID: "order-1", Amount: 1})
+	_ = second.Record(&orderCancelled{OrderID: "order-1"})
+
+	// Act
+	err := repo.Save(ctx, first)
+	err2 := repo.Save(ctx, second)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be ErrConcurrencyConflict", err2, event.ErrConcurrencyConflict)
+}
+
+func Test_AggregateRepository_With_UnknownID_Should_ReturnErrAggregateNotFound(t *testing.T) {
+	// Arrange
+	repo := event.NewAggregateRepository(event.NewInMemoryAggregateStore(), newTestOrder)
+
+	// Act
+	_, err := repo.Load(context.Background(), "order-1")
+
+	// Assert
+	assert.That(t, "err must be ErrAggregateNotFound", err, event.ErrAggregateNotFound)
+}
+
+func Test_AggregateRepository_With_MissingID_Should_ReturnErrMissingAggregateID(t *testing.T) {
+	// Arrange
+	repo := event.NewAggregateRepository(event.NewInMemoryAggregateStore(), newTestOrder)
+	o := newTestOrder()
+	_ = o.Record(orderPlaced{OrderID: "order-1"})
+
+	// Act
+	err := repo.Save(context.Background(), o)
+
+	// Assert
+	assert.That(t, "err must be ErrMissingAggregateID", err, event.ErrMissingAggregateID)
+}
+
+func Test_AggregateRepository_With_Snapshots_Should_ReplayEventsAfterSnapshot(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := event.NewInMemoryAggregateStore()
+	snapshots := resource.NewInMemoryAccess[string, event.Snapshot]()
+	repo := event.NewAggregateRepositoryWithOptions(store, newTestOrder, event.AggregateRepositoryOptions{
+		Snapshots:     snapshots,
+		SnapshotEvery: 2,
+	})
+	o := newTestOrder()
+	o.SetID("order-1")
+	for range 3 {
+		_ = o.Record(orderPlaced{OrderID: "order-1", Amount: 1})
+		_ = repo.Save(ctx, o)
+	}
+
+	// Act
+	snapshot, err := snapshots.Read(ctx, "order-1")
+	loaded, err2 := repo.Load(ctx, "order-1")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "snapshot version must be 2", snapshot.Version, uint64(2))
+	assert.That(t, "snapshot state must be correct", string(snapshot.State), `{"status":"placed","amount":2}`)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "amount must be 3", loaded.Amount, 3)
+	assert.That(t, "version must be 3", loaded.Version(), uint64(3))
+}
+
+func Test_AggregateRepository_With_SqliteSnapshots_Should_CreateAndUpdateSnapshot(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "snapshots.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	snapshots := resource.NewSqliteAccess[string, event.Snapshot](db)
+	if err := snapshots.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	repo := event.NewAggregateRepositoryWithOptions(event.NewInMemoryAggregateStore(), newTestOrder, event.AggregateRepositoryOptions{
+		Snapshots:     snapshots,
+		SnapshotEvery: 1,
+	})
+	o := newTestOrder()
+	o.SetID("order-1")
+	_ = o.Record(orderPlaced{OrderID: "order-1", Amount: 1})
+	_ = repo.Save(ctx, o)
+	created, err := snapshots.Read(ctx, "order-1")
+	_ = o.Record(orderPlaced{OrderID: "order-1", Amount: 1})
+
+	// Act
+	err2 := repo.Save(ctx, o)
+	updated, err3 := snapshots.Read(ctx, "order-1")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "created version must be 1", created.Version, uint64(1))
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "err3 must be nil", err3, nil)
+	assert.That(t, "updated version must be 2", updated.Version, uint64(2))
+}
+
+func Test_AggregateRepository_With_FailingPublisher_Should_ReportErrorAfterAppend(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	errPublish := errors.New("publish failed")
+	var reported []error
+	repo := event.NewAggregateRepositoryWithOptions(event.NewInMemoryAggregateStore(), newTestOrder, event.AggregateRepositoryOptions{
+		Publisher: failingPublisher{err: errPublish},
+		OnError: func(ctx context.Context, aggregateID string, err error) {
+			reported = append(reported, err)
+		},
+	})
+	o := newTestOrder()
+	o.SetID("order-1")
+	_ = o.Record(orderPlaced{OrderID: "order-1"})
+	_ = o.Record(&orderCancelled{OrderID: "order-1"})
+
+	// Act
+	err := repo.Save(ctx, o)
+	loaded, err2 := repo.Load(ctx, "order-1")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "reported must be correct", reported, []error{errPublish})
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "version must be 2", loaded.Version(), uint64(2))
+}
+
+func Test_AggregateRepository_With_Publisher_Should_PublishSavedEvents(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	publisher := event.NewInMemoryAdapter()
+	repo := event.NewAggregateRepositoryWithOptions(event.NewInMemoryAggregateStore(), newTestOrder, event.AggregateRepositoryOptions{
+		Publisher: publisher,
+	})
+	o := newTestOrder()
+	o.SetID("order-1")
+	_ = o.Record(orderPlaced{OrderID: "order-1"})
+
+	// Act
+	err := repo.Save(ctx, o)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "published must be correct", publisher.Published(), []event.Event{orderPlaced{OrderID: "order-1"}})
+}
+
+func Test_LoggerAggregateStore_With_AppendedEvents_Should_LoadEvents(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	logger := consistency.NewJsonFileLogger[string, json.RawMessage](filepath.Join(t.TempDir(), "events.json"))
+	defer func() { _ = logger.Close() }()
+	store := event.NewLoggerAggregateStore(logger, newTestOrderRegistry())
+	repo := event.NewAggregateRepository(store, newTestOrder)
+	o := newTestOrder()
+	o.SetID("order-1")
+	_ = o.Record(orderPlaced{OrderID: "order-1", Amount: 42})
+	_ = o.Record(&orderCancelled{OrderID: "order-1"})
+
+	// Act
+	err := repo.Save(ctx, o)
+	conflict := store.AppendEvents(ctx, "order-1", 1, []event.Event{orderPlaced{}})
+	loaded, err2 := repo.Load(ctx, "order-1")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "conflict must be ErrConcurrencyConflict", conflict, event.ErrConcurrencyConflict)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "status must be cancelled", loaded.Status, "cancelled")
+	assert.That(t, "amount must be 42", loaded.Amount, 42)
+}
+
+func Test_LoggerAggregateStore_With_FailingWrite_Should_NotAppendPartially(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	logger := &failingLogger{Logger: consistency.NewJsonFileLogger[string, json.RawMessage](filepath.Join(t.TempDir(), "events.json"))}
+	defer func() { _ = logger.Close() }()
+	store := event.NewLoggerAggregateStore(logger, newTestOrderRegistry())
+
+	// Act
+	err := store.AppendEvents(ctx, "order-1", 0, []event.Event{orderPlaced{OrderID: "order-1"}, &orderCancelled{OrderID: "order-1"}})
+	err2 := store.AppendEvents(ctx, "order-1", 2, []event.Event{orderPlaced{OrderID: "order-1"}})
+	events, err3 := store.LoadEvents(ctx, "order-1", 1)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must not be nil", err2 != nil, true)
+	assert.That(t, "err3 must be nil", err3, nil)
+	assert.That(t, "events must be correct", events, []event.Event{&orderCancelled{OrderID: "order-1"}})
+}
+
+func Test_LoggerAggregateStore_With_TimedOutWrite_Should_ReadVersionFromLog(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	logger := consistency.NewJsonFileLogger[string, json.RawMessage](filepath.Join(t.TempDir(), "events.json"))
+	defer func() { _ = logger.Close() }()
+	registry := newTestOrderRegistry()
+	_ = event.NewLoggerAggregateStore(logger, registry).AppendEvents(ctx, "order-2", 0, []event.Event{orderPlaced{OrderID: "order-2"}})
+	store := event.NewLoggerAggregateStore(timedOutLogger{Logger: logger}, registry)
+
+	// Act
+	err := store.AppendEvents(ctx, "order-1", 0, []event.Event{orderPlaced{OrderID: "order-1"}})
+	err2 := store.AppendEvents(ctx, "order-1", 0, []event.Event{orderPlaced{OrderID: "order-1"}})
+	events, err3 := store.LoadEvents(ctx, "order-1", 0)
+
+	// Assert
+	assert.That(t, "err must be DeadlineExceeded", err, context.DeadlineExceeded)
+	assert.That(t, "err2 must be ErrConcurrencyConflict", err2, event.ErrConcurrencyConflict)
+	assert.That(t, "err3 must be nil", err3, nil)
+	assert.That(t, "events must be correct", events, []event.Event{orderPlaced{OrderID: "order-1"}})
+}
+
+func Test_LoggerAggregateStore_With_SeveralAggregates_Should_ReadFromFirstEventOfAggregate(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	logger := &recordingLogger{Logger: consistency.NewJsonFileLogger[string, json.RawMessage](filepath.Join(t.TempDir(), "events.json"))}
+	defer func() { _ = logger.Close() }()
+	store := event.NewLoggerAggregateStore(logger, newTestOrderRegistry())
+	_ = store.AppendEvents(ctx, "order-1", 0, []event.Event{orderPlaced{OrderID: "order-1"}})
+	_ = store.AppendEvents(ctx, "order-2", 0, []event.Event{orderPlaced{OrderID: "order-2"}})
+	_ = store.AppendEvents(ctx, "order-1", 1, []event.Event{&orderCancelled{OrderID: "order-1"}})
+
+	// Act
+	events, err := store.LoadEvents(ctx, "order-2", 0)
+	reads := logger.reads
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "events must be correct", events, []event.Event{orderPlaced{OrderID: "order-2"}})
+	assert.That(t, "reads must start after indexed events", reads, []uint64{1, 1, 2})
+}
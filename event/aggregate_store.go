@@ -0,0 +1,66 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+var (
+	ErrConcurrencyConflict = errors.New("concurrency conflict")
+)
+
+// AggregateStore persists the events of aggregates.
+type AggregateStore interface {
+	// AppendEvents appends events to an aggregate if its current version equals the expected version.
+	// It returns ErrConcurrencyConflict otherwise.
+	AppendEvents(ctx context.Context, aggregateID string, expectedVersion uint64, events []Event) error
+	// LoadEvents returns the events of an aggregate with a version greater than the given version.
+	LoadEvents(ctx context.Context, aggregateID string, afterVersion uint64) ([]Event, error)
+}
+
+// InMemoryAggregateStore is an AggregateStore that keeps the events in memory.
+type InMemoryAggregateStore struct {
+	events map[string][]Event
+	mutex  sync.RWMutex
+}
+
+// NewInMemoryAggregateStore creates a new InMemoryAggregateStore instance.
+func NewInMemoryAggregateStore() *InMemoryAggregateStore {
+	return &InMemoryAggregateStore{events: make(map[string][]Event)}
+}
+
+// AppendEvents appends events to an aggregate if its current version equals the expected version.
+func (a *InMemoryAggregateStore) AppendEvents(ctx context.Context, aggregateID string, expectedVersion uint64, events []Event) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if uint64(len(a.events[aggregateID])) != expectedVersion {
+		return ErrConcurrencyConflict
+	}
+	a.events[aggregateID] = append(a.events[aggregateID], events...)
+	return nil
+}
+
+// LoadEvents returns the events of an aggregate with a version greater than the given version.
+func (a *InMemoryAggregateStore) LoadEvents(ctx context.Context, aggregateID string, afterVersion uint64) ([]Event, error) {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	events := a.events[aggregateID]
+	if afterVersion >= uint64(len(events)) {
+		return nil, nil
+	}
+	return slices.Clone(events[afterVersion:]), nil
+}
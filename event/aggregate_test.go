@@ -0,0 +1,86 @@
+package event_test
+
+import (
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/event"
+)
+
+type testOrder struct {
+	event.AggregateRoot
+
+	Status string `json:"status"`
+	Amount int    `json:"amount"`
+}
+
+func newTestOrder() *testOrder {
+	o := &testOrder{}
+	event.On(&o.AggregateRoot, func(e orderPlaced) {
+		o.Status = "placed"
+		o.Amount += e.Amount
+	})
+	event.On(&o.AggregateRoot, func(e *orderCancelled) {
+		o.Status = "cancelled"
+	})
+	return o
+}
+
+func Test_AggregateRoot_With_Record_Should_ApplyAndRecordEvent(t *testing.T) {
+	// Arrange
+	o := newTestOrder()
+
+	// Act
+	err := o.Record(orderPlaced{OrderID: "order-1", Amount: 42})
+	err2 := o.Record(&orderCancelled{OrderID: "order-1"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "status must be cancelled", o.Status, "cancelled")
+	assert.That(t, "amount must be 42", o.Amount, 42)
+	assert.That(t, "version must be 2", o.Version(), uint64(2))
+	assert.That(t, "persisted version must be 0", o.PersistedVersion(), uint64(0))
+	assert.That(t, "pending events must have 2 entries", len(o.PendingEvents()), 2)
+}
+
+func Test_AggregateRoot_With_Apply_Should_NotRecordEvent(t *testing.T) {
+	// Arrange
+	o := newTestOrder()
+
+	// Act
+	err := o.Apply(&orderPlaced{OrderID: "order-1", Amount: 42})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "status must be placed", o.Status, "placed")
+	assert.That(t, "version must be 1", o.Version(), uint64(1))
+	assert.That(t, "persisted version must be 1", o.PersistedVersion(), uint64(1))
+	assert.That(t, "pending events must be empty", len(o.PendingEvents()), 0)
+}
+
+func Test_AggregateRoot_With_UnknownEvent_Should_ReturnErrNoEventHandler(t *testing.T) {
+	// Arrange
+	o := newTestOrder()
+
+	// Act
+	err := o.Record(orderEvent{OrderID: "order-1"})
+
+	// Assert
+	assert.That(t, "err must be ErrNoEventHandler", err, event.ErrNoEventHandler)
+	assert.That(t, "version must be 0", o.Version(), uint64(0))
+	assert.That(t, "pending events must be empty", len(o.PendingEvents()), 0)
+}
+
+func Test_AggregateRoot_With_ClearPendingEvents_Should_KeepVersion(t *testing.T) {
+	// Arrange
+	o := newTestOrder()
+	_ = o.Record(orderPlaced{OrderID: "order-1"})
+
+	// Act
+	o.ClearPendingEvents()
+
+	// Assert
+	assert.That(t, "version must be 1", o.Version(), uint64(1))
+	assert.That(t, "persisted version must be 1", o.PersistedVersion(), uint64(1))
+}
@@ -0,0 +1,317 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+
+	"github.com/andygeiss/cloud-native-utils/messaging"
+)
+
+var (
+	ErrBusClosed           = errors.New("bus closed")
+	ErrTransactionFinished = errors.New("transaction already finished")
+)
+
+// BusOptions configures a Bus.
+type BusOptions struct {
+	// Workers is the number of workers calling the asynchronous handlers. Default: 4.
+	Workers int
+
+	// QueueSize is the number of events each worker buffers before Publish blocks. Default: 100.
+	QueueSize int
+}
+
+// Bus dispatches events within the process without a broker. It implements
+// EventPublisher and EventSubscriber.
+//
+// Synchronous handlers are called by Publish in the order of their registration,
+// and their errors are joined and returned. Asynchronous handlers are called by a
+// pool of workers after the synchronous handlers succeeded, or after the transaction
+// of the context has been committed. Events of the same topic are delivered to the
+// asynchronous handlers in the order they were published.
+type Bus struct {
+	async    []busHandler
+	done     chan struct{}
+	errorCh  chan error
+	options  BusOptions
+	queues   []chan busDelivery
+	sync     []busHandler
+	sending  sync.WaitGroup
+	wg       sync.WaitGroup
+	closed   bool
+	mutex    sync.RWMutex
+	errMutex sync.Mutex
+}
+
+// BusTx collects the events for the asynchronous handlers until it is committed.
+type BusTx struct {
+	bus      *Bus
+	events   []Event
+	finished bool
+	mutex    sync.Mutex
+}
+
+// busDelivery is an event with the asynchronous handlers to call.
+type busDelivery struct {
+	event    Event
+	handlers []EventHandlerFn
+}
+
+// busHandler is a handler of the events matching a topic pattern.
+type busHandler struct {
+	pattern messaging.TopicPattern
+	handler EventHandlerFn
+}
+
+// busTxKey is the context key of a BusTx.
+type busTxKey struct{}
+
+// NewBus creates a new Bus instance.
+func NewBus() *Bus {
+	return NewBusWithOptions(BusOptions{})
+}
+
+// NewBusWithOptions creates a new Bus instance with the given options and starts its workers.
+func NewBusWithOptions(options BusOptions) *Bus {
+	if options.Workers <= 0 {
+		options.Workers = 4
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = 100
+	}
+	a := &Bus{
+		done:    make(chan struct{}),
+		errorCh: make(chan error, 100),
+		options: options,
+		queues:  make([]chan busDelivery, options.Workers),
+	}
+	for i := range a.queues {
+		a.queues[i] = make(chan busDelivery, options.QueueSize)
+		a.wg.Go(func() { a.work(a.queues[i]) })
+	}
+	return a
+}
+
+// Begin starts a transaction and returns a context carrying it. Events published with the
+// context are delivered to the asynchronous handlers after the transaction has been committed.
+func (a *Bus) Begin(ctx context.Context) (context.Context, *BusTx) {
+	tx := &BusTx{bus: a}
+	return context.WithValue(ctx, busTxKey{}, tx), tx
+}
+
+// Close waits for the asynchronous handlers to handle the queued events and stops the workers.
+// Publish calls waiting for a full queue return ErrBusClosed.
+func (a *Bus) Close() error {
+	a.mutex.Lock()
+	if a.closed {
+		a.mutex.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.done)
+	a.mutex.Unlock()
+
+	// Close the queues after the pending sends finished, since no new sends are started.
+	a.sending.Wait()
+	for _, queue := range a.queues {
+		close(queue)
+	}
+	a.wg.Wait()
+	a.errMutex.Lock()
+	close(a.errorCh)
+	a.errMutex.Unlock()
+	return nil
+}
+
+// Errors returns the errors of the asynchronous handlers. It is closed by Close.
+// Errors are dropped if the channel is full.
+func (a *Bus) Errors() <-chan error {
+	return a.errorCh
+}
+
+// Publish calls the synchronous handlers of the event and returns their joined errors.
+// If they succeeded, the event is delivered to the asynchronous handlers, or added to
+// the transaction of the context.
+func (a *Bus) Publish(ctx context.Context, e Event) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if e == nil {
+		return ErrNilEvent
+	}
+
+	a.mutex.RLock()
+	closed := a.closed
+	handlers := matchBusHandlers(a.sync, e.Topic())
+	a.mutex.RUnlock()
+	if closed {
+		return ErrBusClosed
+	}
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	// Defer the asynchronous delivery until the transaction has been committed.
+	if tx, ok := ctx.Value(busTxKey{}).(*BusTx); ok && tx.bus == a {
+		return tx.add(e)
+	}
+	return a.dispatch(ctx, []Event{e})
+}
+
+// Subscribe adds a synchronous handler for the events of the topic, which may be a pattern
+// like "orders.*". The factory is not used, since events are passed without encoding.
+func (a *Bus) Subscribe(ctx context.Context, topic string, _ EventFactoryFn, handler EventHandlerFn) error {
+	return a.subscribe(ctx, topic, handler, false)
+}
+
+// SubscribeAsync adds an asynchronous handler for the events of the topic, which may be a pattern
+// like "orders.*".
+func (a *Bus) SubscribeAsync(ctx context.Context, topic string, handler EventHandlerFn) error {
+	return a.subscribe(ctx, topic, handler, true)
+}
+
+// dispatch queues the events for the asynchronous handlers.
+// Events of the same topic are queued to the same worker to keep their order.
+// The queues are chosen while holding the lock, but sending may block and is done after releasing it.
+func (a *Bus) dispatch(ctx context.Context, events []Event) error {
+	type send struct {
+		queue    chan<- busDelivery
+		delivery busDelivery
+	}
+
+	a.mutex.RLock()
+	if a.closed {
+		a.mutex.RUnlock()
+		return ErrBusClosed
+	}
+	var sends []send
+	for _, e := range events {
+		handlers := matchBusHandlers(a.async, e.Topic())
+		if len(handlers) == 0 {
+			continue
+		}
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(e.Topic()))
+		sends = append(sends, send{
+			queue:    a.queues[h.Sum32()%uint32(len(a.queues))], //nolint:gosec // number of workers is positive
+			delivery: busDelivery{event: e, handlers: handlers},
+		})
+	}
+	a.sending.Add(1)
+	a.mutex.RUnlock()
+	defer a.sending.Done()
+
+	for _, s := range sends {
+		select {
+		case s.queue <- s.delivery:
+		case <-a.done:
+			return ErrBusClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// report sends the error to the error channel, or drops it if the channel is full.
+func (a *Bus) report(err error) {
+	a.errMutex.Lock()
+	defer a.errMutex.Unlock()
+	select {
+	case a.errorCh <- err:
+	default:
+	}
+}
+
+// subscribe adds a synchronous or asynchronous handler.
+func (a *Bus) subscribe(ctx context.Context, topic string, handler EventHandlerFn, async bool) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	pattern, err := messaging.ParseTopicPattern(topic)
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return ErrBusClosed
+	}
+	if async {
+		a.async = append(a.async, busHandler{pattern: pattern, handler: handler})
+	} else {
+		a.sync = append(a.sync, busHandler{pattern: pattern, handler: handler})
+	}
+	return nil
+}
+
+// work calls the asynchronous handlers of the queued events until the queue is closed.
+func (a *Bus) work(queue <-chan busDelivery) {
+	for delivery := range queue {
+		for _, handler := range delivery.handlers {
+			if err := handler(delivery.event); err != nil {
+				a.report(err)
+			}
+		}
+	}
+}
+
+// Commit delivers the collected events to the asynchronous handlers.
+func (a *BusTx) Commit(ctx context.Context) error {
+	a.mutex.Lock()
+	if a.finished {
+		a.mutex.Unlock()
+		return ErrTransactionFinished
+	}
+	a.finished = true
+	events := a.events
+	a.events = nil
+	a.mutex.Unlock()
+	return a.bus.dispatch(ctx, events)
+}
+
+// Rollback discards the collected events.
+func (a *BusTx) Rollback() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.finished {
+		return ErrTransactionFinished
+	}
+	a.finished = true
+	a.events = nil
+	return nil
+}
+
+// add collects an event until the transaction is committed.
+func (a *BusTx) add(e Event) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.finished {
+		return ErrTransactionFinished
+	}
+	a.events = append(a.events, e)
+	return nil
+}
+
+// matchBusHandlers returns the handlers of the topic in the order of their registration.
+func matchBusHandlers(handlers []busHandler, topic string) []EventHandlerFn {
+	var matches []EventHandlerFn
+	for _, h := range handlers {
+		if h.pattern.Match(topic) {
+			matches = append(matches, h.handler)
+		}
+	}
+	return matches
+}
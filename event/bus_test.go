@@ -0,0 +1,203 @@
+package event_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/event"
+)
+
+func Test_Bus_With_SyncHandlers_Should_CallInOrder(t *testing.T) {
+	// Arrange
+	bus := event.NewBus()
+	defer func() { _ = bus.Close() }()
+	var calls []string
+	_ = bus.Subscribe(context.Background(), "orders.placed", nil, func(e event.Event) error {
+		calls = append(calls, "first")
+		return nil
+	})
+	_ = bus.Subscribe(context.Background(), "orders.*", nil, func(e event.Event) error {
+		calls = append(calls, "second")
+		return nil
+	})
+
+	// Act
+	err := bus.Publish(context.Background(), orderPlaced{OrderID: "1"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "calls must be correct", calls, []string{"first", "second"})
+}
+
+func Test_Bus_With_FailingSyncHandlers_Should_JoinErrors(t *testing.T) {
+	// Arrange
+	bus := event.NewBus()
+	defer func() { _ = bus.Close() }()
+	errFirst := errors.New("first")
+	errSecond := errors.New("second")
+	_ = bus.Subscribe(context.Background(), "orders.placed", nil, func(e event.Event) error { return errFirst })
+	_ = bus.Subscribe(context.Background(), "orders.placed", nil, func(e event.Event) error { return errSecond })
+	var asyncCalled bool
+	_ = bus.SubscribeAsync(context.Background(), "orders.placed", func(e event.Event) error {
+		asyncCalled = true
+		return nil
+	})
+
+	// Act
+	err := bus.Publish(context.Background(), orderPlaced{OrderID: "1"})
+	_ = bus.Close()
+
+	// Assert
+	assert.That(t, "err must contain first", errors.Is(err, errFirst), true)
+	assert.That(t, "err must contain second", errors.Is(err, errSecond), true)
+	assert.That(t, "async handler must not be called", asyncCalled, false)
+}
+
+func Test_Bus_With_AsyncHandler_Should_DeliverInOrder(t *testing.T) {
+	// Arrange
+	bus := event.NewBusWithOptions(event.BusOptions{Workers: 2, QueueSize: 1})
+	var mutex sync.Mutex
+	var ids []string
+	_ = bus.SubscribeAsync(context.Background(), "orders.placed", func(e event.Event) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		ids = append(ids, e.(orderPlaced).OrderID)
+		return nil
+	})
+
+	// Act
+	for _, id := range []string{"1", "2", "3", "4"} {
+		_ = bus.Publish(context.Background(), orderPlaced{OrderID: id})
+	}
+	err := bus.Close()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "ids must be in order", ids, []string{"1", "2", "3", "4"})
+}
+
+func Test_Bus_With_FailingAsyncHandler_Should_ReportError(t *testing.T) {
+	// Arrange
+	bus := event.NewBus()
+	errHandler := errors.New("error")
+	_ = bus.SubscribeAsync(context.Background(), "orders.placed", func(e event.Event) error { return errHandler })
+
+	// Act
+	_ = bus.Publish(context.Background(), orderPlaced{OrderID: "1"})
+	_ = bus.Close()
+
+	// Assert
+	var errs []error
+	for err := range bus.Errors() {
+		errs = append(errs, err)
+	}
+	assert.That(t, "errs must be correct", errs, []error{errHandler})
+}
+
+func Test_Bus_With_CommittedTransaction_Should_DeliverAfterCommit(t *testing.T) {
+	// Arrange
+	bus := event.NewBus()
+	delivered := make(chan string, 1)
+	_ = bus.SubscribeAsync(context.Background(), "orders.placed", func(e event.Event) error {
+		delivered <- e.(orderPlaced).OrderID
+		return nil
+	})
+	ctx, tx := bus.Begin(context.Background())
+
+	// Act
+	_ = bus.Publish(ctx, orderPlaced{OrderID: "1"})
+	pending := len(delivered)
+	err := tx.Commit(context.Background())
+	_ = bus.Close()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "event must not be delivered before commit", pending, 0)
+	assert.That(t, "event must be delivered after commit", <-delivered, "1")
+}
+
+func Test_Bus_With_RolledBackTransaction_Should_DiscardEvents(t *testing.T) {
+	// Arrange
+	bus := event.NewBus()
+	var syncCalled, asyncCalled bool
+	_ = bus.Subscribe(context.Background(), "orders.placed", nil, func(e event.Event) error {
+		syncCalled = true
+		return nil
+	})
+	_ = bus.SubscribeAsync(context.Background(), "orders.placed", func(e event.Event) error {
+		asyncCalled = true
+		return nil
+	})
+	ctx, tx := bus.Begin(context.Background())
+
+	// Act
+	_ = bus.Publish(ctx, orderPlaced{OrderID: "1"})
+	err := tx.Rollback()
+	_ = bus.Close()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "sync handler must be called", syncCalled, true)
+	assert.That(t, "async handler must not be called", asyncCalled, false)
+	assert.That(t, "commit must fail", tx.Commit(context.Background()), event.ErrTransactionFinished)
+}
+
+func Test_Bus_With_Closed_Should_ReturnErrBusClosed(t *testing.T) {
+	// Arrange
+	bus := event.NewBus()
+	_ = bus.Close()
+
+	// Act
+	err := bus.Publish(context.Background(), orderPlaced{OrderID: "1"})
+
+	// Assert
+	assert.That(t, "err must be ErrBusClosed", err, event.ErrBusClosed)
+}
+
+func Test_Bus_With_FullQueue_Should_NotBlockSubscribeAndClose(t *testing.T) {
+	// Arrange
+	bus := event.NewBusWithOptions(event.BusOptions{Workers: 1, QueueSize: 1})
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	_ = bus.SubscribeAsync(context.Background(), "orders.placed", func(e event.Event) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	_ = bus.Publish(context.Background(), orderPlaced{OrderID: "1"})
+	<-started
+	_ = bus.Publish(context.Background(), orderPlaced{OrderID: "2"})
+	published := make(chan error, 1)
+	go func() { published <- bus.Publish(context.Background(), orderPlaced{OrderID: "3"}) }()
+	time.Sleep(10 * time.Millisecond) // Wait until the third event blocks on the full queue.
+
+	// Act
+	subscribed := make(chan error, 1)
+	go func() {
+		subscribed <- bus.Subscribe(context.Background(), "orders.placed", nil, func(e event.Event) error { return nil })
+	}()
+	var err, err2 error
+	select {
+	case err = <-subscribed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscribe must not block")
+	}
+	closed := make(chan error, 1)
+	go func() { closed <- bus.Close() }()
+	select {
+	case err2 = <-published:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publish must return after close")
+	}
+	close(release)
+	err3 := <-closed
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be ErrBusClosed", err2, event.ErrBusClosed)
+	assert.That(t, "err3 must be nil", err3, nil)
+}
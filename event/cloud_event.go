@@ -0,0 +1,29 @@
+package event
+
+import (
+	"encoding/json"
+
+	"github.com/andygeiss/cloud-native-utils/messaging"
+)
+
+// ToCloudEvent encodes the event as CloudEvent with JSON data. The topic of the event becomes
+// the type, and the ID of an IdentifiedEvent becomes the ID of the CloudEvent.
+func ToCloudEvent(source string, e Event) (messaging.CloudEvent, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return messaging.CloudEvent{}, err
+	}
+	message := messaging.NewMessage(e.Topic(), data).WithHeader(messaging.HeaderContentType, messaging.ContentTypeJson)
+	if identified, ok := e.(IdentifiedEvent); ok && identified.EventID() != "" {
+		message.ID = identified.EventID()
+	}
+	return messaging.NewCloudEvent(source, message), nil
+}
+
+// FromCloudEvent decodes the data of the CloudEvent into a new event created by the factory.
+func FromCloudEvent(ce messaging.CloudEvent, factory EventFactoryFn) (Event, error) {
+	if err := ce.Validate(); err != nil {
+		return nil, err
+	}
+	return decodeEvent(factory, ce.Data)
+}
@@ -0,0 +1,49 @@
+package event_test
+
+import (
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/event"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+)
+
+func Test_ToCloudEvent_Should_DecodeFromCloudEvent(t *testing.T) {
+	// Arrange
+	e := orderPlaced{OrderID: "order-1", Amount: 42}
+
+	// Act
+	ce, err := event.ToCloudEvent("/orders", e)
+	decoded, err2 := event.FromCloudEvent(ce, func() event.Event { return orderPlaced{} })
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "type must be the topic", ce.Type, "orders.placed")
+	assert.That(t, "source must be correct", ce.Source, "/orders")
+	assert.That(t, "content type must be JSON", ce.DataContentType, messaging.ContentTypeJson)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "event must be correct", decoded, event.Event(e))
+}
+
+func Test_ToCloudEvent_With_IdentifiedEvent_Should_UseEventID(t *testing.T) {
+	// Arrange
+	e := identifiedEvent{ID: "event-1"}
+
+	// Act
+	ce, err := event.ToCloudEvent("/orders", e)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "id must be correct", ce.ID, "event-1")
+}
+
+func Test_FromCloudEvent_With_InvalidEvent_Should_ReturnErrInvalidCloudEvent(t *testing.T) {
+	// Arrange
+	ce := messaging.CloudEvent{Type: "orders.placed", Data: []byte("{}")}
+
+	// Act
+	_, err := event.FromCloudEvent(ce, func() event.Event { return orderPlaced{} })
+
+	// Assert
+	assert.That(t, "err must be ErrInvalidCloudEvent", err, messaging.ErrInvalidCloudEvent)
+}
@@ -0,0 +1,14 @@
+package event
+
+import "encoding/json"
+
+// HeaderEventType contains the type name of an event in an envelope.
+const HeaderEventType = "x-event-type"
+
+// Envelope wraps the JSON data of an event with its type name and version,
+// so that a topic may carry several event types.
+type Envelope struct {
+	Type    string          `json:"type"`
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
@@ -8,6 +8,17 @@
 //   - EventSubscriber: interface for subscribing to events from a message broker
 //   - EventFactoryFn: factory function type for creating event instances
 //   - EventHandlerFn: handler function type for processing events
+//   - MessagingAdapter: publisher and subscriber on top of a messaging.Dispatcher
+//   - InMemoryAdapter: MessagingAdapter recording the published events for tests
+//   - Bus: dispatches events within the process to synchronous and asynchronous handlers
+//   - Registry: maps event type names and versions to factories for envelopes
+//   - Router: publishes envelopes and dispatches events to handlers by Go type
+//   - AggregateRoot: base of event-sourced aggregates recording pending events
+//   - AggregateRepository: loads and saves aggregates with optimistic concurrency
+//   - Projection: builds read models from events with checkpoints and rebuilds
+//   - Saga: orchestrates steps driven by events and compensates them on failure
+//   - Middleware: wraps EventHandlerFn with logging, retries, timeouts or idempotency
+//   - ToCloudEvent and FromCloudEvent: convert events to and from CloudEvents
 //
 // These interfaces are designed to be implemented by concrete message broker
 // adapters (e.g., Kafka, NATS, in-memory) while keeping domain code decoupled
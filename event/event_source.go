@@ -0,0 +1,13 @@
+package event
+
+import "context"
+
+// EventSource replays the published events of topics in order, e.g. to rebuild projections.
+// Positions are global positions of the source starting at 1, which don't depend on the
+// given topics, so that checkpoints stay valid if the topics of a projection change.
+type EventSource interface {
+	// Head returns the position of the latest event of the topics.
+	Head(ctx context.Context, topics []string) (uint64, error)
+	// ReadEvents calls fn for each event of the topics after the given position.
+	ReadEvents(ctx context.Context, topics []string, after uint64, fn func(position uint64, e Event) error) error
+}
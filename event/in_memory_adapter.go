@@ -0,0 +1,124 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+
+	"github.com/andygeiss/cloud-native-utils/messaging"
+)
+
+// InMemoryAdapter is a MessagingAdapter on top of an internal dispatcher for tests.
+// Events are delivered synchronously, and the published events are recorded,
+// so that they can be replayed as an EventSource.
+type InMemoryAdapter struct {
+	*MessagingAdapter
+	published []*publishedEvent
+	mutex     sync.Mutex
+}
+
+// publishedEvent is a recorded event and the error of its publication.
+type publishedEvent struct {
+	event Event
+	err   error
+}
+
+// NewInMemoryAdapter creates a new InMemoryAdapter instance.
+func NewInMemoryAdapter() *InMemoryAdapter {
+	return &InMemoryAdapter{MessagingAdapter: NewMessagingAdapter(messaging.NewInternalDispatcher())}
+}
+
+// Head returns the position of the latest recorded event of the topics.
+func (a *InMemoryAdapter) Head(ctx context.Context, topics []string) (uint64, error) {
+	var head uint64
+	err := a.ReadEvents(ctx, topics, 0, func(position uint64, _ Event) error {
+		head = position
+		return nil
+	})
+	return head, err
+}
+
+// Publish records the event and publishes it to the subscribers.
+// The event is recorded before it is delivered, so that subscribers can read it as an
+// EventSource, and the error of its delivery is recorded with it. Events which can't
+// be serialized are not recorded. It returns the errors of the subscribers.
+func (a *InMemoryAdapter) Publish(ctx context.Context, e Event) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := json.Marshal(e); err != nil {
+		return err
+	}
+	published := &publishedEvent{event: e}
+	a.mutex.Lock()
+	a.published = append(a.published, published)
+	a.mutex.Unlock()
+
+	err := a.MessagingAdapter.Publish(ctx, e)
+	a.mutex.Lock()
+	published.err = err
+	a.mutex.Unlock()
+	return err
+}
+
+// Errors returns the errors of the published events in the order of Published.
+// The error of an event delivered successfully is nil.
+func (a *InMemoryAdapter) Errors() []error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	errs := make([]error, 0, len(a.published))
+	for _, published := range a.published {
+		errs = append(errs, published.err)
+	}
+	return errs
+}
+
+// Published returns a copy of the published events in order.
+func (a *InMemoryAdapter) Published() []Event {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	events := make([]Event, 0, len(a.published))
+	for _, published := range a.published {
+		events = append(events, published.event)
+	}
+	return events
+}
+
+// ReadEvents calls fn for each recorded event of the topics after the given position.
+// The position of an event is its index in the recorded events starting at 1.
+// Topics may be patterns like "orders.*".
+func (a *InMemoryAdapter) ReadEvents(ctx context.Context, topics []string, after uint64, fn func(position uint64, e Event) error) error {
+	patterns := make([]messaging.TopicPattern, 0, len(topics))
+	for _, topic := range topics {
+		pattern, err := messaging.ParseTopicPattern(topic)
+		if err != nil {
+			return err
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	var position uint64
+	for _, e := range a.Published() {
+		// Skip if context is canceled or timed out.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		position++
+		if position <= after || !slices.ContainsFunc(patterns, func(p messaging.TopicPattern) bool { return p.Match(e.Topic()) }) {
+			continue
+		}
+		if err := fn(position, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset removes the recorded events.
+func (a *InMemoryAdapter) Reset() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.published = nil
+}
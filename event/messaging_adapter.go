@@ -0,0 +1,78 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/andygeiss/cloud-native-utils/messaging"
+)
+
+var (
+	ErrNilEvent = errors.New("factory returned nil event")
+)
+
+// MessagingAdapter implements EventPublisher and EventSubscriber on top of a messaging.Dispatcher.
+// Events are serialized as JSON into the message data and published to their topic.
+type MessagingAdapter struct {
+	dispatcher messaging.Dispatcher
+}
+
+// NewMessagingAdapter creates a new MessagingAdapter instance.
+func NewMessagingAdapter(dispatcher messaging.Dispatcher) *MessagingAdapter {
+	return &MessagingAdapter{dispatcher: dispatcher}
+}
+
+// Publish serializes the event as JSON and publishes it to the topic of the event.
+// The values of the context are propagated by using messaging.InjectContext.
+func (a *MessagingAdapter) Publish(ctx context.Context, e Event) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	message := messaging.NewMessage(e.Topic(), data).WithHeader(messaging.HeaderContentType, messaging.ContentTypeJson)
+	return a.dispatcher.Publish(ctx, messaging.InjectContext(ctx, message))
+}
+
+// Subscribe deserializes the messages of the topic into events created by the factory and calls the handler.
+// Messages are completed if the handler succeeds, and failed otherwise.
+func (a *MessagingAdapter) Subscribe(ctx context.Context, topic string, factory EventFactoryFn, handler EventHandlerFn) error {
+	return a.dispatcher.Subscribe(ctx, topic, func(ctx context.Context, message messaging.Message) (messaging.MessageState, error) {
+		e, err := decodeEvent(factory, message.Data)
+		if err != nil {
+			return messaging.MessageStateFailed, err
+		}
+		if err := handler(e); err != nil {
+			return messaging.MessageStateFailed, err
+		}
+		return messaging.MessageStateCompleted, nil
+	})
+}
+
+// decodeEvent deserializes the data into a new event created by the factory.
+// Factories may return pointers or values, which are decoded by using a pointer to a copy.
+func decodeEvent(factory EventFactoryFn, data []byte) (Event, error) {
+	e := factory()
+	if e == nil {
+		return nil, ErrNilEvent
+	}
+	v := reflect.ValueOf(e)
+	if v.Kind() == reflect.Pointer {
+		if err := json.Unmarshal(data, e); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	if err := json.Unmarshal(data, ptr.Interface()); err != nil {
+		return nil, err
+	}
+	decoded, _ := ptr.Elem().Interface().(Event)
+	return decoded, nil
+}
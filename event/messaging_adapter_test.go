@@ -0,0 +1,160 @@
+package event_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/event"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+	"github.com/andygeiss/cloud-native-utils/service"
+)
+
+type orderPlaced struct {
+	OrderID string `json:"order_id"`
+	Amount  int    `json:"amount"`
+}
+
+func (e orderPlaced) Topic() string {
+	return "orders.placed"
+}
+
+type orderCancelled struct {
+	OrderID string `json:"order_id"`
+}
+
+func (e *orderCancelled) Topic() string {
+	return "orders.cancelled"
+}
+
+func Test_MessagingAdapter_With_ValueFactory_Should_HandleEvent(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewMessagingAdapter(messaging.NewInternalDispatcher())
+	var handled event.Event
+	_ = adapter.Subscribe(ctx, "orders.placed", func() event.Event { return orderPlaced{} }, func(e event.Event) error {
+		handled = e
+		return nil
+	})
+
+	// Act
+	err := adapter.Publish(ctx, orderPlaced{OrderID: "order-1", Amount: 42})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "event must be correct", handled, event.Event(orderPlaced{OrderID: "order-1", Amount: 42}))
+}
+
+func Test_MessagingAdapter_With_PointerFactory_Should_HandleEvent(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewMessagingAdapter(messaging.NewInternalDispatcher())
+	var handled *orderCancelled
+	_ = adapter.Subscribe(ctx, "orders.cancelled", func() event.Event { return &orderCancelled{} }, func(e event.Event) error {
+		handled, _ = e.(*orderCancelled)
+		return nil
+	})
+
+	// Act
+	err := adapter.Publish(ctx, &orderCancelled{OrderID: "order-1"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "order id must be correct", handled.OrderID, "order-1")
+}
+
+func Test_MessagingAdapter_With_Publish_Should_SerializeJSON(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	adapter := event.NewMessagingAdapter(dis)
+	var received messaging.Message
+	_ = dis.Subscribe(ctx, "orders.placed", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		received = msg
+		return messaging.MessageStateCompleted, nil
+	}))
+
+	// Act
+	err := adapter.Publish(ctx, orderPlaced{OrderID: "order-1", Amount: 42})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "data must be correct", string(received.Data), `{"order_id":"order-1","amount":42}`)
+	assert.That(t, "content type must be correct", received.Headers[messaging.HeaderContentType], messaging.ContentTypeJson)
+}
+
+func Test_MessagingAdapter_With_FailingHandler_Should_FailMessage(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	adapter := event.NewMessagingAdapter(dis)
+	handlerErr := errors.New("error")
+	_ = adapter.Subscribe(ctx, "orders.placed", func() event.Event { return orderPlaced{} }, func(e event.Event) error {
+		return handlerErr
+	})
+
+	// Act
+	err := adapter.Publish(ctx, orderPlaced{OrderID: "order-1"})
+
+	// Assert
+	assert.That(t, "err must be correct", err, handlerErr)
+}
+
+func Test_MessagingAdapter_With_NilFactory_Should_ReturnErrNilEvent(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewMessagingAdapter(messaging.NewInternalDispatcher())
+	_ = adapter.Subscribe(ctx, "orders.placed", func() event.Event { return nil }, func(e event.Event) error {
+		return nil
+	})
+
+	// Act
+	err := adapter.Publish(ctx, orderPlaced{OrderID: "order-1"})
+
+	// Assert
+	assert.That(t, "err must be ErrNilEvent", err, event.ErrNilEvent)
+}
+
+func Test_InMemoryAdapter_With_Publish_Should_RecordEvents(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewInMemoryAdapter()
+	var handled int
+	_ = adapter.Subscribe(ctx, "orders.placed", func() event.Event { return orderPlaced{} }, func(e event.Event) error {
+		handled++
+		return nil
+	})
+
+	// Act
+	_ = adapter.Publish(ctx, orderPlaced{OrderID: "order-1"})
+	_ = adapter.Publish(ctx, &orderCancelled{OrderID: "order-1"})
+	published := adapter.Published()
+	adapter.Reset()
+
+	// Assert
+	assert.That(t, "handled must be 1", handled, 1)
+	assert.That(t, "published must have 2 events", len(published), 2)
+	assert.That(t, "first topic must be correct", published[0].Topic(), "orders.placed")
+	assert.That(t, "published must be empty after reset", len(adapter.Published()), 0)
+}
+
+func Test_InMemoryAdapter_With_FailingSubscriber_Should_RecordError(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewInMemoryAdapter()
+	_ = adapter.Subscribe(ctx, "orders.cancelled", func() event.Event { return &orderCancelled{} }, func(e event.Event) error {
+		return errors.New("unavailable")
+	})
+
+	// Act
+	_ = adapter.Publish(ctx, orderPlaced{OrderID: "order-1"})
+	err := adapter.Publish(ctx, &orderCancelled{OrderID: "order-1"})
+	errs := adapter.Errors()
+
+	// Assert
+	assert.That(t, "err must not be nil", err != nil, true)
+	assert.That(t, "errs must have 2 errors", len(errs), 2)
+	assert.That(t, "first error must be nil", errs[0], nil)
+	assert.That(t, "second error must be correct", errors.Is(errs[1], err), true)
+}
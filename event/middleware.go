@@ -0,0 +1,143 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/resource"
+	"github.com/andygeiss/cloud-native-utils/service"
+	"github.com/andygeiss/cloud-native-utils/stability"
+)
+
+var (
+	ErrHandlerPanicked = errors.New("event handler panicked")
+)
+
+// IdentifiedEvent is an event with a unique ID used for deduplication.
+type IdentifiedEvent interface {
+	Event
+	EventID() string
+}
+
+// Middleware wraps an EventHandlerFn to add cross-cutting behavior.
+type Middleware func(next EventHandlerFn) EventHandlerFn
+
+// Chain wraps the handler with the middlewares. The first middleware is the outermost one.
+func Chain(handler EventHandlerFn, middlewares ...Middleware) EventHandlerFn {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// WithBreaker stops calling the handler after the given number of consecutive failures
+// by using stability.Breaker.
+func WithBreaker(threshold int) Middleware {
+	return func(next EventHandlerFn) EventHandlerFn {
+		return fromFunction(stability.Breaker(toFunction(next), threshold))
+	}
+}
+
+// WithIdempotency skips events whose ID has already been handled successfully.
+// The IDs are stored with the time of handling. Events without an ID are always handled.
+func WithIdempotency(processed resource.Access[string, time.Time]) Middleware {
+	return WithIdempotencyCustom(processed, func(e Event) string {
+		if identified, ok := e.(IdentifiedEvent); ok {
+			return identified.EventID()
+		}
+		return ""
+	})
+}
+
+// WithIdempotencyCustom is like WithIdempotency, but uses a custom function to get the ID of an event.
+// The ID is stored before the handler is called, so that concurrent duplicates are skipped,
+// and removed again if the handler fails.
+func WithIdempotencyCustom(processed resource.Access[string, time.Time], id func(e Event) string) Middleware {
+	return func(next EventHandlerFn) EventHandlerFn {
+		return func(e Event) error {
+			key := id(e)
+			if key == "" {
+				return next(e)
+			}
+			ctx := context.Background()
+			if err := processed.Create(ctx, key, time.Now().UTC()); err != nil {
+				if err.Error() == resource.ErrorResourceAlreadyExists {
+					return nil
+				}
+				return err
+			}
+			if err := next(e); err != nil {
+				return errors.Join(err, processed.Delete(ctx, key))
+			}
+			return nil
+		}
+	}
+}
+
+// WithLogging logs the topic, duration and error of each handled event.
+func WithLogging(logger *slog.Logger) Middleware {
+	return func(next EventHandlerFn) EventHandlerFn {
+		return func(e Event) error {
+			start := time.Now()
+			err := next(e)
+			if err != nil {
+				logger.Error("event handling failed", "topic", e.Topic(), "duration", time.Since(start), "error", err)
+				return err
+			}
+			logger.Info("event handled", "topic", e.Topic(), "duration", time.Since(start))
+			return nil
+		}
+	}
+}
+
+// WithRecovery recovers from panics of the handler and returns them as ErrHandlerPanicked.
+func WithRecovery() Middleware {
+	return func(next EventHandlerFn) EventHandlerFn {
+		return func(e Event) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = errors.Join(ErrHandlerPanicked, errors.New(fmt.Sprint(r)))
+				}
+			}()
+			return next(e)
+		}
+	}
+}
+
+// WithRetry retries a failed handler after the given delay by using stability.Retry.
+func WithRetry(maxRetries int, delay time.Duration) Middleware {
+	return func(next EventHandlerFn) EventHandlerFn {
+		return fromFunction(stability.Retry(toFunction(next), maxRetries, delay))
+	}
+}
+
+// WithTimeout returns context.DeadlineExceeded if the handler does not return in time
+// by using stability.Timeout.
+//
+// Handlers have no context and cannot be canceled, so a timed out handler keeps running
+// in the background while the next event is handled. This breaks the order of events and
+// the deduplication of WithIdempotency, since a redelivered event may be handled while its
+// first call is still running. Only use it for handlers that may run concurrently.
+func WithTimeout(duration time.Duration) Middleware {
+	return func(next EventHandlerFn) EventHandlerFn {
+		return fromFunction(stability.Timeout(toFunction(next), duration))
+	}
+}
+
+// fromFunction adapts a service.Function to an EventHandlerFn.
+func fromFunction(fn service.Function[Event, struct{}]) EventHandlerFn {
+	return func(e Event) error {
+		_, err := fn(context.Background(), e)
+		return err
+	}
+}
+
+// toFunction adapts an EventHandlerFn to a service.Function used by the stability patterns.
+func toFunction(handler EventHandlerFn) service.Function[Event, struct{}] {
+	return func(_ context.Context, e Event) (struct{}, error) {
+		return struct{}{}, handler(e)
+	}
+}
@@ -0,0 +1,174 @@
+package event_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/event"
+	"github.com/andygeiss/cloud-native-utils/resource"
+	"github.com/andygeiss/cloud-native-utils/stability"
+)
+
+type identifiedEvent struct {
+	ID string
+}
+
+func (e identifiedEvent) Topic() string   { return "orders.placed" }
+func (e identifiedEvent) EventID() string { return e.ID }
+
+func Test_Chain_With_Middlewares_Should_WrapInOrder(t *testing.T) {
+	// Arrange
+	var calls []string
+	record := func(name string) event.Middleware {
+		return func(next event.EventHandlerFn) event.EventHandlerFn {
+			return func(e event.Event) error {
+				calls = append(calls, name)
+				return next(e)
+			}
+		}
+	}
+	handler := event.Chain(func(e event.Event) error {
+		calls = append(calls, "handler")
+		return nil
+	}, record("first"), record("second"))
+
+	// Act
+	err := handler(orderPlaced{})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "calls must be correct", calls, []string{"first", "second", "handler"})
+}
+
+func Test_WithRetry_With_TransientError_Should_Succeed(t *testing.T) {
+	// Arrange
+	var calls int
+	handler := event.Chain(func(e event.Event) error {
+		calls++
+		if calls < 3 {
+			return errors.New("error")
+		}
+		return nil
+	}, event.WithRetry(3, time.Millisecond))
+
+	// Act
+	err := handler(orderPlaced{})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "calls must be 3", calls, 3)
+}
+
+func Test_WithTimeout_With_SlowHandler_Should_ReturnDeadlineExceeded(t *testing.T) {
+	// Arrange
+	handler := event.Chain(func(e event.Event) error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}, event.WithTimeout(time.Millisecond))
+
+	// Act
+	err := handler(orderPlaced{})
+
+	// Assert
+	assert.That(t, "err must be DeadlineExceeded", err, context.DeadlineExceeded)
+}
+
+func Test_WithBreaker_With_Failures_Should_OpenCircuit(t *testing.T) {
+	// Arrange
+	var calls int
+	handler := event.Chain(func(e event.Event) error {
+		calls++
+		return errors.New("error")
+	}, event.WithBreaker(2))
+
+	// Act
+	_ = handler(orderPlaced{})
+	_ = handler(orderPlaced{})
+	err := handler(orderPlaced{})
+
+	// Assert
+	assert.That(t, "err must be ErrBreakerServiceUnavailable", err, stability.ErrBreakerServiceUnavailable)
+	assert.That(t, "calls must be 2", calls, 2)
+}
+
+func Test_WithRecovery_With_Panic_Should_ReturnErrHandlerPanicked(t *testing.T) {
+	// Arrange
+	handler := event.Chain(func(e event.Event) error {
+		panic("boom")
+	}, event.WithRecovery())
+
+	// Act
+	err := handler(orderPlaced{})
+
+	// Assert
+	assert.That(t, "err must be ErrHandlerPanicked", errors.Is(err, event.ErrHandlerPanicked), true)
+	assert.That(t, "err must contain panic value", strings.Contains(err.Error(), "boom"), true)
+}
+
+func Test_WithLogging_With_Error_Should_LogTopicAndError(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	handler := event.Chain(func(e event.Event) error {
+		return errors.New("boom")
+	}, event.WithLogging(logger))
+
+	// Act
+	err := handler(orderPlaced{})
+
+	// Assert
+	assert.That(t, "err must not be nil", err != nil, true)
+	assert.That(t, "log must contain topic", strings.Contains(buf.String(), `"topic":"orders.placed"`), true)
+	assert.That(t, "log must contain error", strings.Contains(buf.String(), `"error":"boom"`), true)
+}
+
+func Test_WithIdempotency_With_Duplicates_Should_HandleOnce(t *testing.T) {
+	// Arrange
+	var calls int
+	processed := resource.NewInMemoryAccess[string, time.Time]()
+	handler := event.Chain(func(e event.Event) error {
+		calls++
+		return nil
+	}, event.WithIdempotency(processed))
+
+	// Act
+	err := handler(identifiedEvent{ID: "event-1"})
+	err2 := handler(identifiedEvent{ID: "event-1"})
+	err3 := handler(identifiedEvent{ID: "event-2"})
+	err4 := handler(orderPlaced{})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "err3 must be nil", err3, nil)
+	assert.That(t, "err4 must be nil", err4, nil)
+	assert.That(t, "calls must be 3", calls, 3)
+}
+
+func Test_WithIdempotency_With_FailedHandler_Should_AllowRetry(t *testing.T) {
+	// Arrange
+	var calls int
+	processed := resource.NewInMemoryAccess[string, time.Time]()
+	handler := event.Chain(func(e event.Event) error {
+		calls++
+		if calls == 1 {
+			return errors.New("error")
+		}
+		return nil
+	}, event.WithIdempotency(processed))
+
+	// Act
+	err := handler(identifiedEvent{ID: "event-1"})
+	err2 := handler(identifiedEvent{ID: "event-1"})
+
+	// Assert
+	assert.That(t, "err must not be nil", err != nil, true)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "calls must be 2", calls, 2)
+}
@@ -0,0 +1,266 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/messaging"
+	"github.com/andygeiss/cloud-native-utils/resource"
+)
+
+var (
+	ErrNoEventSource = errors.New("no event source")
+)
+
+// Checkpoint is the position of the latest event applied by a projection.
+type Checkpoint struct {
+	Projection string    `json:"projection"`
+	Position   uint64    `json:"position"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ProjectionOptions configures a Projection.
+type ProjectionOptions struct {
+	// Checkpoints stores the checkpoint of each projection by its name. Default: in-memory.
+	Checkpoints resource.Access[string, Checkpoint]
+
+	// Reset removes the read model before a rebuild. Default: none, handlers overwrite the values.
+	Reset func(ctx context.Context) error
+
+	// Source replays the events to rebuild the read model, catch up and report the lag. Default: none.
+	Source EventSource
+}
+
+// Projection builds a read model from the events of its topics.
+// Handlers are added by using When and are called one at a time in the order of the events.
+// The source position of the latest applied event is stored as checkpoint. Since the positions
+// don't depend on the topics, events of topics added later are only applied before the
+// checkpoint by a Rebuild.
+type Projection[K comparable, V any] struct {
+	name      string
+	options   ProjectionOptions
+	readModel resource.Access[K, V]
+	handlers  map[string]projectionHandler
+	mutex     sync.Mutex
+}
+
+// projectionHandler decodes and applies the events of a topic.
+type projectionHandler struct {
+	factory EventFactoryFn
+	fn      func(ctx context.Context, e Event) error
+}
+
+// NewProjection creates a new Projection instance updating the given read model.
+func NewProjection[K comparable, V any](name string, readModel resource.Access[K, V]) *Projection[K, V] {
+	return NewProjectionWithOptions(name, readModel, ProjectionOptions{})
+}
+
+// NewProjectionWithOptions creates a new Projection instance with the given options.
+func NewProjectionWithOptions[K comparable, V any](name string, readModel resource.Access[K, V], options ProjectionOptions) *Projection[K, V] {
+	if options.Checkpoints == nil {
+		options.Checkpoints = resource.NewInMemoryAccess[string, Checkpoint]()
+	}
+	return &Projection[K, V]{
+		name:      name,
+		options:   options,
+		readModel: readModel,
+		handlers:  make(map[string]projectionHandler),
+	}
+}
+
+// When adds the handler applying the events of type T published to the topic to the read model.
+// A topic is handled by a single event type, so adding a handler for a topic again replaces it.
+func When[T Event, K comparable, V any](projection *Projection[K, V], topic string, handler func(ctx context.Context, readModel resource.Access[K, V], e T) error) {
+	projection.mutex.Lock()
+	defer projection.mutex.Unlock()
+	projection.handlers[topic] = projectionHandler{
+		factory: newEventFactory[T](),
+		fn: func(ctx context.Context, e Event) error {
+			return handler(ctx, projection.readModel, convertEvent[T](e))
+		},
+	}
+}
+
+// CatchUp applies the events of the source after the checkpoint.
+func (a *Projection[K, V]) CatchUp(ctx context.Context) error {
+	if a.options.Source == nil {
+		return ErrNoEventSource
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	checkpoint, err := a.checkpoint(ctx)
+	if err != nil {
+		return err
+	}
+	return a.replay(ctx, checkpoint.Position)
+}
+
+// Checkpoint returns the position of the latest applied event.
+func (a *Projection[K, V]) Checkpoint(ctx context.Context) (Checkpoint, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.checkpoint(ctx)
+}
+
+// Lag returns the number of events of the source, which have not been applied yet.
+func (a *Projection[K, V]) Lag(ctx context.Context) (uint64, error) {
+	if a.options.Source == nil {
+		return 0, ErrNoEventSource
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	checkpoint, err := a.checkpoint(ctx)
+	if err != nil {
+		return 0, err
+	}
+	head, err := a.options.Source.Head(ctx, a.topics())
+	if err != nil || head <= checkpoint.Position {
+		return 0, err
+	}
+
+	// Count the events after the checkpoint, since positions include the events of other topics.
+	var lag uint64
+	err = a.options.Source.ReadEvents(ctx, a.topics(), checkpoint.Position, func(_ uint64, _ Event) error {
+		lag++
+		return nil
+	})
+	return lag, err
+}
+
+// Name returns the name of the projection.
+func (a *Projection[K, V]) Name() string {
+	return a.name
+}
+
+// Rebuild resets the read model and applies all events of the source from the beginning.
+func (a *Projection[K, V]) Rebuild(ctx context.Context) error {
+	if a.options.Source == nil {
+		return ErrNoEventSource
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.options.Reset != nil {
+		if err := a.options.Reset(ctx); err != nil {
+			return err
+		}
+	}
+	if err := a.save(ctx, 0); err != nil {
+		return err
+	}
+	return a.replay(ctx, 0)
+}
+
+// Start subscribes to the topics of the projection and applies their events as they are published.
+// With a source, each published event triggers applying the events of the source after the checkpoint,
+// so that the checkpoint is the source position of the latest applied event, and events which are
+// not in the source yet are applied by a later event or CatchUp. Without a source, published events
+// are applied directly and do not change the checkpoint, since they have no position.
+// Failed events are not checkpointed.
+func (a *Projection[K, V]) Start(ctx context.Context, subscriber EventSubscriber) error {
+	a.mutex.Lock()
+	handlers := make(map[string]projectionHandler, len(a.handlers))
+	for topic, handler := range a.handlers {
+		handlers[topic] = handler
+	}
+	a.mutex.Unlock()
+
+	for topic, handler := range handlers {
+		err := subscriber.Subscribe(ctx, topic, handler.factory, func(e Event) error {
+			a.mutex.Lock()
+			defer a.mutex.Unlock()
+
+			if a.options.Source == nil {
+				return handler.fn(ctx, e)
+			}
+			checkpoint, err := a.checkpoint(ctx)
+			if err != nil {
+				return err
+			}
+			return a.replay(ctx, checkpoint.Position)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkpoint reads the checkpoint of the projection, which is zero if it has not been stored yet.
+func (a *Projection[K, V]) checkpoint(ctx context.Context) (Checkpoint, error) {
+	checkpoint, err := a.options.Checkpoints.Read(ctx, a.name)
+	if err != nil {
+		if err.Error() == resource.ErrorResourceNotFound {
+			return Checkpoint{Projection: a.name}, nil
+		}
+		return Checkpoint{}, err
+	}
+	return *checkpoint, nil
+}
+
+// replay applies the events of the source after the position and advances the checkpoint.
+func (a *Projection[K, V]) replay(ctx context.Context, after uint64) error {
+	return a.options.Source.ReadEvents(ctx, a.topics(), after, func(position uint64, e Event) error {
+		// Apply the event by using the handler of the first matching topic.
+		for _, topic := range a.topics() {
+			if !matchTopic(topic, e.Topic()) {
+				continue
+			}
+			if err := a.handlers[topic].fn(ctx, e); err != nil {
+				return err
+			}
+			break
+		}
+		return a.save(ctx, position)
+	})
+}
+
+// save stores the checkpoint at the given position.
+func (a *Projection[K, V]) save(ctx context.Context, position uint64) error {
+	checkpoint := Checkpoint{Projection: a.name, Position: position, UpdatedAt: time.Now().UTC()}
+	if err := a.options.Checkpoints.Update(ctx, a.name, checkpoint); err != nil {
+		if err.Error() != resource.ErrorResourceNotFound {
+			return err
+		}
+		return a.options.Checkpoints.Create(ctx, a.name, checkpoint)
+	}
+	return nil
+}
+
+// topics returns the sorted topics of the handlers.
+func (a *Projection[K, V]) topics() []string {
+	topics := make([]string, 0, len(a.handlers))
+	for topic := range a.handlers {
+		topics = append(topics, topic)
+	}
+	slices.Sort(topics)
+	return topics
+}
+
+// matchTopic reports whether the topic matches the topic pattern of a handler.
+func matchTopic(pattern, topic string) bool {
+	p, err := messaging.ParseTopicPattern(pattern)
+	return err == nil && p.Match(topic)
+}
+
+// newEventFactory returns a factory creating empty events of type T.
+func newEventFactory[T Event]() EventFactoryFn {
+	typ := reflect.TypeFor[T]()
+	return func() Event {
+		if typ.Kind() == reflect.Pointer {
+			e, _ := reflect.New(typ.Elem()).Interface().(Event)
+			return e
+		}
+		var zero T
+		return zero
+	}
+}
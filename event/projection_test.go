@@ -0,0 +1,256 @@
+package event_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/event"
+	"github.com/andygeiss/cloud-native-utils/resource"
+)
+
+type orderView struct {
+	OrderID string
+	Status  string
+	Amount  int
+}
+
+func newOrderProjection(source event.EventSource, readModel *resource.InMemoryAccess[string, orderView]) *event.Projection[string, orderView] {
+	projection := event.NewProjectionWithOptions("orders", readModel, event.ProjectionOptions{
+		Source: source,
+		Reset: func(ctx context.Context) error {
+			views, _ := readModel.ReadAll(ctx)
+			for _, view := range views {
+				_ = readModel.Delete(ctx, view.OrderID)
+			}
+			return nil
+		},
+	})
+	event.When(projection, "orders.placed", func(ctx context.Context, readModel resource.Access[string, orderView], e orderPlaced) error {
+		view, err := readModel.Read(ctx, e.OrderID)
+		if err != nil {
+			return readModel.Create(ctx, e.OrderID, orderView{OrderID: e.OrderID, Status: "placed", Amount: e.Amount})
+		}
+		view.Amount += e.Amount
+		return readModel.Update(ctx, e.OrderID, *view)
+	})
+	event.When(projection, "orders.cancelled", func(ctx context.Context, readModel resource.Access[string, orderView], e *orderCancelled) error {
+		view, err := readModel.Read(ctx, e.OrderID)
+		if err != nil {
+			return err
+		}
+		view.Status = "cancelled"
+		return readModel.Update(ctx, e.OrderID, *view)
+	})
+	return projection
+}
+
+func Test_Projection_With_Start_Should_UpdateReadModel(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewInMemoryAdapter()
+	readModel := resource.NewInMemoryAccess[string, orderView]()
+	projection := newOrderProjection(adapter, readModel)
+
+	// Act
+	err := projection.Start(ctx, adapter)
+	_ = adapter.Publish(ctx, orderPlaced{OrderID: "order-1", Amount: 40})
+	_ = adapter.Publish(ctx, orderPlaced{OrderID: "order-1", Amount: 2})
+	_ = adapter.Publish(ctx, &orderCancelled{OrderID: "order-1"})
+	view, _ := readModel.Read(ctx, "order-1")
+	checkpoint, _ := projection.Checkpoint(ctx)
+	lag, _ := projection.Lag(ctx)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "view must be correct", *view, orderView{OrderID: "order-1", Status: "cancelled", Amount: 42})
+	assert.That(t, "checkpoint must be 3", checkpoint.Position, uint64(3))
+	assert.That(t, "lag must be 0", lag, uint64(0))
+}
+
+func Test_Projection_With_StartAfterPublishedEvents_Should_CheckpointSourcePosition(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewInMemoryAdapter()
+	readModel := resource.NewInMemoryAccess[string, orderView]()
+	projection := newOrderProjection(adapter, readModel)
+	_ = adapter.Publish(ctx, orderPlaced{OrderID: "order-1", Amount: 40})
+	_ = adapter.Publish(ctx, orderPlaced{OrderID: "order-1", Amount: 2})
+	_ = projection.Start(ctx, adapter)
+
+	// Act
+	err := adapter.Publish(ctx, &orderCancelled{OrderID: "order-1"})
+	view, _ := readModel.Read(ctx, "order-1")
+	checkpoint, _ := projection.Checkpoint(ctx)
+	lag, _ := projection.Lag(ctx)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "view must be correct", *view, orderView{OrderID: "order-1", Status: "cancelled", Amount: 42})
+	assert.That(t, "checkpoint must be 3", checkpoint.Position, uint64(3))
+	assert.That(t, "lag must be 0", lag, uint64(0))
+}
+
+func Test_Projection_Without_Source_Should_NotChangeCheckpoint(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewInMemoryAdapter()
+	readModel := resource.NewInMemoryAccess[string, orderView]()
+	projection := newOrderProjection(nil, readModel)
+	_ = projection.Start(ctx, adapter)
+
+	// Act
+	err := adapter.Publish(ctx, orderPlaced{OrderID: "order-1", Amount: 42})
+	view, _ := readModel.Read(ctx, "order-1")
+	checkpoint, _ := projection.Checkpoint(ctx)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "view must be correct", *view, orderView{OrderID: "order-1", Status: "placed", Amount: 42})
+	assert.That(t, "checkpoint must be 0", checkpoint.Position, uint64(0))
+}
+
+func Test_Projection_With_SqliteCheckpoints_Should_StoreCheckpoint(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "checkpoints.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	checkpoints := resource.NewSqliteAccess[string, event.Checkpoint](db)
+	if err := checkpoints.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	adapter := event.NewInMemoryAdapter()
+	readModel := resource.NewInMemoryAccess[string, orderView]()
+	projection := event.NewProjectionWithOptions("orders", readModel, event.ProjectionOptions{Checkpoints: checkpoints, Source: adapter})
+	event.When(projection, "orders.placed", func(ctx context.Context, readModel resource.Access[string, orderView], e orderPlaced) error {
+		return nil
+	})
+	_ = adapter.Publish(ctx, orderPlaced{OrderID: "order-1"})
+	_ = projection.CatchUp(ctx)
+	_ = adapter.Publish(ctx, orderPlaced{OrderID: "order-2"})
+
+	// Act
+	err = projection.CatchUp(ctx)
+	checkpoint, err2 := checkpoints.Read(ctx, "orders")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "checkpoint must be 2", checkpoint.Position, uint64(2))
+}
+
+func Test_Projection_With_CatchUp_Should_ApplyMissedEvents(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewInMemoryAdapter()
+	_ = adapter.Publish(ctx, orderPlaced{OrderID: "order-1", Amount: 42})
+	_ = adapter.Publish(ctx, &orderCancelled{OrderID: "order-1"})
+	_ = adapter.Publish(ctx, orderEvent{OrderID: "order-1"})
+	readModel := resource.NewInMemoryAccess[string, orderView]()
+	projection := newOrderProjection(adapter, readModel)
+	lagBefore, _ := projection.Lag(ctx)
+
+	// Act
+	err := projection.CatchUp(ctx)
+	lagAfter, _ := projection.Lag(ctx)
+	view, _ := readModel.Read(ctx, "order-1")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "lag before must be 2", lagBefore, uint64(2))
+	assert.That(t, "lag after must be 0", lagAfter, uint64(0))
+	assert.That(t, "view must be correct", *view, orderView{OrderID: "order-1", Status: "cancelled", Amount: 42})
+}
+
+func Test_Projection_With_Rebuild_Should_ResetAndReplayAllEvents(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewInMemoryAdapter()
+	readModel := resource.NewInMemoryAccess[string, orderView]()
+	projection := newOrderProjection(adapter, readModel)
+	_ = projection.Start(ctx, adapter)
+	_ = adapter.Publish(ctx, orderPlaced{OrderID: "order-1", Amount: 42})
+	_ = readModel.Update(ctx, "order-1", orderView{OrderID: "order-1", Status: "corrupted"})
+
+	// Act
+	err := projection.Rebuild(ctx)
+	view, _ := readModel.Read(ctx, "order-1")
+	checkpoint, _ := projection.Checkpoint(ctx)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "view must be correct", *view, orderView{OrderID: "order-1", Status: "placed", Amount: 42})
+	assert.That(t, "checkpoint must be 1", checkpoint.Position, uint64(1))
+}
+
+func Test_Projection_With_FailingHandler_Should_NotAdvanceCheckpoint(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewInMemoryAdapter()
+	readModel := resource.NewInMemoryAccess[string, orderView]()
+	projection := newOrderProjection(adapter, readModel)
+	_ = projection.Start(ctx, adapter)
+
+	// Act
+	err := adapter.Publish(ctx, &orderCancelled{OrderID: "order-1"})
+	checkpoint, _ := projection.Checkpoint(ctx)
+	lag, _ := projection.Lag(ctx)
+
+	// Assert
+	assert.That(t, "err must not be nil", err != nil, true)
+	assert.That(t, "checkpoint must be 0", checkpoint.Position, uint64(0))
+	assert.That(t, "lag must be 1", lag, uint64(1))
+}
+
+func Test_Projection_Without_Source_Should_ReturnErrNoEventSource(t *testing.T) {
+	// Arrange
+	projection := event.NewProjection("orders", resource.NewInMemoryAccess[string, orderView]())
+
+	// Act
+	err := projection.Rebuild(context.Background())
+	_, err2 := projection.Lag(context.Background())
+
+	// Assert
+	assert.That(t, "err must be ErrNoEventSource", errors.Is(err, event.ErrNoEventSource), true)
+	assert.That(t, "err2 must be ErrNoEventSource", errors.Is(err2, event.ErrNoEventSource), true)
+}
+
+func Test_Projection_With_AddedTopic_Should_KeepCheckpoint(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewInMemoryAdapter()
+	checkpoints := resource.NewInMemoryAccess[string, event.Checkpoint]()
+	readModel := resource.NewInMemoryAccess[string, orderView]()
+	placed := event.NewProjectionWithOptions("orders", readModel, event.ProjectionOptions{Checkpoints: checkpoints, Source: adapter})
+	event.When(placed, "orders.placed", func(ctx context.Context, readModel resource.Access[string, orderView], e orderPlaced) error {
+		return readModel.Create(ctx, e.OrderID, orderView{OrderID: e.OrderID, Status: "placed", Amount: e.Amount})
+	})
+	_ = adapter.Publish(ctx, orderPlaced{OrderID: "order-1", Amount: 42})
+	_ = adapter.Publish(ctx, &orderCancelled{OrderID: "order-2"})
+	_ = adapter.Publish(ctx, orderPlaced{OrderID: "order-3", Amount: 7})
+	_ = placed.CatchUp(ctx)
+	projection := event.NewProjectionWithOptions("orders", readModel, event.ProjectionOptions{Checkpoints: checkpoints, Source: adapter})
+	event.When(projection, "orders.placed", func(ctx context.Context, readModel resource.Access[string, orderView], e orderPlaced) error {
+		return readModel.Create(ctx, e.OrderID, orderView{OrderID: e.OrderID, Status: "placed", Amount: e.Amount})
+	})
+	event.When(projection, "orders.cancelled", func(ctx context.Context, readModel resource.Access[string, orderView], e *orderCancelled) error {
+		return errors.New("must not be applied")
+	})
+
+	// Act
+	lag, err := projection.Lag(ctx)
+	err2 := projection.CatchUp(ctx)
+	checkpoint, _ := projection.Checkpoint(ctx)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "lag must be 0", lag, uint64(0))
+	assert.That(t, "checkpoint must be 3", checkpoint.Position, uint64(3))
+}
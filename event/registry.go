@@ -0,0 +1,133 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+)
+
+var (
+	ErrEventTypeRegistered = errors.New("event type already registered")
+	ErrInvalidEventVersion = errors.New("invalid event version")
+	ErrUnknownEventType    = errors.New("unknown event type")
+)
+
+// Registry maps the type names and versions of events to their factories.
+// It encodes events into envelopes and decodes envelopes into events of the registered types.
+type Registry struct {
+	names map[registryKey]EventFactoryFn
+	types map[reflect.Type]registryKey
+	mutex sync.RWMutex
+}
+
+// registryKey identifies a version of an event type.
+type registryKey struct {
+	name    string
+	version int
+}
+
+// NewRegistry creates a new Registry instance.
+func NewRegistry() *Registry {
+	return &Registry{
+		names: make(map[registryKey]EventFactoryFn),
+		types: make(map[reflect.Type]registryKey),
+	}
+}
+
+// Decode decodes an envelope into a new event created by the factory of its type name and version.
+func (a *Registry) Decode(data []byte) (Event, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+	return a.Unwrap(envelope)
+}
+
+// Encode encodes the event into an envelope with the latest registered version of its type.
+func (a *Registry) Encode(e Event) ([]byte, error) {
+	envelope, err := a.Wrap(e)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope)
+}
+
+// Name returns the registered type name of the event.
+func (a *Registry) Name(e Event) (string, bool) {
+	key, ok := a.lookup(e)
+	return key.name, ok
+}
+
+// Register adds the factory of a version of an event type.
+// Events of the type returned by the factory, or a pointer to it, are encoded with the
+// latest version registered for the type. Older versions are only used for decoding,
+// and their factories may return other types.
+func (a *Registry) Register(name string, version int, factory EventFactoryFn) error {
+	if version < 1 {
+		return ErrInvalidEventVersion
+	}
+	e := factory()
+	if e == nil {
+		return ErrNilEvent
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	// Each version must be registered once, and a type must not be used by several names.
+	key := registryKey{name: name, version: version}
+	typ := baseType(reflect.TypeOf(e))
+	existing, ok := a.types[typ]
+	if _, registered := a.names[key]; registered || (ok && existing.name != name) {
+		return ErrEventTypeRegistered
+	}
+	a.names[key] = factory
+	if !ok || existing.version < version {
+		a.types[typ] = key
+	}
+	return nil
+}
+
+// Unwrap decodes the data of an envelope into a new event created by the factory of its type name and version.
+func (a *Registry) Unwrap(envelope Envelope) (Event, error) {
+	a.mutex.RLock()
+	factory, ok := a.names[registryKey{name: envelope.Type, version: envelope.Version}]
+	a.mutex.RUnlock()
+	if !ok {
+		return nil, ErrUnknownEventType
+	}
+	return decodeEvent(factory, envelope.Data)
+}
+
+// Wrap encodes the event as JSON into an envelope with the latest registered version of its type.
+func (a *Registry) Wrap(e Event) (Envelope, error) {
+	key, ok := a.lookup(e)
+	if !ok {
+		return Envelope{}, ErrUnknownEventType
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return Envelope{}, err
+	}
+	return Envelope{Type: key.name, Version: key.version, Data: data}, nil
+}
+
+// lookup returns the key used to encode the event.
+func (a *Registry) lookup(e Event) (registryKey, bool) {
+	if e == nil {
+		return registryKey{}, false
+	}
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	key, ok := a.types[baseType(reflect.TypeOf(e))]
+	return key, ok
+}
+
+// baseType returns the element type of pointer types.
+func baseType(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Pointer {
+		return typ.Elem()
+	}
+	return typ
+}
@@ -0,0 +1,92 @@
+package event_test
+
+import (
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/event"
+)
+
+type orderPlacedV1 struct {
+	OrderID string `json:"order_id"`
+}
+
+func (e orderPlacedV1) Topic() string {
+	return "orders.placed"
+}
+
+func Test_Registry_With_Encode_Should_WrapEventInEnvelope(t *testing.T) {
+	// Arrange
+	registry := event.NewRegistry()
+	_ = registry.Register("OrderPlaced", 1, func() event.Event { return orderPlaced{} })
+
+	// Act
+	data, err := registry.Encode(orderPlaced{OrderID: "order-1", Amount: 42})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "data must be correct", string(data), `{"type":"OrderPlaced","version":1,"data":{"order_id":"order-1","amount":42}}`)
+}
+
+func Test_Registry_With_Decode_Should_CreateRegisteredType(t *testing.T) {
+	// Arrange
+	registry := event.NewRegistry()
+	_ = registry.Register("OrderPlaced", 1, func() event.Event { return orderPlaced{} })
+	_ = registry.Register("OrderCancelled", 1, func() event.Event { return &orderCancelled{} })
+	data, _ := registry.Encode(&orderCancelled{OrderID: "order-1"})
+
+	// Act
+	e, err := registry.Decode(data)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "event must be correct", e, event.Event(&orderCancelled{OrderID: "order-1"}))
+}
+
+func Test_Registry_With_Versions_Should_EncodeLatestAndDecodeAll(t *testing.T) {
+	// Arrange
+	registry := event.NewRegistry()
+	_ = registry.Register("OrderPlaced", 2, func() event.Event { return orderPlaced{} })
+	_ = registry.Register("OrderPlaced", 1, func() event.Event { return orderPlacedV1{} })
+
+	// Act
+	data, err := registry.Encode(orderPlaced{OrderID: "order-1"})
+	e, err2 := registry.Decode([]byte(`{"type":"OrderPlaced","version":1,"data":{"order_id":"order-1"}}`))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "data must use version 2", string(data), `{"type":"OrderPlaced","version":2,"data":{"order_id":"order-1","amount":0}}`)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "event must be version 1", e, event.Event(orderPlacedV1{OrderID: "order-1"}))
+}
+
+func Test_Registry_With_UnknownType_Should_ReturnErrUnknownEventType(t *testing.T) {
+	// Arrange
+	registry := event.NewRegistry()
+
+	// Act
+	_, err := registry.Encode(orderPlaced{})
+	_, err2 := registry.Decode([]byte(`{"type":"OrderPlaced","version":1,"data":{}}`))
+
+	// Assert
+	assert.That(t, "err must be ErrUnknownEventType", err, event.ErrUnknownEventType)
+	assert.That(t, "err2 must be ErrUnknownEventType", err2, event.ErrUnknownEventType)
+}
+
+func Test_Registry_With_InvalidRegistration_Should_ReturnError(t *testing.T) {
+	// Arrange
+	registry := event.NewRegistry()
+	_ = registry.Register("OrderPlaced", 1, func() event.Event { return orderPlaced{} })
+
+	// Act
+	duplicate := registry.Register("OrderPlaced", 1, func() event.Event { return orderPlaced{} })
+	otherName := registry.Register("OrderCreated", 1, func() event.Event { return &orderPlaced{} })
+	version := registry.Register("OrderCancelled", 0, func() event.Event { return &orderCancelled{} })
+	nilEvent := registry.Register("OrderCancelled", 1, func() event.Event { return nil })
+
+	// Assert
+	assert.That(t, "duplicate must be ErrEventTypeRegistered", duplicate, event.ErrEventTypeRegistered)
+	assert.That(t, "otherName must be ErrEventTypeRegistered", otherName, event.ErrEventTypeRegistered)
+	assert.That(t, "version must be ErrInvalidEventVersion", version, event.ErrInvalidEventVersion)
+	assert.That(t, "nilEvent must be ErrNilEvent", nilEvent, event.ErrNilEvent)
+}
@@ -0,0 +1,99 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/andygeiss/cloud-native-utils/messaging"
+)
+
+// Router publishes events in envelopes and dispatches received events to the handlers of their Go types.
+// It allows a topic to carry several event types, e.g. OrderPlaced and OrderCancelled.
+type Router struct {
+	dispatcher messaging.Dispatcher
+	registry   *Registry
+	handlers   map[reflect.Type][]EventHandlerFn
+	mutex      sync.RWMutex
+}
+
+// NewRouter creates a new Router instance.
+func NewRouter(dispatcher messaging.Dispatcher, registry *Registry) *Router {
+	return &Router{
+		dispatcher: dispatcher,
+		registry:   registry,
+		handlers:   make(map[reflect.Type][]EventHandlerFn),
+	}
+}
+
+// Handle adds a handler for events of type T to the router.
+// Events decoded as a value or a pointer of the handled type are converted to T.
+func Handle[T Event](router *Router, handler func(e T) error) {
+	typ := baseType(reflect.TypeFor[T]())
+	router.mutex.Lock()
+	defer router.mutex.Unlock()
+	router.handlers[typ] = append(router.handlers[typ], func(e Event) error {
+		return handler(convertEvent[T](e))
+	})
+}
+
+// convertEvent converts an event to T, which may be a pointer to the type of the event or vice versa.
+func convertEvent[T Event](e Event) T {
+	if value, ok := e.(T); ok {
+		return value
+	}
+	v := reflect.ValueOf(e)
+	if v.Kind() == reflect.Pointer {
+		value, _ := v.Elem().Interface().(T)
+		return value
+	}
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	value, _ := ptr.Interface().(T)
+	return value
+}
+
+// Publish encodes the event into an envelope and publishes it to the topic of the event.
+// The values of the context are propagated by using messaging.InjectContext.
+func (a *Router) Publish(ctx context.Context, e Event) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := a.registry.Encode(e)
+	if err != nil {
+		return err
+	}
+	name, _ := a.registry.Name(e)
+	message := messaging.NewMessage(e.Topic(), data).
+		WithHeader(messaging.HeaderContentType, messaging.ContentTypeJson).
+		WithHeader(HeaderEventType, name)
+	return a.dispatcher.Publish(ctx, messaging.InjectContext(ctx, message))
+}
+
+// Subscribe decodes the envelopes of the topic and calls the handlers of the event types.
+// Messages of unknown types are failed, and events without handlers are completed.
+func (a *Router) Subscribe(ctx context.Context, topic string) error {
+	return a.dispatcher.Subscribe(ctx, topic, func(ctx context.Context, message messaging.Message) (messaging.MessageState, error) {
+		e, err := a.registry.Decode(message.Data)
+		if err != nil {
+			return messaging.MessageStateFailed, err
+		}
+
+		a.mutex.RLock()
+		handlers := a.handlers[baseType(reflect.TypeOf(e))]
+		a.mutex.RUnlock()
+
+		var errs []error
+		for _, handler := range handlers {
+			if err := handler(e); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return messaging.MessageStateFailed, err
+		}
+		return messaging.MessageStateCompleted, nil
+	})
+}
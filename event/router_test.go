@@ -0,0 +1,128 @@
+package event_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/event"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+	"github.com/andygeiss/cloud-native-utils/service"
+)
+
+type orderEvent struct {
+	OrderID string `json:"order_id"`
+}
+
+func (e orderEvent) Topic() string {
+	return "orders"
+}
+
+type orderShipped struct {
+	OrderID string `json:"order_id"`
+}
+
+func (e *orderShipped) Topic() string {
+	return "orders"
+}
+
+func newTestRouter() (*event.Router, messaging.Dispatcher) {
+	registry := event.NewRegistry()
+	_ = registry.Register("OrderEvent", 1, func() event.Event { return orderEvent{} })
+	_ = registry.Register("OrderShipped", 1, func() event.Event { return &orderShipped{} })
+	dis := messaging.NewInternalDispatcher()
+	return event.NewRouter(dis, registry), dis
+}
+
+func Test_Router_With_SeveralTypes_Should_DispatchByType(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	router, _ := newTestRouter()
+	var handled []string
+	event.Handle(router, func(e orderEvent) error {
+		handled = append(handled, "event:"+e.OrderID)
+		return nil
+	})
+	event.Handle(router, func(e *orderShipped) error {
+		handled = append(handled, "shipped:"+e.OrderID)
+		return nil
+	})
+	_ = router.Subscribe(ctx, "orders")
+
+	// Act
+	err := router.Publish(ctx, orderEvent{OrderID: "order-1"})
+	err2 := router.Publish(ctx, &orderShipped{OrderID: "order-1"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "handled must be correct", handled, []string{"event:order-1", "shipped:order-1"})
+}
+
+func Test_Router_With_ValueHandlerForPointerType_Should_ConvertEvent(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	router, _ := newTestRouter()
+	var handled []string
+	event.Handle(router, func(e *orderEvent) error {
+		handled = append(handled, e.OrderID)
+		return nil
+	})
+	_ = router.Subscribe(ctx, "orders")
+
+	// Act
+	err := router.Publish(ctx, orderEvent{OrderID: "order-1"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "handled must be correct", handled, []string{"order-1"})
+}
+
+func Test_Router_With_Publish_Should_SetEventTypeHeader(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	router, dis := newTestRouter()
+	var received messaging.Message
+	_ = dis.Subscribe(ctx, "orders", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		received = msg
+		return messaging.MessageStateCompleted, nil
+	}))
+
+	// Act
+	err := router.Publish(ctx, &orderShipped{OrderID: "order-1"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "event type must be correct", received.Headers[event.HeaderEventType], "OrderShipped")
+}
+
+func Test_Router_With_UnknownType_Should_FailMessage(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	router, dis := newTestRouter()
+	_ = router.Subscribe(ctx, "orders")
+
+	// Act
+	err := dis.Publish(ctx, messaging.NewMessage("orders", []byte(`{"type":"OrderDeleted","version":1,"data":{}}`)))
+
+	// Assert
+	assert.That(t, "err must be ErrUnknownEventType", err, event.ErrUnknownEventType)
+}
+
+func Test_Router_With_FailingHandler_Should_ReturnError(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	router, _ := newTestRouter()
+	handlerErr := errors.New("error")
+	event.Handle(router, func(e orderEvent) error {
+		return handlerErr
+	})
+	_ = router.Subscribe(ctx, "orders")
+
+	// Act
+	err := router.Publish(ctx, orderEvent{OrderID: "order-1"})
+
+	// Assert
+	assert.That(t, "err must be handlerErr", errors.Is(err, handlerErr), true)
+}
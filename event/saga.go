@@ -0,0 +1,462 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/resource"
+	"github.com/andygeiss/cloud-native-utils/security"
+)
+
+var (
+	ErrSagaCommandsPending = errors.New("saga commands pending")
+	ErrSagaStepFailed      = errors.New("saga step failed")
+	ErrSagaStepTimeout     = errors.New("saga step timed out")
+	ErrSagaTopicAmbiguous  = errors.New("saga topic used by several event types")
+)
+
+// CorrelatedEvent is an event that references the saga instance it belongs to.
+type CorrelatedEvent interface {
+	Event
+	CorrelationID() string
+}
+
+// SagaStatus is the status of a saga instance.
+type SagaStatus int
+
+const (
+	// SagaStatusRunning indicates that the saga waits for the current step.
+	SagaStatusRunning SagaStatus = iota
+	// SagaStatusCompleted indicates that all steps completed.
+	SagaStatusCompleted
+	// SagaStatusCompensated indicates that a step failed and the completed steps were compensated.
+	SagaStatusCompensated
+	// SagaStatusFailed indicates that a compensation failed.
+	SagaStatusFailed
+)
+
+// SagaInstance is the persisted state of a running or finished saga.
+type SagaInstance[D any] struct {
+	ID        string        `json:"id"`
+	Data      D             `json:"data"`
+	Step      int           `json:"step"`
+	Status    SagaStatus    `json:"status"`
+	Deadline  time.Time     `json:"deadline,omitzero"`
+	Error     string        `json:"error,omitempty"`
+	Pending   []SagaCommand `json:"pending,omitempty"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// SagaCommand is a command of an instance encoded with its registered type name and version,
+// which is stored with the instance until it has been published, so that it is not lost if publishing fails.
+type SagaCommand struct {
+	ID      string          `json:"id"`
+	Topic   string          `json:"topic"`
+	Type    string          `json:"type"`
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// SagaStep is a step of a saga. Its action is called when the step starts and may return a command,
+// which is stored with the instance and published afterwards. The step completes if the action succeeds
+// and no completion events are defined, or if one of the completion events is received. It fails if the
+// action fails, a failure event is received or the timeout expires. Then the compensations of the
+// completed steps are called in reverse order. Events match if their topic and type equal an event
+// created by the factories.
+type SagaStep[D any] struct {
+	// Name is the name of the step used in errors.
+	Name string
+
+	// Action starts the step, may change the data of the instance and may return a command to publish.
+	Action func(ctx context.Context, instance *SagaInstance[D]) (Event, error)
+
+	// Compensation reverts the step after a later step failed and may return a command to publish. Default: none.
+	Compensation func(ctx context.Context, instance *SagaInstance[D]) (Event, error)
+
+	// CompletedBy creates the events completing the step, which define the subscribed topics. Default: none.
+	CompletedBy []EventFactoryFn
+
+	// FailedBy creates the events failing the step, which define the subscribed topics. Default: none.
+	FailedBy []EventFactoryFn
+
+	// Handle is called with the completion event and may change the data of the instance. Default: none.
+	Handle func(ctx context.Context, instance *SagaInstance[D], e Event) error
+
+	// Timeout limits the duration of waiting for a completion event. Default: none.
+	Timeout time.Duration
+}
+
+// SagaOptions configures a Saga.
+type SagaOptions struct {
+	// CheckInterval is the interval of checking for timed out steps. Default: 1s.
+	CheckInterval time.Duration
+
+	// Correlate returns the saga instance ID of an event. Default: the ID of a CorrelatedEvent.
+	Correlate func(e Event) string
+
+	// Registry encodes the stored commands and decodes them into their types when they are published
+	// by PublishPending. Register the command types to publish the commands stored before a restart.
+	// Default: a new Registry, to which the command types are added by their Go type names.
+	Registry *Registry
+}
+
+// Saga orchestrates a workflow of steps driven by events, and compensates completed
+// steps on failure. The instances are persisted by using a resource.Access, and
+// incoming events are correlated to their instances.
+//
+// Commands are stored with the instance and published after the instance has been stored.
+// If publishing fails, the commands are kept and published again by PublishPending,
+// so that they are published at least once.
+type Saga[D any] struct {
+	instances     resource.Access[string, SagaInstance[D]]
+	options       SagaOptions
+	publisher     EventPublisher
+	registerTypes bool
+	steps         []SagaStep[D]
+	mutex         sync.Mutex
+}
+
+// NewSaga creates a new Saga instance with the given steps.
+// The publisher publishes the commands returned by actions and compensations.
+func NewSaga[D any](publisher EventPublisher, instances resource.Access[string, SagaInstance[D]], steps ...SagaStep[D]) *Saga[D] {
+	return NewSagaWithOptions(publisher, instances, SagaOptions{}, steps...)
+}
+
+// NewSagaWithOptions creates a new Saga instance with the given options and steps.
+func NewSagaWithOptions[D any](publisher EventPublisher, instances resource.Access[string, SagaInstance[D]], options SagaOptions, steps ...SagaStep[D]) *Saga[D] {
+	if options.CheckInterval <= 0 {
+		options.CheckInterval = time.Second
+	}
+	if options.Correlate == nil {
+		options.Correlate = func(e Event) string {
+			if c, ok := e.(CorrelatedEvent); ok {
+				return c.CorrelationID()
+			}
+			return ""
+		}
+	}
+	registerTypes := options.Registry == nil
+	if registerTypes {
+		options.Registry = NewRegistry()
+	}
+	return &Saga[D]{instances: instances, options: options, publisher: publisher, registerTypes: registerTypes, steps: steps}
+}
+
+// CheckTimeouts fails the running instances whose current step timed out.
+func (a *Saga[D]) CheckTimeouts(ctx context.Context) error {
+	var commands []sagaDelivery
+	err := a.locked(func() error {
+		instances, err := a.instances.ReadAll(ctx)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		var errs []error
+		for _, instance := range instances {
+			if instance.Status != SagaStatusRunning || instance.Deadline.IsZero() || now.Before(instance.Deadline) {
+				continue
+			}
+			errs = append(errs, a.compensate(ctx, &instance, ErrSagaStepTimeout, &commands))
+		}
+		return errors.Join(errs...)
+	})
+	return errors.Join(err, a.publish(ctx, commands))
+}
+
+// Handle correlates the event to its instance and completes or fails the current step.
+// Events of unknown or finished instances and of other steps are ignored.
+func (a *Saga[D]) Handle(ctx context.Context, e Event) error {
+	id := a.options.Correlate(e)
+	if id == "" {
+		return nil
+	}
+
+	var commands []sagaDelivery
+	err := a.locked(func() error {
+		instance, err := a.instances.Read(ctx, id)
+		if err != nil {
+			if err.Error() == resource.ErrorResourceNotFound {
+				return nil
+			}
+			return err
+		}
+		if instance.Status != SagaStatusRunning {
+			return nil
+		}
+
+		step := a.steps[instance.Step]
+		switch {
+		case matchEvent(step.FailedBy, e):
+			return a.compensate(ctx, instance, ErrSagaStepFailed, &commands)
+		case matchEvent(step.CompletedBy, e):
+			if step.Handle != nil {
+				if err := step.Handle(ctx, instance, e); err != nil {
+					return a.compensate(ctx, instance, err, &commands)
+				}
+			}
+			instance.Step++
+			return a.run(ctx, instance, &commands)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return a.publish(ctx, commands)
+}
+
+// Instance returns the instance with the given ID.
+func (a *Saga[D]) Instance(ctx context.Context, id string) (*SagaInstance[D], error) {
+	return a.instances.Read(ctx, id)
+}
+
+// PublishPending publishes the stored commands of all instances, which have not been published yet,
+// e.g. because publishing failed or the process stopped. The commands are decoded into their types
+// by the registry, and commands of unknown types are kept.
+func (a *Saga[D]) PublishPending(ctx context.Context) error {
+	var commands []sagaDelivery
+	err := a.locked(func() error {
+		instances, err := a.instances.ReadAll(ctx)
+		if err != nil {
+			return err
+		}
+		var errs []error
+		for _, instance := range instances {
+			for _, pending := range instance.Pending {
+				command, err := a.options.Registry.Unwrap(Envelope{Type: pending.Type, Version: pending.Version, Data: pending.Data})
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				commands = append(commands, sagaDelivery{instanceID: instance.ID, commandID: pending.ID, command: command})
+			}
+		}
+		return errors.Join(errs...)
+	})
+	return errors.Join(err, a.publish(ctx, commands))
+}
+
+// Start creates an instance with the given ID and data, and starts the first step.
+// If publishing the commands fails, the instance has been stored anyway and an error wrapping
+// ErrSagaCommandsPending is returned. Then Start must not be called again, since the commands
+// are published by PublishPending.
+func (a *Saga[D]) Start(ctx context.Context, id string, data D) error {
+	var commands []sagaDelivery
+	err := a.locked(func() error {
+		instance := &SagaInstance[D]{ID: id, Data: data, Status: SagaStatusRunning, UpdatedAt: time.Now().UTC()}
+		if err := a.instances.Create(ctx, id, *instance); err != nil {
+			return err
+		}
+		return a.run(ctx, instance, &commands)
+	})
+	if err != nil {
+		return err
+	}
+	return a.publish(ctx, commands)
+}
+
+// Subscribe subscribes to the topics of the completion and failure events of all steps.
+// Since the events of a topic are decoded by a single factory, it returns ErrSagaTopicAmbiguous
+// if a topic is used by several event types. Then use a Router, whose handlers call Handle.
+func (a *Saga[D]) Subscribe(ctx context.Context, subscriber EventSubscriber) error {
+	factories := make(map[string]EventFactoryFn)
+	for _, step := range a.steps {
+		for _, factory := range slices.Concat(step.CompletedBy, step.FailedBy) {
+			topic := factory().Topic()
+			if existing, ok := factories[topic]; ok && eventType(existing()) != eventType(factory()) {
+				return ErrSagaTopicAmbiguous
+			}
+			factories[topic] = factory
+		}
+	}
+	for topic, factory := range factories {
+		err := subscriber.Subscribe(ctx, topic, factory, func(e Event) error {
+			return a.Handle(ctx, e)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WatchTimeouts checks for timed out steps and publishes pending commands periodically until the context is done.
+func (a *Saga[D]) WatchTimeouts(ctx context.Context) {
+	ticker := time.NewTicker(a.options.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = a.CheckTimeouts(ctx)
+			_ = a.PublishPending(ctx)
+		}
+	}
+}
+
+// compensate calls the compensations of the completed steps in reverse order and stores the result.
+func (a *Saga[D]) compensate(ctx context.Context, instance *SagaInstance[D], reason error, commands *[]sagaDelivery) error {
+	instance.Status = SagaStatusCompensated
+	instance.Error = a.steps[instance.Step].Name + ": " + reason.Error()
+	instance.Deadline = time.Time{}
+	for i := instance.Step - 1; i >= 0; i-- {
+		if a.steps[i].Compensation == nil {
+			continue
+		}
+		command, err := a.steps[i].Compensation(ctx, instance)
+		if err != nil {
+			instance.Status = SagaStatusFailed
+			instance.Error += "; " + a.steps[i].Name + ": " + err.Error()
+			break
+		}
+		if err := a.enqueue(instance, command, commands); err != nil {
+			return err
+		}
+	}
+	return a.save(ctx, instance)
+}
+
+// enqueue stores the command with the instance and adds it to the commands to publish.
+func (a *Saga[D]) enqueue(instance *SagaInstance[D], command Event, commands *[]sagaDelivery) error {
+	if command == nil {
+		return nil
+	}
+	if err := a.register(command); err != nil {
+		return err
+	}
+	envelope, err := a.options.Registry.Wrap(command)
+	if err != nil {
+		return err
+	}
+	id := security.GenerateID()
+	instance.Pending = append(instance.Pending, SagaCommand{
+		ID:      id,
+		Topic:   command.Topic(),
+		Type:    envelope.Type,
+		Version: envelope.Version,
+		Data:    envelope.Data,
+	})
+	*commands = append(*commands, sagaDelivery{instanceID: instance.ID, commandID: id, command: command})
+	return nil
+}
+
+// register adds the type of the command to the default registry by its Go type name.
+func (a *Saga[D]) register(command Event) error {
+	if !a.registerTypes {
+		return nil
+	}
+	if _, ok := a.options.Registry.Name(command); ok {
+		return nil
+	}
+	typ := eventType(command)
+	pointer := reflect.TypeOf(command).Kind() == reflect.Pointer
+	factory := func() Event {
+		value := reflect.New(typ)
+		if !pointer {
+			value = value.Elem()
+		}
+		e, _ := value.Interface().(Event)
+		return e
+	}
+	err := a.options.Registry.Register(typ.PkgPath()+"."+typ.Name(), 1, factory)
+	if err != nil && !errors.Is(err, ErrEventTypeRegistered) {
+		return err
+	}
+	return nil
+}
+
+// locked calls fn while holding the lock.
+// Commands are published after releasing it, since their replies may be handled synchronously.
+func (a *Saga[D]) locked(fn func() error) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return fn()
+}
+
+// publish publishes the commands in order and removes each published command from its instance.
+// It stops at the first failure, and the remaining commands are kept as pending.
+func (a *Saga[D]) publish(ctx context.Context, commands []sagaDelivery) error {
+	for _, delivery := range commands {
+		if err := a.publisher.Publish(ctx, delivery.command); err != nil {
+			return errors.Join(ErrSagaCommandsPending, err)
+		}
+		err := a.locked(func() error {
+			instance, err := a.instances.Read(ctx, delivery.instanceID)
+			if err != nil {
+				return err
+			}
+			pending := len(instance.Pending)
+			instance.Pending = slices.DeleteFunc(slices.Clone(instance.Pending), func(command SagaCommand) bool {
+				return command.ID == delivery.commandID
+			})
+			if len(instance.Pending) == pending {
+				return nil
+			}
+			return a.save(ctx, instance)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run starts the steps from the current step until a step waits for an event or all steps completed.
+func (a *Saga[D]) run(ctx context.Context, instance *SagaInstance[D], commands *[]sagaDelivery) error {
+	for instance.Step < len(a.steps) {
+		step := a.steps[instance.Step]
+		command, err := step.Action(ctx, instance)
+		if err != nil {
+			return a.compensate(ctx, instance, err, commands)
+		}
+		if err := a.enqueue(instance, command, commands); err != nil {
+			return err
+		}
+		if len(step.CompletedBy) > 0 {
+			instance.Deadline = time.Time{}
+			if step.Timeout > 0 {
+				instance.Deadline = time.Now().Add(step.Timeout).UTC()
+			}
+			return a.save(ctx, instance)
+		}
+		instance.Step++
+	}
+	instance.Status = SagaStatusCompleted
+	instance.Deadline = time.Time{}
+	return a.save(ctx, instance)
+}
+
+// save stores the instance.
+func (a *Saga[D]) save(ctx context.Context, instance *SagaInstance[D]) error {
+	instance.UpdatedAt = time.Now().UTC()
+	return a.instances.Update(ctx, instance.ID, *instance)
+}
+
+// sagaDelivery is a command to publish with the IDs of its instance and pending entry.
+type sagaDelivery struct {
+	instanceID string
+	commandID  string
+	command    Event
+}
+
+// eventType returns the type of an event, regardless of whether it is a pointer.
+func eventType(e Event) reflect.Type {
+	if e == nil {
+		return nil
+	}
+	return baseType(reflect.TypeOf(e))
+}
+
+// matchEvent reports whether the topic and type of the event equal those of an event created by the factories.
+func matchEvent(factories []EventFactoryFn, e Event) bool {
+	return slices.ContainsFunc(factories, func(factory EventFactoryFn) bool {
+		expected := factory()
+		return expected.Topic() == e.Topic() && eventType(expected) == eventType(e)
+	})
+}
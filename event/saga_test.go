@@ -0,0 +1,363 @@
+package event_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/event"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+	"github.com/andygeiss/cloud-native-utils/resource"
+)
+
+type sagaCommand struct {
+	Name    string `json:"name"`
+	OrderID string `json:"order_id"`
+}
+
+func (e sagaCommand) Topic() string {
+	return "commands." + e.Name
+}
+
+type stockReserved struct {
+	OrderID string `json:"order_id"`
+}
+
+func (e stockReserved) Topic() string         { return "stock.reserved" }
+func (e stockReserved) CorrelationID() string { return e.OrderID }
+
+type stockRejected struct {
+	OrderID string `json:"order_id"`
+}
+
+func (e stockRejected) Topic() string         { return "stock.rejected" }
+func (e stockRejected) CorrelationID() string { return e.OrderID }
+
+type stockResult struct {
+	OrderID string `json:"order_id"`
+}
+
+func (e stockResult) Topic() string         { return "stock.result" }
+func (e stockResult) CorrelationID() string { return e.OrderID }
+
+type stockResultFailed struct {
+	OrderID string `json:"order_id"`
+}
+
+func (e stockResultFailed) Topic() string         { return "stock.result" }
+func (e stockResultFailed) CorrelationID() string { return e.OrderID }
+
+// flakyPublisher fails to publish events while fail is set.
+type flakyPublisher struct {
+	event.EventPublisher
+	fail bool
+}
+
+func (a *flakyPublisher) Publish(ctx context.Context, e event.Event) error {
+	if a.fail {
+		return errors.New("publish failed")
+	}
+	return a.EventPublisher.Publish(ctx, e)
+}
+
+type orderSagaData struct {
+	Reserved bool   `json:"reserved"`
+	Charge   string `json:"charge"`
+}
+
+func newOrderSaga(adapter *event.InMemoryAdapter, chargeErr error, timeout time.Duration) *event.Saga[orderSagaData] {
+	instances := resource.NewInMemoryAccess[string, event.SagaInstance[orderSagaData]]()
+	return event.NewSaga(adapter, instances,
+		event.SagaStep[orderSagaData]{
+			Name: "reserve stock",
+			Action: func(ctx context.Context, instance *event.SagaInstance[orderSagaData]) (event.Event, error) {
+				return sagaCommand{Name: "reserve", OrderID: instance.ID}, nil
+			},
+			Compensation: func(ctx context.Context, instance *event.SagaInstance[orderSagaData]) (event.Event, error) {
+				return sagaCommand{Name: "release", OrderID: instance.ID}, nil
+			},
+			CompletedBy: []event.EventFactoryFn{func() event.Event { return stockReserved{} }},
+			FailedBy:    []event.EventFactoryFn{func() event.Event { return stockRejected{} }},
+			Handle: func(ctx context.Context, instance *event.SagaInstance[orderSagaData], e event.Event) error {
+				instance.Data.Reserved = true
+				return nil
+			},
+			Timeout: timeout,
+		},
+		event.SagaStep[orderSagaData]{
+			Name: "charge card",
+			Action: func(ctx context.Context, instance *event.SagaInstance[orderSagaData]) (event.Event, error) {
+				if chargeErr != nil {
+					return nil, chargeErr
+				}
+				instance.Data.Charge = "charge-1"
+				return nil, nil
+			},
+		},
+		event.SagaStep[orderSagaData]{
+			Name: "ship",
+			Action: func(ctx context.Context, instance *event.SagaInstance[orderSagaData]) (event.Event, error) {
+				return sagaCommand{Name: "ship", OrderID: instance.ID}, nil
+			},
+		},
+	)
+}
+
+// commandNames returns the names of the published commands.
+func commandNames(adapter *event.InMemoryAdapter) []string {
+	var names []string
+	for _, e := range adapter.Published() {
+		if command, ok := e.(sagaCommand); ok {
+			names = append(names, command.Name)
+		}
+	}
+	return names
+}
+
+// replyWith subscribes to the reserve command and publishes the reply.
+func replyWith(ctx context.Context, adapter *event.InMemoryAdapter, reply func(orderID string) event.Event) {
+	_ = adapter.Subscribe(ctx, "commands.reserve", func() event.Event { return sagaCommand{} }, func(e event.Event) error {
+		command, _ := e.(sagaCommand)
+		return adapter.Publish(ctx, reply(command.OrderID))
+	})
+}
+
+func Test_Saga_With_CompletedSteps_Should_CompleteInstance(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewInMemoryAdapter()
+	saga := newOrderSaga(adapter, nil, 0)
+	_ = saga.Subscribe(ctx, adapter)
+	replyWith(ctx, adapter, func(orderID string) event.Event { return stockReserved{OrderID: orderID} })
+
+	// Act
+	err := saga.Start(ctx, "order-1", orderSagaData{})
+	instance, _ := saga.Instance(ctx, "order-1")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "status must be completed", instance.Status, event.SagaStatusCompleted)
+	assert.That(t, "data must be correct", instance.Data, orderSagaData{Reserved: true, Charge: "charge-1"})
+	assert.That(t, "commands must be correct", commandNames(adapter), []string{"reserve", "ship"})
+}
+
+func Test_Saga_With_FailedAction_Should_CompensateCompletedSteps(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewInMemoryAdapter()
+	saga := newOrderSaga(adapter, errors.New("card declined"), 0)
+	_ = saga.Subscribe(ctx, adapter)
+	replyWith(ctx, adapter, func(orderID string) event.Event { return stockReserved{OrderID: orderID} })
+
+	// Act
+	err := saga.Start(ctx, "order-1", orderSagaData{})
+	instance, _ := saga.Instance(ctx, "order-1")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "status must be compensated", instance.Status, event.SagaStatusCompensated)
+	assert.That(t, "error must be correct", instance.Error, "charge card: card declined")
+	assert.That(t, "commands must be correct", commandNames(adapter), []string{"reserve", "release"})
+}
+
+func Test_Saga_With_FailureEvent_Should_NotCompensateFailedStep(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewInMemoryAdapter()
+	saga := newOrderSaga(adapter, nil, 0)
+	_ = saga.Subscribe(ctx, adapter)
+	replyWith(ctx, adapter, func(orderID string) event.Event { return stockRejected{OrderID: orderID} })
+
+	// Act
+	err := saga.Start(ctx, "order-1", orderSagaData{})
+	instance, _ := saga.Instance(ctx, "order-1")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "status must be compensated", instance.Status, event.SagaStatusCompensated)
+	assert.That(t, "error must be correct", instance.Error, "reserve stock: "+event.ErrSagaStepFailed.Error())
+	assert.That(t, "commands must be correct", commandNames(adapter), []string{"reserve"})
+}
+
+func Test_Saga_With_ExpiredTimeout_Should_CompensateInstance(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewInMemoryAdapter()
+	saga := newOrderSaga(adapter, nil, time.Millisecond)
+	_ = saga.Subscribe(ctx, adapter)
+	_ = saga.Start(ctx, "order-1", orderSagaData{})
+	time.Sleep(5 * time.Millisecond)
+
+	// Act
+	err := saga.CheckTimeouts(ctx)
+	err2 := adapter.Publish(ctx, stockReserved{OrderID: "order-1"})
+	instance, _ := saga.Instance(ctx, "order-1")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "status must be compensated", instance.Status, event.SagaStatusCompensated)
+	assert.That(t, "error must be correct", instance.Error, "reserve stock: "+event.ErrSagaStepTimeout.Error())
+	assert.That(t, "reserved must be false", instance.Data.Reserved, false)
+}
+
+func Test_Saga_With_UncorrelatedEvent_Should_IgnoreEvent(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewInMemoryAdapter()
+	saga := newOrderSaga(adapter, nil, 0)
+	_ = saga.Start(ctx, "order-1", orderSagaData{})
+
+	// Act
+	err := saga.Handle(ctx, stockReserved{OrderID: "order-2"})
+	err2 := saga.Handle(ctx, orderPlaced{OrderID: "order-1"})
+	instance, _ := saga.Instance(ctx, "order-1")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "status must be running", instance.Status, event.SagaStatusRunning)
+	assert.That(t, "step must be 0", instance.Step, 0)
+}
+
+func Test_Saga_With_FailingPublisher_Should_PublishPendingCommandsLater(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewInMemoryAdapter()
+	publisher := &flakyPublisher{EventPublisher: adapter, fail: true}
+	instances := resource.NewInMemoryAccess[string, event.SagaInstance[orderSagaData]]()
+	saga := event.NewSaga(publisher, instances, event.SagaStep[orderSagaData]{
+		Name: "ship",
+		Action: func(ctx context.Context, instance *event.SagaInstance[orderSagaData]) (event.Event, error) {
+			return sagaCommand{Name: "ship", OrderID: instance.ID}, nil
+		},
+	})
+	err := saga.Start(ctx, "order-1", orderSagaData{})
+	failed, _ := saga.Instance(ctx, "order-1")
+	publisher.fail = false
+
+	// Act
+	err2 := saga.PublishPending(ctx)
+	instance, _ := saga.Instance(ctx, "order-1")
+
+	// Assert
+	assert.That(t, "err must be ErrSagaCommandsPending", errors.Is(err, event.ErrSagaCommandsPending), true)
+	assert.That(t, "failed instance must be completed", failed.Status, event.SagaStatusCompleted)
+	assert.That(t, "failed instance must have 1 pending command", len(failed.Pending), 1)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "pending commands must be empty", len(instance.Pending), 0)
+	assert.That(t, "commands must be correct", commandNames(adapter), []string{"ship"})
+}
+
+// newShipSaga creates a saga publishing a ship command, whose commands are decoded by the registry.
+func newShipSaga(publisher event.EventPublisher, instances resource.Access[string, event.SagaInstance[orderSagaData]], registry *event.Registry) *event.Saga[orderSagaData] {
+	return event.NewSagaWithOptions(publisher, instances, event.SagaOptions{Registry: registry}, event.SagaStep[orderSagaData]{
+		Name: "ship",
+		Action: func(ctx context.Context, instance *event.SagaInstance[orderSagaData]) (event.Event, error) {
+			return sagaCommand{Name: "ship", OrderID: instance.ID}, nil
+		},
+	})
+}
+
+func Test_Saga_With_RestartAndRouter_Should_PublishPendingCommandTypes(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	registry := event.NewRegistry()
+	_ = registry.Register("SagaCommand", 1, func() event.Event { return sagaCommand{} })
+	router := event.NewRouter(messaging.NewInternalDispatcher(), registry)
+	var handled []sagaCommand
+	event.Handle(router, func(e sagaCommand) error {
+		handled = append(handled, e)
+		return nil
+	})
+	_ = router.Subscribe(ctx, "commands.ship")
+	instances := resource.NewInMemoryAccess[string, event.SagaInstance[orderSagaData]]()
+	err := newShipSaga(&flakyPublisher{EventPublisher: router, fail: true}, instances, registry).Start(ctx, "order-1", orderSagaData{})
+	restarted := newShipSaga(router, instances, registry)
+
+	// Act
+	err2 := restarted.PublishPending(ctx)
+	instance, _ := restarted.Instance(ctx, "order-1")
+
+	// Assert
+	assert.That(t, "err must be ErrSagaCommandsPending", errors.Is(err, event.ErrSagaCommandsPending), true)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "handled must be correct", handled, []sagaCommand{{Name: "ship", OrderID: "order-1"}})
+	assert.That(t, "pending commands must be empty", len(instance.Pending), 0)
+}
+
+func Test_Saga_With_RestartAndBus_Should_PublishPendingCommandTypes(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	registry := event.NewRegistry()
+	_ = registry.Register("SagaCommand", 1, func() event.Event { return sagaCommand{} })
+	bus := event.NewBus()
+	defer func() { _ = bus.Close() }()
+	var handled []sagaCommand
+	_ = bus.Subscribe(ctx, "commands.ship", nil, func(e event.Event) error {
+		command, ok := e.(sagaCommand)
+		if !ok {
+			return errors.New("unexpected command type")
+		}
+		handled = append(handled, command)
+		return nil
+	})
+	instances := resource.NewInMemoryAccess[string, event.SagaInstance[orderSagaData]]()
+	_ = newShipSaga(&flakyPublisher{EventPublisher: bus, fail: true}, instances, registry).Start(ctx, "order-1", orderSagaData{})
+	restarted := newShipSaga(bus, instances, registry)
+
+	// Act
+	err := restarted.PublishPending(ctx)
+	instance, _ := restarted.Instance(ctx, "order-1")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "handled must be correct", handled, []sagaCommand{{Name: "ship", OrderID: "order-1"}})
+	assert.That(t, "pending commands must be empty", len(instance.Pending), 0)
+}
+
+func Test_Saga_With_RestartAndUnknownCommandType_Should_KeepPendingCommand(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewInMemoryAdapter()
+	instances := resource.NewInMemoryAccess[string, event.SagaInstance[orderSagaData]]()
+	_ = newShipSaga(&flakyPublisher{EventPublisher: adapter, fail: true}, instances, nil).Start(ctx, "order-1", orderSagaData{})
+	restarted := newShipSaga(adapter, instances, event.NewRegistry())
+
+	// Act
+	err := restarted.PublishPending(ctx)
+	instance, _ := restarted.Instance(ctx, "order-1")
+
+	// Assert
+	assert.That(t, "err must be ErrUnknownEventType", errors.Is(err, event.ErrUnknownEventType), true)
+	assert.That(t, "pending commands must be kept", len(instance.Pending), 1)
+	assert.That(t, "published must be empty", len(adapter.Published()), 0)
+}
+
+func Test_Saga_With_SharedTopic_Should_MatchEventType(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adapter := event.NewInMemoryAdapter()
+	instances := resource.NewInMemoryAccess[string, event.SagaInstance[orderSagaData]]()
+	saga := event.NewSaga(adapter, instances, event.SagaStep[orderSagaData]{
+		Name: "reserve stock",
+		Action: func(ctx context.Context, instance *event.SagaInstance[orderSagaData]) (event.Event, error) {
+			return nil, nil
+		},
+		CompletedBy: []event.EventFactoryFn{func() event.Event { return stockResult{} }},
+		FailedBy:    []event.EventFactoryFn{func() event.Event { return stockResultFailed{} }},
+	})
+	_ = saga.Start(ctx, "order-1", orderSagaData{})
+
+	// Act
+	err := saga.Subscribe(ctx, adapter)
+	err2 := saga.Handle(ctx, stockResult{OrderID: "order-1"})
+	instance, _ := saga.Instance(ctx, "order-1")
+
+	// Assert
+	assert.That(t, "err must be ErrSagaTopicAmbiguous", err, event.ErrSagaTopicAmbiguous)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "status must be completed", instance.Status, event.SagaStatusCompleted)
+}
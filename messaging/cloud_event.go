@@ -0,0 +1,398 @@
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// CloudEventsSpecVersion is the supported version of the CloudEvents specification.
+	CloudEventsSpecVersion = "1.0"
+	// CloudEventsPrefixHTTP is the prefix of CloudEvents attributes in HTTP headers.
+	CloudEventsPrefixHTTP = "ce-"
+	// CloudEventsPrefixKafka is the prefix of CloudEvents attributes in Kafka headers.
+	CloudEventsPrefixKafka = "ce_"
+	// ContentTypeCloudEvents is the media type of CloudEvents in structured mode.
+	ContentTypeCloudEvents = "application/cloudevents+json"
+	// CloudEventsMaxBytes is the default limit of the request body read by ReadCloudEventRequest.
+	CloudEventsMaxBytes = 1 << 20 // 1MB
+)
+
+var (
+	ErrInvalidCloudEvent = errors.New("invalid cloud event")
+)
+
+// cloudEventExtensions maps the headers of messages to the names of CloudEvents extensions,
+// since extension names must only contain lowercase letters and digits.
+var cloudEventExtensions = map[string]string{
+	HeaderCausationID:   "causationid",
+	HeaderCorrelationID: "correlationid",
+	HeaderTraceParent:   "traceparent",
+	HeaderTraceState:    "tracestate",
+}
+
+// CloudEvent is an event in the format of the CloudEvents 1.0 specification.
+// Extensions are encoded as additional attributes.
+type CloudEvent struct {
+	ID              string
+	Source          string
+	SpecVersion     string
+	Type            string
+	DataContentType string
+	DataSchema      string
+	Subject         string
+	Time            time.Time
+	Data            []byte
+	Extensions      map[string]string
+}
+
+// NewCloudEvent creates a CloudEvent from a message. The topic becomes the type,
+// the key becomes the subject, and the headers become extensions.
+func NewCloudEvent(source string, message Message) CloudEvent {
+	ce := CloudEvent{
+		ID:              message.ID,
+		Source:          source,
+		SpecVersion:     CloudEventsSpecVersion,
+		Type:            message.Topic,
+		DataContentType: message.Headers[HeaderContentType],
+		Subject:         message.Key,
+		Time:            message.Timestamp,
+		Data:            message.Data,
+	}
+	for header, value := range message.Headers {
+		if header == HeaderContentType {
+			continue
+		}
+		name, ok := cloudEventExtensions[header]
+		if !ok {
+			name = cloudEventExtensionName(header)
+		}
+		if name == "" || isCloudEventAttribute(name) {
+			continue
+		}
+		if ce.Extensions == nil {
+			ce.Extensions = make(map[string]string, len(message.Headers))
+		}
+		ce.Extensions[name] = value
+	}
+	return ce
+}
+
+// Message creates a message from the CloudEvent. The source is not part of the message.
+func (a CloudEvent) Message() Message {
+	message := Message{
+		Data:      a.Data,
+		ID:        a.ID,
+		Key:       a.Subject,
+		State:     MessageStateCreated,
+		Timestamp: a.Time,
+		Topic:     a.Type,
+	}
+	if a.DataContentType != "" {
+		message = message.WithHeader(HeaderContentType, a.DataContentType)
+	}
+	for name, value := range a.Extensions {
+		header := name
+		for h, n := range cloudEventExtensions {
+			if n == name {
+				header = h
+			}
+		}
+		message = message.WithHeader(header, value)
+	}
+	return message
+}
+
+// Validate returns ErrInvalidCloudEvent if a required attribute is missing
+// or the specification version is not supported.
+func (a CloudEvent) Validate() error {
+	if a.ID == "" || a.Source == "" || a.Type == "" || a.SpecVersion != CloudEventsSpecVersion {
+		return ErrInvalidCloudEvent
+	}
+	return nil
+}
+
+// MarshalJSON encodes the CloudEvent in structured mode. JSON data is embedded,
+// and other data is encoded as base64.
+func (a CloudEvent) MarshalJSON() ([]byte, error) {
+	attributes := make(map[string]any, len(a.Extensions)+9)
+	for name, value := range a.Extensions {
+		attributes[name] = value
+	}
+	attributes["id"] = a.ID
+	attributes["source"] = a.Source
+	attributes["specversion"] = a.SpecVersion
+	attributes["type"] = a.Type
+	setCloudEventAttribute(attributes, "datacontenttype", a.DataContentType)
+	setCloudEventAttribute(attributes, "dataschema", a.DataSchema)
+	setCloudEventAttribute(attributes, "subject", a.Subject)
+	if !a.Time.IsZero() {
+		attributes["time"] = a.Time.UTC().Format(time.RFC3339Nano)
+	}
+	if a.Data != nil {
+		if isJsonContentType(a.DataContentType) && json.Valid(a.Data) {
+			attributes["data"] = json.RawMessage(a.Data)
+		} else {
+			attributes["data_base64"] = a.Data
+		}
+	}
+	return json.Marshal(attributes)
+}
+
+// UnmarshalJSON decodes the CloudEvent from structured mode.
+func (a *CloudEvent) UnmarshalJSON(data []byte) error {
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return err
+	}
+	*a = CloudEvent{}
+	for name, raw := range attributes {
+		switch name {
+		case "data":
+			// String data of other content types than JSON is not JSON encoded.
+			var s string
+			if !isJsonContentType(cloudEventString(attributes["datacontenttype"])) && json.Unmarshal(raw, &s) == nil {
+				a.Data = []byte(s)
+			} else {
+				a.Data = raw
+			}
+		case "data_base64":
+			if err := json.Unmarshal(raw, &a.Data); err != nil {
+				return ErrInvalidCloudEvent
+			}
+		default:
+			if err := a.setAttribute(name, cloudEventString(raw)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// BinaryHeaders returns the attributes as headers with the given prefix for binary mode,
+// e.g. CloudEventsPrefixHTTP or CloudEventsPrefixKafka. The data content type is
+// returned as "content-type".
+func (a CloudEvent) BinaryHeaders(prefix string) map[string]string {
+	headers := make(map[string]string, len(a.Extensions)+8)
+	for name, value := range a.Extensions {
+		headers[prefix+name] = value
+	}
+	headers[prefix+"id"] = a.ID
+	headers[prefix+"source"] = a.Source
+	headers[prefix+"specversion"] = a.SpecVersion
+	headers[prefix+"type"] = a.Type
+	if a.DataContentType != "" {
+		headers[HeaderContentType] = a.DataContentType
+	}
+	if a.DataSchema != "" {
+		headers[prefix+"dataschema"] = a.DataSchema
+	}
+	if a.Subject != "" {
+		headers[prefix+"subject"] = a.Subject
+	}
+	if !a.Time.IsZero() {
+		headers[prefix+"time"] = a.Time.UTC().Format(time.RFC3339Nano)
+	}
+	return headers
+}
+
+// ParseBinaryCloudEvent creates a CloudEvent from headers with the given prefix and the data.
+// Header names are compared case-insensitively.
+func ParseBinaryCloudEvent(prefix string, headers map[string]string, data []byte) (CloudEvent, error) {
+	ce := CloudEvent{Data: data}
+	for header, value := range headers {
+		name := strings.ToLower(header)
+		if name == HeaderContentType {
+			ce.DataContentType = value
+			continue
+		}
+		if name, ok := strings.CutPrefix(name, strings.ToLower(prefix)); ok {
+			if err := ce.setAttribute(name, value); err != nil {
+				return CloudEvent{}, err
+			}
+		}
+	}
+	return ce, ce.Validate()
+}
+
+// ToBinaryMessage encodes a message as CloudEvent in binary mode.
+// The attributes are stored as headers with the given prefix, and the data is kept.
+func ToBinaryMessage(source string, message Message, prefix string) Message {
+	ce := NewCloudEvent(source, message)
+	out := Message{Data: message.Data, ID: message.ID, Key: message.Key, State: message.State, Timestamp: message.Timestamp, Topic: message.Topic}
+	out.Headers = ce.BinaryHeaders(prefix)
+	return out
+}
+
+// ToStructuredMessage encodes a message as CloudEvent in structured mode.
+// The data contains the JSON encoded CloudEvent.
+func ToStructuredMessage(source string, message Message) (Message, error) {
+	data, err := json.Marshal(NewCloudEvent(source, message))
+	if err != nil {
+		return Message{}, err
+	}
+	out := Message{Data: data, ID: message.ID, Key: message.Key, State: message.State, Timestamp: message.Timestamp, Topic: message.Topic}
+	return out.WithHeader(HeaderContentType, ContentTypeCloudEvents), nil
+}
+
+// ParseCloudEventMessage decodes a CloudEvent from a message in structured or binary mode.
+// Binary mode is detected by headers with the HTTP or Kafka prefix.
+func ParseCloudEventMessage(message Message) (CloudEvent, error) {
+	if isCloudEventsContentType(message.Headers[HeaderContentType]) {
+		var ce CloudEvent
+		if err := json.Unmarshal(message.Data, &ce); err != nil {
+			return CloudEvent{}, errors.Join(ErrInvalidCloudEvent, err)
+		}
+		return ce, ce.Validate()
+	}
+	for _, prefix := range []string{CloudEventsPrefixKafka, CloudEventsPrefixHTTP} {
+		if _, ok := message.Headers[prefix+"specversion"]; ok {
+			return ParseBinaryCloudEvent(prefix, message.Headers, message.Data)
+		}
+	}
+	return CloudEvent{}, ErrInvalidCloudEvent
+}
+
+// NewCloudEventRequest creates an HTTP POST request delivering the CloudEvent
+// in structured or binary mode.
+func NewCloudEventRequest(ctx context.Context, url string, ce CloudEvent, structured bool) (*http.Request, error) {
+	if structured {
+		data, err := json.Marshal(ce)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", ContentTypeCloudEvents)
+		return req, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(ce.Data))
+	if err != nil {
+		return nil, err
+	}
+	for header, value := range ce.BinaryHeaders(CloudEventsPrefixHTTP) {
+		req.Header.Set(header, value)
+	}
+	return req, nil
+}
+
+// ReadCloudEventRequest decodes a CloudEvent from an HTTP request in structured or binary mode.
+// The body is limited to CloudEventsMaxBytes.
+func ReadCloudEventRequest(r *http.Request) (CloudEvent, error) {
+	return ReadCloudEventRequestWithLimit(r, CloudEventsMaxBytes)
+}
+
+// ReadCloudEventRequestWithLimit decodes a CloudEvent from an HTTP request in structured or binary mode.
+// It returns an *http.MaxBytesError if the body exceeds maxBytes.
+func ReadCloudEventRequestWithLimit(r *http.Request, maxBytes int64) (CloudEvent, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBytes))
+	if err != nil {
+		return CloudEvent{}, err
+	}
+	if isCloudEventsContentType(r.Header.Get("Content-Type")) {
+		var ce CloudEvent
+		if err := json.Unmarshal(data, &ce); err != nil {
+			return CloudEvent{}, errors.Join(ErrInvalidCloudEvent, err)
+		}
+		return ce, ce.Validate()
+	}
+	headers := make(map[string]string, len(r.Header))
+	for header := range r.Header {
+		headers[header] = r.Header.Get(header)
+	}
+	return ParseBinaryCloudEvent(CloudEventsPrefixHTTP, headers, data)
+}
+
+// setAttribute sets a context attribute or an extension by its name.
+// It returns ErrInvalidCloudEvent if the time is not a RFC 3339 timestamp.
+func (a *CloudEvent) setAttribute(name, value string) error {
+	switch name {
+	case "id":
+		a.ID = value
+	case "source":
+		a.Source = value
+	case "specversion":
+		a.SpecVersion = value
+	case "type":
+		a.Type = value
+	case "datacontenttype":
+		a.DataContentType = value
+	case "dataschema":
+		a.DataSchema = value
+	case "subject":
+		a.Subject = value
+	case "time":
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return errors.Join(ErrInvalidCloudEvent, err)
+		}
+		a.Time = t
+	default:
+		if a.Extensions == nil {
+			a.Extensions = make(map[string]string)
+		}
+		a.Extensions[name] = value
+	}
+	return nil
+}
+
+// cloudEventExtensionName removes the characters of a header, which are not allowed in extension names.
+func cloudEventExtensionName(header string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(header) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// cloudEventString decodes a JSON attribute value as string.
+// Other values like numbers or booleans are kept in their JSON representation.
+func cloudEventString(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// isCloudEventAttribute reports whether the name is a context attribute or reserved for the data.
+func isCloudEventAttribute(name string) bool {
+	switch name {
+	case "id", "source", "specversion", "type", "datacontenttype", "dataschema", "subject", "time", "data", "data_base64":
+		return true
+	}
+	return false
+}
+
+// isCloudEventsContentType reports whether the media type is used for structured mode.
+func isCloudEventsContentType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == ContentTypeCloudEvents
+}
+
+// isJsonContentType reports whether data of the media type is JSON. An empty media type implies JSON.
+func isJsonContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == ContentTypeJson || strings.HasSuffix(mediaType, "+json")
+}
+
+// setCloudEventAttribute sets an optional attribute if its value is not empty.
+func setCloudEventAttribute(attributes map[string]any, name, value string) {
+	if value != "" {
+		attributes[name] = value
+	}
+}
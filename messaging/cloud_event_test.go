@@ -0,0 +1,175 @@
+package messaging_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+)
+
+func newCloudEventTestMessage() messaging.Message {
+	msg := messaging.NewMessage("orders.placed", []byte(`{"order_id":"order-1"}`)).
+		WithKey("order-1").
+		WithHeader(messaging.HeaderContentType, messaging.ContentTypeJson).
+		WithHeader(messaging.HeaderCorrelationID, "correlation-1")
+	msg.ID = "message-1"
+	msg.Timestamp = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	return msg
+}
+
+func Test_CloudEvent_With_MarshalJSON_Should_EncodeStructuredMode(t *testing.T) {
+	// Arrange
+	ce := messaging.NewCloudEvent("/orders", newCloudEventTestMessage())
+
+	// Act
+	data, err := json.Marshal(ce)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "data must be correct", string(data), `{"correlationid":"correlation-1","data":{"order_id":"order-1"},"datacontenttype":"application/json","id":"message-1","source":"/orders","specversion":"1.0","subject":"order-1","time":"2026-01-02T03:04:05Z","type":"orders.placed"}`)
+}
+
+func Test_CloudEvent_With_BinaryData_Should_EncodeBase64(t *testing.T) {
+	// Arrange
+	msg := messaging.NewMessage("files.uploaded", []byte{0, 1, 2}).WithHeader(messaging.HeaderContentType, "application/octet-stream")
+	ce := messaging.NewCloudEvent("/files", msg)
+
+	// Act
+	data, _ := json.Marshal(ce)
+	var decoded messaging.CloudEvent
+	err := json.Unmarshal(data, &decoded)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "data must be base64 encoded", decoded.Data, []byte{0, 1, 2})
+}
+
+func Test_CloudEvent_With_UnmarshalJSON_Should_RestoreMessage(t *testing.T) {
+	// Arrange
+	message := newCloudEventTestMessage()
+	data, _ := json.Marshal(messaging.NewCloudEvent("/orders", message))
+	var ce messaging.CloudEvent
+
+	// Act
+	err := json.Unmarshal(data, &ce)
+	restored := ce.Message()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "validate must be nil", ce.Validate(), nil)
+	assert.That(t, "source must be correct", ce.Source, "/orders")
+	assert.That(t, "message must be correct", restored, message)
+}
+
+func Test_ToStructuredMessage_Should_ParseCloudEventMessage(t *testing.T) {
+	// Arrange
+	message := newCloudEventTestMessage()
+
+	// Act
+	structured, err := messaging.ToStructuredMessage("/orders", message)
+	ce, err2 := messaging.ParseCloudEventMessage(structured)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "content type must be correct", structured.Headers[messaging.HeaderContentType], messaging.ContentTypeCloudEvents)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "message must be correct", ce.Message(), message)
+}
+
+func Test_ToBinaryMessage_With_KafkaPrefix_Should_ParseCloudEventMessage(t *testing.T) {
+	// Arrange
+	message := newCloudEventTestMessage()
+
+	// Act
+	binary := messaging.ToBinaryMessage("/orders", message, messaging.CloudEventsPrefixKafka)
+	ce, err := messaging.ParseCloudEventMessage(binary)
+
+	// Assert
+	assert.That(t, "type header must be correct", binary.Headers["ce_type"], "orders.placed")
+	assert.That(t, "data must be kept", string(binary.Data), `{"order_id":"order-1"}`)
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "message must be correct", ce.Message(), message)
+}
+
+func Test_ParseCloudEventMessage_With_PlainMessage_Should_ReturnErrInvalidCloudEvent(t *testing.T) {
+	// Arrange
+	message := messaging.NewMessage("orders.placed", []byte("{}"))
+
+	// Act
+	_, err := messaging.ParseCloudEventMessage(message)
+
+	// Assert
+	assert.That(t, "err must be ErrInvalidCloudEvent", err, messaging.ErrInvalidCloudEvent)
+}
+
+func Test_NewCloudEventRequest_With_BinaryMode_Should_ReadCloudEventRequest(t *testing.T) {
+	// Arrange
+	ce := messaging.NewCloudEvent("/orders", newCloudEventTestMessage())
+
+	// Act
+	req, err := messaging.NewCloudEventRequest(context.Background(), "http://localhost/events", ce, false)
+	decoded, err2 := messaging.ReadCloudEventRequest(req)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "method must be POST", req.Method, http.MethodPost)
+	assert.That(t, "id header must be correct", req.Header.Get("Ce-Id"), "message-1")
+	assert.That(t, "content type must be correct", req.Header.Get("Content-Type"), messaging.ContentTypeJson)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "event must be correct", decoded, ce)
+}
+
+func Test_NewCloudEventRequest_With_StructuredMode_Should_ReadCloudEventRequest(t *testing.T) {
+	// Arrange
+	ce := messaging.NewCloudEvent("/orders", newCloudEventTestMessage())
+
+	// Act
+	req, err := messaging.NewCloudEventRequest(context.Background(), "http://localhost/events", ce, true)
+	decoded, err2 := messaging.ReadCloudEventRequest(req)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "content type must be correct", req.Header.Get("Content-Type"), messaging.ContentTypeCloudEvents)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "event must be correct", decoded, ce)
+}
+
+func Test_ReadCloudEventRequestWithLimit_With_LargeBody_Should_ReturnMaxBytesError(t *testing.T) {
+	// Arrange
+	ce := messaging.NewCloudEvent("/orders", newCloudEventTestMessage())
+	req, _ := messaging.NewCloudEventRequest(context.Background(), "http://localhost/events", ce, true)
+
+	// Act
+	_, err := messaging.ReadCloudEventRequestWithLimit(req, 16)
+
+	// Assert
+	var maxBytesErr *http.MaxBytesError
+	assert.That(t, "err must be MaxBytesError", errors.As(err, &maxBytesErr), true)
+}
+
+func Test_ReadCloudEventRequest_With_InvalidTime_Should_ReturnErrInvalidCloudEvent(t *testing.T) {
+	// Arrange
+	body := `{"specversion":"1.0","id":"1","source":"/orders","type":"orders.placed","time":"yesterday"}`
+	structured, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, "http://localhost/events", strings.NewReader(body))
+	structured.Header.Set("Content-Type", messaging.ContentTypeCloudEvents)
+	binary, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, "http://localhost/events", strings.NewReader("{}"))
+	binary.Header.Set("Ce-Specversion", "1.0")
+	binary.Header.Set("Ce-Id", "1")
+	binary.Header.Set("Ce-Source", "/orders")
+	binary.Header.Set("Ce-Type", "orders.placed")
+	binary.Header.Set("Ce-Time", "yesterday")
+
+	// Act
+	_, err := messaging.ReadCloudEventRequest(structured)
+	_, err2 := messaging.ReadCloudEventRequest(binary)
+
+	// Assert
+	assert.That(t, "err must be ErrInvalidCloudEvent", errors.Is(err, messaging.ErrInvalidCloudEvent), true)
+	assert.That(t, "err2 must be ErrInvalidCloudEvent", errors.Is(err2, messaging.ErrInvalidCloudEvent), true)
+}
@@ -0,0 +1,78 @@
+package messaging
+
+import (
+	"encoding"
+	"encoding/json"
+)
+
+const (
+	// ContentTypeBinary is the media type of the BinaryCodec.
+	ContentTypeBinary = "application/octet-stream"
+	// ContentTypeJson is the media type of the JsonCodec.
+	ContentTypeJson = "application/json"
+)
+
+// Codec encodes and decodes the payload of typed messages.
+type Codec[T any] interface {
+	ContentType() string
+	Marshal(value T) ([]byte, error)
+	Unmarshal(data []byte) (T, error)
+}
+
+// JsonCodec encodes payloads as JSON.
+type JsonCodec[T any] struct{}
+
+// NewJsonCodec creates a new JsonCodec instance.
+func NewJsonCodec[T any]() Codec[T] {
+	return JsonCodec[T]{}
+}
+
+// ContentType returns the media type of the encoded payloads.
+func (a JsonCodec[T]) ContentType() string {
+	return ContentTypeJson
+}
+
+// Marshal encodes the value as JSON.
+func (a JsonCodec[T]) Marshal(value T) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// Unmarshal decodes the value from JSON.
+func (a JsonCodec[T]) Unmarshal(data []byte) (T, error) {
+	var value T
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+// BinaryMessage is a pointer to a type that encodes itself in a binary format,
+// e.g. a protobuf message with MarshalBinary and UnmarshalBinary methods.
+type BinaryMessage[T any] interface {
+	*T
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// BinaryCodec encodes payloads by using their binary marshaling methods.
+type BinaryCodec[T any, P BinaryMessage[T]] struct{}
+
+// NewBinaryCodec creates a new BinaryCodec instance, e.g. by using NewBinaryCodec[Order]().
+func NewBinaryCodec[T any, P BinaryMessage[T]]() Codec[T] {
+	return BinaryCodec[T, P]{}
+}
+
+// ContentType returns the media type of the encoded payloads.
+func (a BinaryCodec[T, P]) ContentType() string {
+	return ContentTypeBinary
+}
+
+// Marshal encodes the value by using its MarshalBinary method.
+func (a BinaryCodec[T, P]) Marshal(value T) ([]byte, error) {
+	return P(&value).MarshalBinary()
+}
+
+// Unmarshal decodes the value by using its UnmarshalBinary method.
+func (a BinaryCodec[T, P]) Unmarshal(data []byte) (T, error) {
+	var value T
+	err := P(&value).UnmarshalBinary(data)
+	return value, err
+}
@@ -0,0 +1,68 @@
+package messaging
+
+import "context"
+
+const (
+	// HeaderCausationID contains the ID of the message which caused a message.
+	HeaderCausationID = "x-causation-id"
+	// HeaderContentType contains the media type of the message data.
+	HeaderContentType = "content-type"
+	// HeaderCorrelationID contains the ID shared by all messages of a workflow.
+	HeaderCorrelationID = "x-correlation-id"
+	// HeaderMessageID contains the message ID if the transport has no dedicated field.
+	HeaderMessageID = "x-message-id"
+	// HeaderMessageKey contains the message key if the transport has no dedicated field.
+	HeaderMessageKey = "x-message-key"
+	// HeaderTimestamp contains the message timestamp in RFC 3339 format if the transport has no dedicated field.
+	HeaderTimestamp = "x-timestamp"
+	// HeaderTraceParent contains the W3C trace context of a message.
+	HeaderTraceParent = "traceparent"
+	// HeaderTraceState contains the vendor specific W3C trace state of a message.
+	HeaderTraceState = "tracestate"
+)
+
+// ContextKey is a type for context keys used in the messaging package.
+type ContextKey string
+
+const (
+	ContextCausationID   ContextKey = "causation_id"
+	ContextCorrelationID ContextKey = "correlation_id"
+	ContextTraceParent   ContextKey = "traceparent"
+	ContextTraceState    ContextKey = "tracestate"
+)
+
+// contextHeaders maps the context keys to the headers propagating their values.
+var contextHeaders = map[ContextKey]string{
+	ContextCausationID:   HeaderCausationID,
+	ContextCorrelationID: HeaderCorrelationID,
+	ContextTraceParent:   HeaderTraceParent,
+	ContextTraceState:    HeaderTraceState,
+}
+
+// InjectContext returns a copy of the message with the string values of the
+// context keys stored in its headers. Existing headers are not overwritten.
+func InjectContext(ctx context.Context, message Message) Message {
+	for key, header := range contextHeaders {
+		value, ok := ctx.Value(key).(string)
+		if !ok || value == "" || message.Headers[header] != "" {
+			continue
+		}
+		message = message.WithHeader(header, value)
+	}
+	return message
+}
+
+// ExtractContext returns a copy of the context with the header values of the
+// message stored by their context keys. The message ID becomes the causation ID,
+// so that messages published while handling the message reference it.
+func ExtractContext(ctx context.Context, message Message) context.Context {
+	for key, header := range contextHeaders {
+		if value := message.Headers[header]; value != "" {
+			ctx = context.WithValue(ctx, key, value)
+		}
+	}
+	if message.ID != "" {
+		ctx = context.WithValue(ctx, ContextCausationID, message.ID)
+	}
+	return ctx
+}
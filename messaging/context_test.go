@@ -0,0 +1,61 @@
+package messaging_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+)
+
+func Test_InjectContext_With_CorrelationID_Should_SetHeader(t *testing.T) {
+	// Arrange
+	ctx := context.WithValue(context.Background(), messaging.ContextCorrelationID, "my correlation")
+	msg := messaging.NewMessage("my topic", []byte("test"))
+
+	// Act
+	msg = messaging.InjectContext(ctx, msg)
+
+	// Assert
+	assert.That(t, "header must be correct", msg.Headers[messaging.HeaderCorrelationID], "my correlation")
+}
+
+func Test_InjectContext_With_ExistingHeader_Should_KeepHeader(t *testing.T) {
+	// Arrange
+	ctx := context.WithValue(context.Background(), messaging.ContextCorrelationID, "my correlation")
+	msg := messaging.NewMessage("my topic", []byte("test")).WithHeader(messaging.HeaderCorrelationID, "existing")
+
+	// Act
+	msg = messaging.InjectContext(ctx, msg)
+
+	// Assert
+	assert.That(t, "header must be correct", msg.Headers[messaging.HeaderCorrelationID], "existing")
+}
+
+func Test_ExtractContext_With_Headers_Should_SetContextValues(t *testing.T) {
+	// Arrange
+	msg := messaging.NewMessage("my topic", []byte("test")).
+		WithHeader(messaging.HeaderCorrelationID, "my correlation").
+		WithHeader(messaging.HeaderTraceParent, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	// Act
+	ctx := messaging.ExtractContext(context.Background(), msg)
+
+	// Assert
+	assert.That(t, "correlation id must be correct", ctx.Value(messaging.ContextCorrelationID), any("my correlation"))
+	assert.That(t, "traceparent must be correct", ctx.Value(messaging.ContextTraceParent), any("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"))
+	assert.That(t, "causation id must be the message id", ctx.Value(messaging.ContextCausationID), any(msg.ID))
+}
+
+func Test_ExtractContext_With_InjectContext_Should_PropagateCausation(t *testing.T) {
+	// Arrange
+	incoming := messaging.NewMessage("my topic", []byte("test")).WithHeader(messaging.HeaderCorrelationID, "my correlation")
+	ctx := messaging.ExtractContext(context.Background(), incoming)
+
+	// Act
+	outgoing := messaging.InjectContext(ctx, messaging.NewMessage("other topic", []byte("test")))
+
+	// Assert
+	assert.That(t, "correlation id must be correct", outgoing.Headers[messaging.HeaderCorrelationID], "my correlation")
+	assert.That(t, "causation id must be correct", outgoing.Headers[messaging.HeaderCausationID], incoming.ID)
+}
@@ -0,0 +1,178 @@
+package messaging
+
+import (
+	"context"
+	"maps"
+	"strconv"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/service"
+)
+
+const (
+	// HeaderAttempts contains the number of failed attempts to handle a message.
+	HeaderAttempts = "x-attempts"
+	// HeaderError contains the error of the last failed attempt.
+	HeaderError = "x-error"
+	// HeaderFailedAt contains the time of the last failed attempt in RFC 3339 format.
+	HeaderFailedAt = "x-failed-at"
+	// HeaderOriginalTopic contains the topic a retried or dead-lettered message was published to first.
+	HeaderOriginalTopic = "x-original-topic"
+	// HeaderRetryAt contains the earliest time in RFC 3339 format to retry a message of a retry topic.
+	HeaderRetryAt = "x-retry-at"
+)
+
+// DeadLetterOptions configures how DeadLetter handles failed messages.
+type DeadLetterOptions struct {
+	// MaxRetries is the number of retries before a message is dead-lettered.
+	// A negative value dead-letters failed messages without retries. Default: 3.
+	MaxRetries int
+
+	// RetryDelay is the delay between two attempts. Default: 1s.
+	RetryDelay time.Duration
+
+	// RetryTopic enables non-blocking retries. Failed messages are published to
+	// the retry topic, which must be subscribed by the same function, instead of
+	// being retried in-process. Default: none.
+	RetryTopic string
+
+	// Topic is the dead-letter topic. Default: original topic with the suffix ".dlq".
+	Topic string
+}
+
+// DeadLetter wraps a given function (`fn`) to retry messages which failed with an
+// error or MessageStateFailed. Messages which still fail after `MaxRetries`
+// retries are published to the dead-letter topic with their failure metadata in
+// the headers, and are reported as completed.
+// An error is only returned if the message could not be routed to the retry or
+// dead-letter topic, so that the dispatcher can redeliver it.
+func DeadLetter(fn service.Function[Message, MessageState], dispatcher Dispatcher, options DeadLetterOptions) service.Function[Message, MessageState] {
+	options = options.withDefaults()
+	return func(ctx context.Context, message Message) (MessageState, error) {
+		// Wait until a message of the retry topic is due.
+		if err := waitUntilRetry(ctx, message); err != nil {
+			return MessageStateFailed, err
+		}
+
+		attempts, _ := strconv.Atoi(message.Headers[HeaderAttempts])
+		for {
+			state, err := fn(ctx, message)
+			if err == nil && state != MessageStateFailed {
+				return state, nil
+			}
+			// Skip if context is canceled or timed out.
+			if ctx.Err() != nil {
+				return MessageStateFailed, ctx.Err()
+			}
+			if err == nil {
+				err = ErrMessageNotCompleted
+			}
+			attempts++
+
+			// Route the message to the dead-letter topic after the last retry.
+			if attempts > options.MaxRetries {
+				failed := failedMessage(message, attempts, err)
+				failed.Topic = options.Topic
+				if failed.Topic == "" {
+					failed.Topic = failed.Headers[HeaderOriginalTopic] + ".dlq"
+				}
+				if err := dispatcher.Publish(ctx, failed); err != nil {
+					return MessageStateFailed, err
+				}
+				return MessageStateCompleted, nil
+			}
+
+			// Route the message to the retry topic to avoid blocking subsequent messages.
+			if options.RetryTopic != "" {
+				failed := failedMessage(message, attempts, err)
+				failed.Topic = options.RetryTopic
+				failed.Headers[HeaderRetryAt] = time.Now().Add(options.RetryDelay).UTC().Format(time.RFC3339Nano)
+				if err := dispatcher.Publish(ctx, failed); err != nil {
+					return MessageStateFailed, err
+				}
+				return MessageStateCompleted, nil
+			}
+
+			select {
+			// Wait for the delay duration before retrying.
+			case <-time.After(options.RetryDelay):
+			// If the context is canceled during the wait, stop retrying.
+			case <-ctx.Done():
+				return MessageStateFailed, ctx.Err()
+			}
+		}
+	}
+}
+
+// Replay publishes a dead-lettered message back to its original topic
+// and removes its failure metadata.
+func Replay(ctx context.Context, dispatcher Dispatcher, message Message) error {
+	replayed := message
+	replayed.Headers = maps.Clone(message.Headers)
+	if topic := replayed.Headers[HeaderOriginalTopic]; topic != "" {
+		replayed.Topic = topic
+	}
+	for _, key := range []string{HeaderAttempts, HeaderError, HeaderFailedAt, HeaderOriginalTopic, HeaderRetryAt} {
+		delete(replayed.Headers, key)
+	}
+	replayed.State = MessageStateCreated
+	return dispatcher.Publish(ctx, replayed)
+}
+
+// Replayer returns a function which replays each message of a subscribed
+// dead-letter topic to its original topic.
+func Replayer(dispatcher Dispatcher) service.Function[Message, MessageState] {
+	return func(ctx context.Context, message Message) (MessageState, error) {
+		if err := Replay(ctx, dispatcher, message); err != nil {
+			return MessageStateFailed, err
+		}
+		return MessageStateCompleted, nil
+	}
+}
+
+// failedMessage returns a copy of the message with its failure metadata in the headers.
+func failedMessage(message Message, attempts int, err error) Message {
+	failed := message
+	failed.Headers = maps.Clone(message.Headers)
+	if failed.Headers == nil {
+		failed.Headers = make(map[string]string)
+	}
+	if failed.Headers[HeaderOriginalTopic] == "" {
+		failed.Headers[HeaderOriginalTopic] = message.Topic
+	}
+	failed.Headers[HeaderAttempts] = strconv.Itoa(attempts)
+	failed.Headers[HeaderError] = err.Error()
+	failed.Headers[HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339Nano)
+	delete(failed.Headers, HeaderRetryAt)
+	failed.State = MessageStateFailed
+	return failed
+}
+
+// waitUntilRetry waits until the retry time of the message has been reached.
+func waitUntilRetry(ctx context.Context, message Message) error {
+	retryAt, err := time.Parse(time.RFC3339Nano, message.Headers[HeaderRetryAt])
+	if err != nil {
+		return nil
+	}
+	delay := time.Until(retryAt)
+	if delay <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// withDefaults replaces unset options with their default values.
+func (a DeadLetterOptions) withDefaults() DeadLetterOptions {
+	if a.MaxRetries == 0 {
+		a.MaxRetries = 3
+	}
+	if a.RetryDelay <= 0 {
+		a.RetryDelay = time.Second
+	}
+	return a
+}
@@ -0,0 +1,159 @@
+package messaging_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+	"github.com/andygeiss/cloud-native-utils/service"
+)
+
+func Test_DeadLetter_With_FailingHandler_Should_PublishToDeadLetterTopic(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	var dead messaging.Message
+	_ = dis.Subscribe(ctx, "orders.dlq", service.Wrap(func(m messaging.Message) (messaging.MessageState, error) {
+		dead = m
+		return messaging.MessageStateCompleted, nil
+	}))
+	calls := 0
+	fn := service.Wrap(func(_ messaging.Message) (messaging.MessageState, error) {
+		calls++
+		return messaging.MessageStateFailed, errors.New("boom")
+	})
+	_ = dis.Subscribe(ctx, "orders", messaging.DeadLetter(fn, dis, messaging.DeadLetterOptions{
+		MaxRetries: 2,
+		RetryDelay: time.Millisecond,
+	}))
+
+	// Act
+	err := dis.Publish(ctx, messaging.NewMessage("orders", []byte("order-1")))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "calls must be 3", calls, 3)
+	assert.That(t, "topic must be correct", dead.Topic, "orders.dlq")
+	assert.That(t, "data must be correct", string(dead.Data), "order-1")
+	assert.That(t, "attempts must be correct", dead.Headers[messaging.HeaderAttempts], "3")
+	assert.That(t, "error must be correct", dead.Headers[messaging.HeaderError], "boom")
+	assert.That(t, "original topic must be correct", dead.Headers[messaging.HeaderOriginalTopic], "orders")
+}
+
+func Test_DeadLetter_With_NegativeMaxRetries_Should_PublishWithoutRetries(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	var dead messaging.Message
+	_ = dis.Subscribe(ctx, "orders.dlq", service.Wrap(func(m messaging.Message) (messaging.MessageState, error) {
+		dead = m
+		return messaging.MessageStateCompleted, nil
+	}))
+	calls := 0
+	fn := service.Wrap(func(_ messaging.Message) (messaging.MessageState, error) {
+		calls++
+		return messaging.MessageStateFailed, errors.New("boom")
+	})
+	_ = dis.Subscribe(ctx, "orders", messaging.DeadLetter(fn, dis, messaging.DeadLetterOptions{MaxRetries: -1}))
+
+	// Act
+	err := dis.Publish(ctx, messaging.NewMessage("orders", []byte("order-1")))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "calls must be 1", calls, 1)
+	assert.That(t, "attempts must be correct", dead.Headers[messaging.HeaderAttempts], "1")
+}
+
+func Test_DeadLetter_With_RecoveringHandler_Should_NotPublishToDeadLetterTopic(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	dead := 0
+	_ = dis.Subscribe(ctx, "orders.dlq", service.Wrap(func(_ messaging.Message) (messaging.MessageState, error) {
+		dead++
+		return messaging.MessageStateCompleted, nil
+	}))
+	calls := 0
+	fn := service.Wrap(func(_ messaging.Message) (messaging.MessageState, error) {
+		calls++
+		if calls == 1 {
+			return messaging.MessageStateFailed, nil
+		}
+		return messaging.MessageStateCompleted, nil
+	})
+	_ = dis.Subscribe(ctx, "orders", messaging.DeadLetter(fn, dis, messaging.DeadLetterOptions{
+		RetryDelay: time.Millisecond,
+	}))
+
+	// Act
+	err := dis.Publish(ctx, messaging.NewMessage("orders", []byte("order-1")))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "calls must be 2", calls, 2)
+	assert.That(t, "dead must be 0", dead, 0)
+}
+
+func Test_DeadLetter_With_RetryTopic_Should_RetryThroughRetryTopic(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	var dead messaging.Message
+	_ = dis.Subscribe(ctx, "orders.dlq", service.Wrap(func(m messaging.Message) (messaging.MessageState, error) {
+		dead = m
+		return messaging.MessageStateCompleted, nil
+	}))
+	calls := 0
+	fn := messaging.DeadLetter(service.Wrap(func(_ messaging.Message) (messaging.MessageState, error) {
+		calls++
+		return messaging.MessageStateFailed, nil
+	}), dis, messaging.DeadLetterOptions{
+		MaxRetries: 1,
+		RetryDelay: time.Millisecond,
+		RetryTopic: "orders.retry",
+	})
+	_ = dis.Subscribe(ctx, "orders", fn)
+	_ = dis.Subscribe(ctx, "orders.retry", fn)
+
+	// Act
+	err := dis.Publish(ctx, messaging.NewMessage("orders", []byte("order-1")))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "calls must be 2", calls, 2)
+	assert.That(t, "topic must be correct", dead.Topic, "orders.dlq")
+	assert.That(t, "attempts must be correct", dead.Headers[messaging.HeaderAttempts], "2")
+	assert.That(t, "original topic must be correct", dead.Headers[messaging.HeaderOriginalTopic], "orders")
+}
+
+func Test_Replay_With_DeadLetter_Should_PublishToOriginalTopic(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	var replayed messaging.Message
+	_ = dis.Subscribe(ctx, "orders", service.Wrap(func(m messaging.Message) (messaging.MessageState, error) {
+		replayed = m
+		return messaging.MessageStateCompleted, nil
+	}))
+	_ = dis.Subscribe(ctx, "orders.dlq", messaging.Replayer(dis))
+	dead := messaging.NewMessage("orders.dlq", []byte("order-1"))
+	dead.Headers = map[string]string{
+		messaging.HeaderAttempts:      "3",
+		messaging.HeaderError:         "boom",
+		messaging.HeaderOriginalTopic: "orders",
+		"trace-id":                    "42",
+	}
+
+	// Act
+	err := dis.Publish(ctx, dead)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "topic must be correct", replayed.Topic, "orders")
+	assert.That(t, "headers must be correct", replayed.Headers, map[string]string{"trace-id": "42"})
+	assert.That(t, "dead letter headers must be unchanged", len(dead.Headers), 4)
+}
@@ -2,32 +2,93 @@ package messaging
 
 import (
 	"context"
+	"errors"
+	"maps"
+	"time"
 
+	"github.com/andygeiss/cloud-native-utils/env"
+	"github.com/andygeiss/cloud-native-utils/security"
 	"github.com/andygeiss/cloud-native-utils/service"
 )
 
+var (
+	ErrUnknownDriver = errors.New("unknown messaging driver")
+)
+
 // Dispatcher is an interface for a message dispatcher.
 type Dispatcher interface {
 	Publish(ctx context.Context, message Message) error
 	Subscribe(ctx context.Context, topic string, fn service.Function[Message, MessageState]) error
 }
 
+// NewDispatcher creates the dispatcher selected by the MESSAGING_DRIVER environment variable,
+// which is one of "internal" (default), "kafka", "nats" or "amqp".
+// The dispatchers are configured by their environment variables.
+func NewDispatcher(ctx context.Context) (Dispatcher, error) {
+	switch env.Get("MESSAGING_DRIVER", "internal") {
+	case "internal":
+		return NewInternalDispatcher(), nil
+	case "kafka":
+		return NewExternalDispatcher(), nil
+	case "nats":
+		dispatcher, err := NewNatsDispatcher(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return dispatcher, nil
+	case "amqp":
+		dispatcher, err := NewAmqpDispatcher()
+		if err != nil {
+			return nil, err
+		}
+		return dispatcher, nil
+	default:
+		return nil, ErrUnknownDriver
+	}
+}
+
 // Message is a struct that represents a message.
+// The ID identifies a message across retries and redeliveries.
+// The key is used to route messages to partitions, so that messages with the
+// same key keep their order. Headers carry additional string values like
+// trace context, correlation IDs or the content type.
 type Message struct {
-	Topic string       `json:"topic"`
-	Data  []byte       `json:"data"`
-	State MessageState `json:"state"`
+	ID        string            `json:"id,omitempty"`
+	Topic     string            `json:"topic"`
+	Key       string            `json:"key,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Data      []byte            `json:"data"`
+	State     MessageState      `json:"state"`
+	Timestamp time.Time         `json:"timestamp,omitzero"`
 }
 
-// NewMessage creates a new message.
+// NewMessage creates a new message with a unique ID and the current time.
 func NewMessage(topic string, data []byte) Message {
 	return Message{
-		Data:  data,
-		State: MessageStateCreated,
-		Topic: topic,
+		Data:      data,
+		ID:        security.GenerateID(),
+		State:     MessageStateCreated,
+		Timestamp: time.Now().UTC(),
+		Topic:     topic,
 	}
 }
 
+// WithHeader returns a copy of the message with the given header.
+func (a Message) WithHeader(key, value string) Message {
+	a.Headers = maps.Clone(a.Headers)
+	if a.Headers == nil {
+		a.Headers = make(map[string]string)
+	}
+	a.Headers[key] = value
+	return a
+}
+
+// WithKey returns a copy of the message with the given key.
+func (a Message) WithKey(key string) Message {
+	a.Key = key
+	return a
+}
+
 // MessageState is an enum that represents the state of a message.
 type MessageState int
 
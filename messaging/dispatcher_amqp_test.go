@@ -0,0 +1,218 @@
+package messaging_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+	"github.com/andygeiss/cloud-native-utils/service"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// fakeAmqpBroker is a minimal in-memory AMQP broker with topic exchanges.
+type fakeAmqpBroker struct {
+	bindings map[string][]string // Queue names by routing key pattern.
+	queues   map[string]chan amqp.Delivery
+	acked    int
+	nacked   int
+	mutex    sync.Mutex
+}
+
+func newFakeAmqpBroker() *fakeAmqpBroker {
+	return &fakeAmqpBroker{
+		bindings: make(map[string][]string),
+		queues:   make(map[string]chan amqp.Delivery),
+	}
+}
+
+func (a *fakeAmqpBroker) Ack(_ uint64, _ bool) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.acked++
+	return nil
+}
+
+func (a *fakeAmqpBroker) Nack(_ uint64, _ bool, _ bool) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.nacked++
+	return nil
+}
+
+func (a *fakeAmqpBroker) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func (a *fakeAmqpBroker) Cancel(_ string, _ bool) error { return nil }
+
+func (a *fakeAmqpBroker) Close() error { return nil }
+
+func (a *fakeAmqpBroker) Consume(queue, _ string, _, _, _, _ bool, _ amqp.Table) (<-chan amqp.Delivery, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.queues[queue], nil
+}
+
+func (a *fakeAmqpBroker) ExchangeDeclare(_, _ string, _, _, _, _ bool, _ amqp.Table) error {
+	return nil
+}
+
+func (a *fakeAmqpBroker) PublishWithContext(_ context.Context, _, key string, _, _ bool, msg amqp.Publishing) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for pattern, queues := range a.bindings {
+		if !matchRoutingKey(strings.Split(pattern, "."), strings.Split(key, ".")) {
+			continue
+		}
+		for _, queue := range queues {
+			a.queues[queue] <- amqp.Delivery{
+				Acknowledger: a,
+				Body:         msg.Body,
+				Headers:      msg.Headers,
+				MessageId:    msg.MessageId,
+				RoutingKey:   key,
+				Timestamp:    msg.Timestamp,
+			}
+		}
+	}
+	return nil
+}
+
+func (a *fakeAmqpBroker) Qos(_, _ int, _ bool) error { return nil }
+
+func (a *fakeAmqpBroker) QueueBind(name, key, _ string, _ bool, _ amqp.Table) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.bindings[key] = append(a.bindings[key], name)
+	return nil
+}
+
+func (a *fakeAmqpBroker) QueueDeclare(name string, _, _, _, _ bool, _ amqp.Table) (amqp.Queue, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if name == "" {
+		name = "amq.gen-" + messaging.NewMessage("", nil).ID[:8]
+	}
+	if _, ok := a.queues[name]; !ok {
+		a.queues[name] = make(chan amqp.Delivery, 100)
+	}
+	return amqp.Queue{Name: name}, nil
+}
+
+// matchRoutingKey matches a routing key against a pattern with "*" and "#" wildcards.
+func matchRoutingKey(pattern, key []string) bool {
+	if len(pattern) == 0 {
+		return len(key) == 0
+	}
+	if pattern[0] == "#" {
+		for i := 0; i <= len(key); i++ {
+			if matchRoutingKey(pattern[1:], key[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(key) == 0 || (pattern[0] != "*" && pattern[0] != key[0]) {
+		return false
+	}
+	return matchRoutingKey(pattern[1:], key[1:])
+}
+
+func Test_AmqpDispatcher_With_Roundtrip_Should_CallHandler(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	broker := newFakeAmqpBroker()
+	dis, err := messaging.NewAmqpDispatcherWithChannel(broker, messaging.AmqpDispatcherOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = dis.Close() }()
+	received := make(chan messaging.Message, 1)
+	fn := func(m messaging.Message) (messaging.MessageState, error) {
+		received <- m
+		return messaging.MessageStateCompleted, nil
+	}
+	msg := messaging.NewMessage("orders.eu.created", []byte("order-1")).
+		WithKey("user-1").
+		WithHeader(messaging.HeaderContentType, "text/plain")
+
+	// Act
+	_ = dis.Subscribe(ctx, "orders.>", service.Wrap(fn))
+	err = dis.Publish(ctx, msg)
+	got := <-received
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "message must be correct", got, msg)
+}
+
+func Test_AmqpDispatcher_With_FailedMessage_Should_Requeue(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	broker := newFakeAmqpBroker()
+	dis, _ := messaging.NewAmqpDispatcherWithChannel(broker, messaging.AmqpDispatcherOptions{
+		Queue:      "billing",
+		RetryDelay: time.Millisecond,
+	})
+	fn := func(_ messaging.Message) (messaging.MessageState, error) {
+		return messaging.MessageStateFailed, nil
+	}
+	sub, _ := dis.Listen(ctx, "orders.created", service.Wrap(fn))
+
+	// Act
+	_ = dis.Publish(ctx, messaging.NewMessage("orders.created", []byte("order-1")))
+	err := <-sub.Errors()
+	_ = dis.Close()
+
+	// Assert
+	assert.That(t, "err must be correct", errors.Is(err, messaging.ErrMessageNotCompleted), true)
+	assert.That(t, "nacked must be 1", broker.nacked, 1)
+	assert.That(t, "acked must be 0", broker.acked, 0)
+}
+
+func Test_AmqpDispatcher_With_RegexpTopic_Should_ReturnErrUnsupportedTopicPattern(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis, _ := messaging.NewAmqpDispatcherWithChannel(newFakeAmqpBroker(), messaging.AmqpDispatcherOptions{})
+	defer func() { _ = dis.Close() }()
+	fn := func(_ messaging.Message) (messaging.MessageState, error) {
+		return messaging.MessageStateCompleted, nil
+	}
+
+	// Act
+	err := dis.Subscribe(ctx, `^orders\..*$`, service.Wrap(fn))
+
+	// Assert
+	assert.That(t, "err must be correct", err, messaging.ErrUnsupportedTopicPattern)
+}
+
+func Test_NewDispatcher_With_UnknownDriver_Should_ReturnErrUnknownDriver(t *testing.T) {
+	// Arrange
+	t.Setenv("MESSAGING_DRIVER", "carrier-pigeon")
+
+	// Act
+	_, err := messaging.NewDispatcher(context.Background())
+
+	// Assert
+	assert.That(t, "err must be correct", err, messaging.ErrUnknownDriver)
+}
+
+func Test_NewDispatcher_With_NatsDriver_Should_Connect(t *testing.T) {
+	// Arrange
+	t.Setenv("MESSAGING_DRIVER", "nats")
+	t.Setenv("NATS_URL", startNatsServer(t))
+
+	// Act
+	dis, err := messaging.NewDispatcher(context.Background())
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	_, ok := dis.(*messaging.NatsDispatcher)
+	assert.That(t, "dispatcher must be a NatsDispatcher", ok, true)
+	_ = dis.(*messaging.NatsDispatcher).Close()
+}
@@ -2,100 +2,551 @@ package messaging
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"os"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/andygeiss/cloud-native-utils/env"
 	"github.com/andygeiss/cloud-native-utils/service"
 	"github.com/andygeiss/cloud-native-utils/stability"
 	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
 )
 
-// externalDispatcher dispatches messages to internal services.
-type externalDispatcher struct{}
+var (
+	ErrDispatcherClosed    = errors.New("dispatcher closed")
+	ErrMessageNotCompleted = errors.New("message not completed")
+	ErrNoMatchingTopics    = errors.New("no matching topics")
+)
+
+// errTopicsChanged restarts a wildcard subscription after the matching topics changed.
+var errTopicsChanged = errors.New("matching topics changed")
+
+// Acks defines how many replicas must acknowledge a published message.
+type Acks int
+
+const (
+	// AcksAll waits for all in-sync replicas to acknowledge a message.
+	AcksAll Acks = iota
+	// AcksLeader waits for the partition leader to acknowledge a message.
+	AcksLeader
+	// AcksNone does not wait for any acknowledgement.
+	AcksNone
+)
+
+// ExternalDispatcherOptions configures the Kafka connection of an ExternalDispatcher.
+type ExternalDispatcherOptions struct {
+	// Brokers are the addresses of the Kafka brokers. Default: KAFKA_BROKERS.
+	Brokers []string
+
+	// TLS enables encrypted connections, e.g. by using web.TLSClientConfig. Default: none.
+	TLS *tls.Config
+
+	// SASL enables authentication, e.g. by using plain.Mechanism or scram.Mechanism. Default: none.
+	SASL sasl.Mechanism
+
+	// Acks defines how many replicas must acknowledge a message. Default: AcksAll.
+	Acks Acks
+
+	// Compression defines the codec of published message batches. Default: none.
+	Compression kafka.Compression
+
+	// BatchSize limits the number of messages per batch. Default: 100.
+	BatchSize int
+
+	// BatchTimeout (linger) limits the time to wait for a batch to fill up. Default: 10ms.
+	BatchTimeout time.Duration
+
+	// GroupID is the consumer group of subscriptions. Instances of a service
+	// sharing the same group ID split the partitions of a topic among them.
+	// Default: KAFKA_CONSUMER_GROUP_ID or the subscribed topic.
+	GroupID string
+
+	// MaxDeliveries is the number of deliveries of a message, which is not completed,
+	// before it is published to the dead-letter topic and committed, so that a poison
+	// message does not stall its partition. Default: 5.
+	MaxDeliveries int
+
+	// DeadLetterTopic is the topic of messages exceeding MaxDeliveries.
+	// Default: original topic with the suffix ".dlq".
+	DeadLetterTopic string
+
+	// ReconnectDelay is the initial delay before a failed subscription reconnects.
+	// The delay doubles after every failure up to MaxReconnectDelay. Default: 1s.
+	ReconnectDelay time.Duration
+
+	// MaxReconnectDelay limits the delay before a failed subscription reconnects. Default: 30s.
+	MaxReconnectDelay time.Duration
 
-// NewExternalDispatcher creates a new externalDispatcher instance.
-func NewExternalDispatcher() Dispatcher {
-	return &externalDispatcher{}
+	// TopicRefreshInterval is the interval to look up new topics matching a wildcard subscription. Default: 1m.
+	TopicRefreshInterval time.Duration
+
+	// MaxRetries is the number of retries of a failed publish or handler call. Default: SERVICE_RETRY_MAX or 3.
+	MaxRetries int
+
+	// RetryDelay is the delay between two retries. Default: SERVICE_RETRY_DELAY or 5s.
+	RetryDelay time.Duration
+
+	// Timeout limits the duration of a publish or handler call. Default: SERVICE_TIMEOUT or 5s.
+	Timeout time.Duration
 }
 
-// Publish publishes a message to the dispatcher.
-func (a *externalDispatcher) Publish(ctx context.Context, message Message) error {
-	brokers := strings.Split(os.Getenv("KAFKA_BROKERS"), ",")
+// ExternalDispatcher dispatches messages to external services by using Kafka.
+// It owns a long-lived writer per topic, which batches messages of concurrent
+// publishers, and must be closed to flush and release them.
+//
+// Subscriptions consume all partitions of a topic as a member of a consumer group.
+// The offset of a message is committed only after the handler returned
+// MessageStateCompleted, so that messages are delivered at least once.
+// Messages, which are not completed after MaxDeliveries deliveries, are published
+// to the dead-letter topic with their failure metadata (see DeadLetter) and committed.
+// Wildcard subscriptions like "orders.*" consume all existing topics matching the
+// pattern (see TopicPattern) and pick up new topics periodically.
+type ExternalDispatcher struct {
+	options       ExternalDispatcherOptions
+	transport     *kafka.Transport
+	writers       map[string]*kafka.Writer
+	subscriptions []*Subscription
+	closed        bool
+	mutex         sync.Mutex
+}
 
-	// Create a new kafka writer.
-	w := &kafka.Writer{
-		Addr:                   kafka.TCP(brokers...),
-		AllowAutoTopicCreation: true,
-		Balancer:               &kafka.LeastBytes{},
-		Topic:                  message.Topic,
+// NewExternalDispatcher creates a new ExternalDispatcher instance
+// that is configured by environment variables.
+func NewExternalDispatcher() *ExternalDispatcher {
+	return NewExternalDispatcherWithOptions(ExternalDispatcherOptions{})
+}
+
+// NewExternalDispatcherWithOptions creates a new ExternalDispatcher instance
+// with the given options. Unset options are read from the environment.
+func NewExternalDispatcherWithOptions(options ExternalDispatcherOptions) *ExternalDispatcher {
+	options = options.withDefaults()
+	return &ExternalDispatcher{
+		options: options,
+		transport: &kafka.Transport{
+			SASL: options.SASL,
+			TLS:  options.TLS,
+		},
+		writers: make(map[string]*kafka.Writer),
+	}
+}
+
+// Close stops all subscriptions, flushes pending messages and closes all writers.
+func (a *ExternalDispatcher) Close() error {
+	a.mutex.Lock()
+	if a.closed {
+		a.mutex.Unlock()
+		return nil
+	}
+	a.closed = true
+	subs := a.subscriptions
+	a.subscriptions = nil
+	a.mutex.Unlock()
+
+	// Stop the subscriptions first to finish handling their messages.
+	// The lock is not held, since their handlers may publish messages.
+	for _, sub := range subs {
+		sub.Stop()
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	// Close the writers and keep the first error.
+	var closeErr error
+	for topic, w := range a.writers {
+		if err := w.Close(); err != nil && closeErr == nil {
+			closeErr = err
+		}
+		delete(a.writers, topic)
+	}
+	a.transport.CloseIdleConnections()
+	return closeErr
+}
+
+// Publish publishes a message to the dispatcher.
+func (a *ExternalDispatcher) Publish(ctx context.Context, message Message) error {
+	w, err := a.writer(message.Topic)
+	if err != nil {
+		return err
 	}
-	defer func() { _ = w.Close() }()
 
 	// Define a service.Function to write the messages.
 	fn := func() service.Function[Message, int] {
 		return func(ctx context.Context, in Message) (int, error) {
-			err := w.WriteMessages(ctx, kafka.Message{Value: message.Data})
-			return len(message.Data), err
+			err := w.WriteMessages(ctx, toKafkaMessage(in))
+			return len(in.Data), err
 		}
 	}()
 
 	// Use stability patterns to make the function more robust.
-	maxRetries := env.Get("SERVICE_RETRY_MAX", 3)
-	delay := env.Get("SERVICE_RETRY_DELAY", 5*time.Second)
-	duration := env.Get("SERVICE_TIMEOUT", 5*time.Second)
-	fn = stability.Retry(fn, maxRetries, delay)
-	fn = stability.Timeout(fn, duration)
+	fn = stability.Retry(fn, a.options.MaxRetries, a.options.RetryDelay)
+	fn = stability.Timeout(fn, a.options.Timeout)
 
 	// Execute and ignore the message length for now.
-	_, err := fn(ctx, message)
+	_, err = fn(ctx, message)
 
 	return err
 }
 
 // Subscribe adds a function to the list of functions that will be called when a message is published to the given topic.
-func (a *externalDispatcher) Subscribe(ctx context.Context, topic string, fn service.Function[Message, MessageState]) error {
-	go func(ctx context.Context) {
-		// Create a new kafka reader.
-		r := kafka.NewReader(kafka.ReaderConfig{
-			Brokers:   strings.Split(os.Getenv("KAFKA_BROKERS"), ","),
-			MaxBytes:  10e6, // 10MB
-			Partition: 0,
-			Topic:     topic,
-		})
-		defer func() { _ = r.Close() }()
-
-		// Use stability patterns to make the function more robust.
-		maxRetries := env.Get("SERVICE_RETRY_MAX", 3)
-		delay := env.Get("SERVICE_RETRY_DELAY", 5*time.Second)
-		duration := env.Get("SERVICE_TIMEOUT", 5*time.Second)
-		fn = stability.Retry(fn, maxRetries, delay)
-		fn = stability.Timeout(fn, duration)
-
-		for {
-			// Read the message from the kafka reader.
-			m, err := r.ReadMessage(ctx)
-			if err != nil {
-				break
+// The subscription runs until the context is canceled or the dispatcher is closed.
+func (a *ExternalDispatcher) Subscribe(ctx context.Context, topic string, fn service.Function[Message, MessageState]) error {
+	_, err := a.Listen(ctx, topic, fn)
+	return err
+}
+
+// Listen is like Subscribe, but returns the Subscription to observe and stop it.
+// A failed read, handler call or commit closes the connection and reconnects
+// with an exponential backoff. Uncommitted messages are redelivered afterwards.
+func (a *ExternalDispatcher) Listen(ctx context.Context, topic string, fn service.Function[Message, MessageState]) (*Subscription, error) {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	pattern, err := ParseTopicPattern(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	// Subscriptions without a configured group consume the topic in a group of their own.
+	group := a.options.GroupID
+	if group == "" {
+		group = topic
+	}
+
+	// Use stability patterns to make the function more robust.
+	fn = stability.Retry(fn, a.options.MaxRetries, a.options.RetryDelay)
+	fn = stability.Timeout(fn, a.options.Timeout)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return nil, ErrDispatcherClosed
+	}
+	sub := newSubscription(ctx, func(ctx context.Context, report func(error)) {
+		a.consume(ctx, group, pattern, fn, report)
+	})
+	a.subscriptions = append(a.subscriptions, sub)
+	go a.forget(sub)
+	return sub, nil
+}
+
+// forget removes the subscription after it has stopped.
+func (a *ExternalDispatcher) forget(sub *Subscription) {
+	<-sub.Done()
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.subscriptions = removeSubscription(a.subscriptions, sub)
+}
+
+// consume reads messages of the matching topics until the context is done and reconnects after failures.
+// The deliveries of messages, which were not completed, are counted across reconnects.
+func (a *ExternalDispatcher) consume(ctx context.Context, group string, pattern TopicPattern, fn service.Function[Message, MessageState], report func(error)) {
+	delay := a.options.ReconnectDelay
+	deliveries := make(map[kafkaPartition]kafkaDelivery)
+	for {
+		committed, err := a.consumeTopics(ctx, group, pattern, fn, deliveries, report)
+
+		// Stop if context is canceled or timed out.
+		if ctx.Err() != nil {
+			return
+		}
+		// Reconnect immediately if the topics of a wildcard subscription changed.
+		if errors.Is(err, errTopicsChanged) {
+			continue
+		}
+		report(err)
+
+		// Reset the backoff if the connection made progress.
+		if committed {
+			delay = a.options.ReconnectDelay
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, a.options.MaxReconnectDelay)
+	}
+}
+
+// consumeTopics creates a reader for the topics matching the pattern and consumes
+// its messages. A wildcard subscription restarts the reader if the matching topics change.
+func (a *ExternalDispatcher) consumeTopics(ctx context.Context, group string, pattern TopicPattern, fn service.Function[Message, MessageState], deliveries map[kafkaPartition]kafkaDelivery, report func(error)) (bool, error) {
+	config := kafka.ReaderConfig{
+		Brokers:     a.options.Brokers,
+		Dialer:      a.dialer(),
+		GroupID:     group,
+		MaxBytes:    10e6, // 10MB
+		StartOffset: kafka.FirstOffset,
+	}
+	if !pattern.IsWildcard() {
+		config.Topic = pattern.String()
+	} else {
+		topics, err := a.matchTopics(ctx, pattern)
+		if err != nil {
+			return false, err
+		}
+		if len(topics) == 0 {
+			return false, ErrNoMatchingTopics
+		}
+		config.GroupTopics = topics
+
+		// Cancel the reader if the matching topics change.
+		var cancel context.CancelCauseFunc
+		ctx, cancel = context.WithCancelCause(ctx)
+		defer cancel(nil)
+		go a.watchTopics(ctx, pattern, topics, cancel)
+	}
+
+	// Create a new kafka reader, which resumes at the last committed offset.
+	r := kafka.NewReader(config)
+	defer func() { _ = r.Close() }()
+	committed, err := a.consumeMessages(ctx, r, fn, deliveries, report)
+	if cause := context.Cause(ctx); errors.Is(cause, errTopicsChanged) {
+		return committed, cause
+	}
+	return committed, err
+}
+
+// matchTopics returns the sorted names of the existing topics matching the pattern.
+func (a *ExternalDispatcher) matchTopics(ctx context.Context, pattern TopicPattern) ([]string, error) {
+	client := &kafka.Client{Addr: kafka.TCP(a.options.Brokers...), Transport: a.transport}
+	res, err := client.Metadata(ctx, &kafka.MetadataRequest{})
+	if err != nil {
+		return nil, err
+	}
+	var topics []string
+	for _, t := range res.Topics {
+		if t.Error == nil && !t.Internal && pattern.Match(t.Name) {
+			topics = append(topics, t.Name)
+		}
+	}
+	sort.Strings(topics)
+	return topics, nil
+}
+
+// watchTopics cancels the context if the topics matching the pattern differ from the given topics.
+func (a *ExternalDispatcher) watchTopics(ctx context.Context, pattern TopicPattern, topics []string, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(a.options.TopicRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// Errors are ignored, since the reader reports connection failures itself.
+		current, err := a.matchTopics(ctx, pattern)
+		if err == nil && !slices.Equal(current, topics) {
+			cancel(errTopicsChanged)
+			return
+		}
+	}
+}
+
+// consumeMessages passes fetched messages to the function and commits them if they are completed.
+// Messages exceeding the maximum deliveries are dead-lettered and committed instead.
+// It returns whether a message has been committed and the error which stopped the reader.
+func (a *ExternalDispatcher) consumeMessages(ctx context.Context, r *kafka.Reader, fn service.Function[Message, MessageState], deliveries map[kafkaPartition]kafkaDelivery, report func(error)) (bool, error) {
+	committed := false
+	for {
+		m, err := r.FetchMessage(ctx)
+		if err != nil {
+			return committed, err
+		}
+		message := fromKafkaMessage(m)
+		state, err := fn(ctx, message)
+		if err == nil && state != MessageStateCompleted {
+			err = ErrMessageNotCompleted
+		}
+		partition := kafkaPartition{topic: m.Topic, partition: m.Partition}
+		if err != nil {
+			// Stop if context is canceled or timed out.
+			if ctx.Err() != nil {
+				return committed, err
+			}
+			// Count the deliveries of the message at the offset.
+			delivery := deliveries[partition]
+			if delivery.offset != m.Offset {
+				delivery = kafkaDelivery{offset: m.Offset}
 			}
+			delivery.count++
+			deliveries[partition] = delivery
 
-			// Transform the kafka message.
-			msg := Message{
-				Data:  m.Value,
-				State: MessageStateCreated,
-				Topic: topic,
+			// Stop without committing to redeliver the message after reconnecting.
+			if delivery.count < a.options.MaxDeliveries {
+				return committed, err
 			}
+			if dlqErr := a.deadLetter(ctx, message, delivery.count, err); dlqErr != nil {
+				return committed, errors.Join(err, dlqErr)
+			}
+			report(err)
+		}
+		delete(deliveries, partition)
+		if err := r.CommitMessages(ctx, m); err != nil {
+			return committed, err
+		}
+		committed = true
+	}
+}
+
+// deadLetter publishes a message exceeding the maximum deliveries to the dead-letter topic.
+func (a *ExternalDispatcher) deadLetter(ctx context.Context, message Message, deliveries int, err error) error {
+	failed := failedMessage(message, deliveries, err)
+	failed.Topic = a.options.DeadLetterTopic
+	if failed.Topic == "" {
+		failed.Topic = failed.Headers[HeaderOriginalTopic] + ".dlq"
+	}
+	return a.Publish(ctx, failed)
+}
+
+// kafkaPartition identifies a partition of a topic.
+type kafkaPartition struct {
+	topic     string
+	partition int
+}
+
+// kafkaDelivery counts the deliveries of the message at an offset of a partition.
+type kafkaDelivery struct {
+	offset int64
+	count  int
+}
+
+// dialer creates a kafka dialer using the TLS and SASL options.
+func (a *ExternalDispatcher) dialer() *kafka.Dialer {
+	return &kafka.Dialer{
+		DualStack:     true,
+		SASLMechanism: a.options.SASL,
+		TLS:           a.options.TLS,
+		Timeout:       10 * time.Second,
+	}
+}
+
+// writer returns the writer of the given topic and creates it if necessary.
+func (a *ExternalDispatcher) writer(topic string) (*kafka.Writer, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return nil, ErrDispatcherClosed
+	}
+	if w, ok := a.writers[topic]; ok {
+		return w, nil
+	}
+
+	// Create a new kafka writer, which uses the message key to select the partition.
+	w := &kafka.Writer{
+		Addr:                   kafka.TCP(a.options.Brokers...),
+		AllowAutoTopicCreation: true,
+		Balancer:               &kafka.Hash{},
+		BatchSize:              a.options.BatchSize,
+		BatchTimeout:           a.options.BatchTimeout,
+		Compression:            a.options.Compression,
+		RequiredAcks:           a.options.Acks.requiredAcks(),
+		Topic:                  topic,
+		Transport:              a.transport,
+	}
+	a.writers[topic] = w
+	return w, nil
+}
 
-			_, _ = fn(ctx, msg)
+// fromKafkaMessage transforms a kafka message into a message.
+// The message ID is restored from its header.
+func fromKafkaMessage(m kafka.Message) Message {
+	msg := Message{
+		Data:      m.Value,
+		Key:       string(m.Key),
+		State:     MessageStateCreated,
+		Timestamp: m.Time.UTC(),
+		Topic:     m.Topic,
+	}
+	for _, h := range m.Headers {
+		if h.Key == HeaderMessageID {
+			msg.ID = string(h.Value)
+			continue
+		}
+		if msg.Headers == nil {
+			msg.Headers = make(map[string]string, len(m.Headers))
 		}
-	}(ctx)
+		msg.Headers[h.Key] = string(h.Value)
+	}
+	return msg
+}
 
-	// Handle ...
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
+// toKafkaMessage transforms a message into a kafka message.
+// The topic is set by the writer and the message ID is stored as a header.
+func toKafkaMessage(message Message) kafka.Message {
+	m := kafka.Message{Time: message.Timestamp, Value: message.Data}
+	if message.Key != "" {
+		m.Key = []byte(message.Key)
+	}
+	if message.ID != "" {
+		m.Headers = append(m.Headers, kafka.Header{Key: HeaderMessageID, Value: []byte(message.ID)})
+	}
+	// Sort the headers to produce a deterministic order.
+	keys := make([]string, 0, len(message.Headers))
+	for key := range message.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		m.Headers = append(m.Headers, kafka.Header{Key: key, Value: []byte(message.Headers[key])})
+	}
+	return m
+}
+
+// requiredAcks maps the acks to the kafka representation.
+func (a Acks) requiredAcks() kafka.RequiredAcks {
+	switch a {
+	case AcksLeader:
+		return kafka.RequireOne
+	case AcksNone:
+		return kafka.RequireNone
 	default:
-		return nil
+		return kafka.RequireAll
+	}
+}
+
+// withDefaults replaces unset options with their default values.
+func (a ExternalDispatcherOptions) withDefaults() ExternalDispatcherOptions {
+	if len(a.Brokers) == 0 {
+		a.Brokers = strings.Split(os.Getenv("KAFKA_BROKERS"), ",")
+	}
+	if a.BatchSize <= 0 {
+		a.BatchSize = 100
+	}
+	if a.BatchTimeout <= 0 {
+		a.BatchTimeout = 10 * time.Millisecond
+	}
+	if a.GroupID == "" {
+		a.GroupID = os.Getenv("KAFKA_CONSUMER_GROUP_ID")
+	}
+	if a.MaxDeliveries <= 0 {
+		a.MaxDeliveries = 5
+	}
+	if a.ReconnectDelay <= 0 {
+		a.ReconnectDelay = time.Second
+	}
+	if a.MaxReconnectDelay <= 0 {
+		a.MaxReconnectDelay = 30 * time.Second
+	}
+	if a.TopicRefreshInterval <= 0 {
+		a.TopicRefreshInterval = time.Minute
+	}
+	if a.MaxRetries <= 0 {
+		a.MaxRetries = env.Get("SERVICE_RETRY_MAX", 3)
+	}
+	if a.RetryDelay <= 0 {
+		a.RetryDelay = env.Get("SERVICE_RETRY_DELAY", 5*time.Second)
+	}
+	if a.Timeout <= 0 {
+		a.Timeout = env.Get("SERVICE_TIMEOUT", 5*time.Second)
 	}
+	return a
 }
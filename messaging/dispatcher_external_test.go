@@ -14,6 +14,7 @@ import (
 //nolint:gochecknoinits // test setup requires init for Kafka broker configuration
 func init() {
 	_ = os.Setenv("KAFKA_BROKERS", "localhost:9092,localhost:9093")
+	_ = os.Setenv("KAFKA_CONSUMER_GROUP_ID", "cloud-native-utils-test")
 }
 
 func Test_ExternalDispatcher_With_PublishMessage_Should_Succeed(t *testing.T) {
@@ -103,3 +104,261 @@ func Test_ExternalDispatcher_With_SubscribeHandler_Should_Succeed(t *testing.T)
 	// Assert
 	assert.That(t, "err must be nil", err, nil)
 }
+
+func Test_ExternalDispatcher_With_PublishAfterClose_Should_ReturnErrDispatcherClosed(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewExternalDispatcherWithOptions(messaging.ExternalDispatcherOptions{
+		Brokers: []string{"localhost:9092"},
+	})
+	_ = dis.Close()
+
+	// Act
+	err := dis.Publish(ctx, messaging.NewMessage("my-topic", []byte("test")))
+
+	// Assert
+	assert.That(t, "err must be correct", err, messaging.ErrDispatcherClosed)
+}
+
+func Test_ExternalDispatcher_With_CloseTwice_Should_Succeed(t *testing.T) {
+	// Arrange
+	dis := messaging.NewExternalDispatcherWithOptions(messaging.ExternalDispatcherOptions{
+		Brokers: []string{"localhost:9092"},
+	})
+	_ = dis.Close()
+
+	// Act
+	err := dis.Close()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+}
+
+func Test_ExternalDispatcher_With_KeyAndHeaders_Should_Roundtrip(t *testing.T) {
+	// Skip this integration test.
+	if testing.Short() {
+		return
+	}
+
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	dis := messaging.NewExternalDispatcherWithOptions(messaging.ExternalDispatcherOptions{
+		Acks:         messaging.AcksLeader,
+		BatchTimeout: time.Millisecond,
+	})
+	defer func() { _ = dis.Close() }()
+	msg := messaging.NewMessage("my-keyed-topic", []byte("my message"))
+	msg.Key = "user-1"
+	msg.Headers = map[string]string{"trace-id": "42"}
+	received := make(chan messaging.Message, 1)
+	fn := func(m messaging.Message) (messaging.MessageState, error) {
+		received <- m
+		return messaging.MessageStateCompleted, nil
+	}
+
+	// Act
+	_ = dis.Subscribe(ctx, "my-keyed-topic", service.Wrap(fn))
+	err := dis.Publish(ctx, msg)
+	var got messaging.Message
+	select {
+	case got = <-received:
+	case <-time.After(10 * time.Second):
+		t.Fatal("message must be received")
+	}
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "key must be correct", got.Key, "user-1")
+	assert.That(t, "header must be correct", got.Headers["trace-id"], "42")
+	assert.That(t, "id must be correct", got.ID, msg.ID)
+}
+
+func Test_ExternalDispatcher_With_MissingGroupID_Should_Subscribe(t *testing.T) {
+	// Arrange
+	t.Setenv("KAFKA_CONSUMER_GROUP_ID", "")
+	ctx := context.Background()
+	dis := messaging.NewExternalDispatcherWithOptions(messaging.ExternalDispatcherOptions{
+		Brokers: []string{"localhost:1"},
+	})
+	defer func() { _ = dis.Close() }()
+	fn := func(_ messaging.Message) (messaging.MessageState, error) {
+		return messaging.MessageStateCompleted, nil
+	}
+
+	// Act
+	err := dis.Subscribe(ctx, "my-topic", service.Wrap(fn))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+}
+
+func Test_ExternalDispatcher_With_StoppedSubscription_Should_BeDone(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewExternalDispatcherWithOptions(messaging.ExternalDispatcherOptions{
+		Brokers: []string{"localhost:1"},
+		GroupID: "my-group",
+	})
+	defer func() { _ = dis.Close() }()
+	fn := func(_ messaging.Message) (messaging.MessageState, error) {
+		return messaging.MessageStateCompleted, nil
+	}
+	sub, err := dis.Listen(ctx, "my-topic", service.Wrap(fn))
+
+	// Act
+	sub.Stop()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	select {
+	case <-sub.Done():
+	default:
+		t.Fatal("subscription must be done")
+	}
+}
+
+func Test_ExternalDispatcher_With_Close_Should_StopSubscriptions(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewExternalDispatcherWithOptions(messaging.ExternalDispatcherOptions{
+		Brokers: []string{"localhost:1"},
+		GroupID: "my-group",
+	})
+	fn := func(_ messaging.Message) (messaging.MessageState, error) {
+		return messaging.MessageStateCompleted, nil
+	}
+	sub, _ := dis.Listen(ctx, "my-topic", service.Wrap(fn))
+
+	// Act
+	_ = dis.Close()
+	_, err := dis.Listen(ctx, "my-topic", service.Wrap(fn))
+
+	// Assert
+	assert.That(t, "err must be correct", err, messaging.ErrDispatcherClosed)
+	select {
+	case <-sub.Done():
+	case <-time.After(time.Second):
+		t.Fatal("subscription must be done")
+	}
+}
+
+func Test_ExternalDispatcher_With_FailedMessage_Should_Redeliver(t *testing.T) {
+	// Skip this integration test.
+	if testing.Short() {
+		return
+	}
+
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	dis := messaging.NewExternalDispatcherWithOptions(messaging.ExternalDispatcherOptions{
+		GroupID:        "redeliver-test",
+		ReconnectDelay: 10 * time.Millisecond,
+	})
+	defer func() { _ = dis.Close() }()
+	calls := make(chan int, 2)
+	count := 0
+	fn := func(_ messaging.Message) (messaging.MessageState, error) {
+		count++
+		calls <- count
+		if count == 1 {
+			return messaging.MessageStateFailed, nil
+		}
+		return messaging.MessageStateCompleted, nil
+	}
+
+	// Act
+	sub, _ := dis.Listen(ctx, "my-redeliver-topic", service.Wrap(fn))
+	_ = dis.Publish(ctx, messaging.NewMessage("my-redeliver-topic", []byte("my message")))
+	var second int
+	var err error
+	for i := range 2 {
+		select {
+		case second = <-calls:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("call %d must be made", i+1)
+		}
+	}
+	select {
+	case err = <-sub.Errors():
+	case <-time.After(time.Second):
+		t.Fatal("error must be reported")
+	}
+
+	// Assert
+	assert.That(t, "second call must be 2", second, 2)
+	assert.That(t, "err must be correct", err, messaging.ErrMessageNotCompleted)
+}
+
+func Test_ExternalDispatcher_With_WildcardSubscription_Should_CallHandler(t *testing.T) {
+	// Skip this integration test.
+	if testing.Short() {
+		return
+	}
+
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	dis := messaging.NewExternalDispatcherWithOptions(messaging.ExternalDispatcherOptions{
+		GroupID: "wildcard-test",
+	})
+	defer func() { _ = dis.Close() }()
+	_ = dis.Publish(ctx, messaging.NewMessage("wildcard.orders.created", []byte("my message")))
+	received := make(chan messaging.Message, 1)
+	fn := func(m messaging.Message) (messaging.MessageState, error) {
+		received <- m
+		return messaging.MessageStateCompleted, nil
+	}
+
+	// Act
+	_ = dis.Subscribe(ctx, "wildcard.orders.*", service.Wrap(fn))
+	var got messaging.Message
+	select {
+	case got = <-received:
+	case <-time.After(10 * time.Second):
+		t.Fatal("message must be received")
+	}
+
+	// Assert
+	assert.That(t, "topic must be correct", got.Topic, "wildcard.orders.created")
+}
+
+func Test_ExternalDispatcher_With_PoisonMessage_Should_DeadLetterAndContinue(t *testing.T) {
+	// Skip this integration test.
+	if testing.Short() {
+		return
+	}
+
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	dis := messaging.NewExternalDispatcherWithOptions(messaging.ExternalDispatcherOptions{
+		GroupID:        "poison-test",
+		MaxDeliveries:  2,
+		MaxRetries:     1,
+		ReconnectDelay: 10 * time.Millisecond,
+	})
+	defer func() { _ = dis.Close() }()
+	dead := make(chan messaging.Message, 1)
+	_ = dis.Subscribe(ctx, "my-poison-topic.dlq", service.Wrap(func(m messaging.Message) (messaging.MessageState, error) {
+		dead <- m
+		return messaging.MessageStateCompleted, nil
+	}))
+	_ = dis.Subscribe(ctx, "my-poison-topic", service.Wrap(func(_ messaging.Message) (messaging.MessageState, error) {
+		return messaging.MessageStateFailed, nil
+	}))
+
+	// Act
+	_ = dis.Publish(ctx, messaging.NewMessage("my-poison-topic", []byte("poison")))
+	var got messaging.Message
+	select {
+	case got = <-dead:
+	case <-time.After(20 * time.Second):
+		t.Fatal("message must be dead-lettered")
+	}
+
+	// Assert
+	assert.That(t, "data must be correct", string(got.Data), "poison")
+	assert.That(t, "attempts must be correct", got.Headers[messaging.HeaderAttempts], "2")
+}
@@ -2,68 +2,282 @@ package messaging
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"sync"
 
 	"github.com/andygeiss/cloud-native-utils/service"
 )
 
-// internalDispatcher dispatches messages to internal services.
-type internalDispatcher struct {
-	fns map[string][]service.Function[Message, MessageState]
+// DeliveryMode defines whether Publish waits for the subscribers.
+type DeliveryMode int
+
+const (
+	// DeliveryModeWaitForAll waits until all subscribers handled the message
+	// and returns their aggregated errors.
+	DeliveryModeWaitForAll DeliveryMode = iota
+	// DeliveryModeFireAndForget returns after the message has been queued.
+	// Errors of the subscribers are reported by their Subscription.
+	DeliveryModeFireAndForget
+)
+
+// InternalDispatcherOptions configures an InternalDispatcher.
+type InternalDispatcherOptions struct {
+	// Mode defines whether Publish waits for the subscribers. Default: DeliveryModeWaitForAll.
+	Mode DeliveryMode
+
+	// QueueSize limits the number of queued messages per subscriber.
+	// Publish blocks while the queue of a subscriber is full. Default: 100.
+	QueueSize int
 }
 
-// NewInternalDispatcher creates a new internalDispatcher instance.
-func NewInternalDispatcher() Dispatcher {
-	return &internalDispatcher{
-		fns: make(map[string][]service.Function[Message, MessageState]),
-	}
+// InternalDispatcher dispatches messages to internal services.
+// Each subscriber handles its messages in order by using its own bounded queue
+// and goroutine, so that a slow subscriber does not block the others.
+// Subscriptions may use topic patterns like "orders.*" or "orders.>" (see TopicPattern).
+// It is safe for concurrent use.
+type InternalDispatcher struct {
+	options     InternalDispatcherOptions
+	subscribers map[string][]*internalSubscriber
+	closed      bool
+	mutex       sync.RWMutex
 }
 
-// Publish publishes a message to the dispatcher.
-func (a *internalDispatcher) Publish(ctx context.Context, message Message) error {
-	fns := a.fns[message.Topic]
+// internalSubscriber is a function subscribed to a topic.
+type internalSubscriber struct {
+	pattern TopicPattern
+	fn      service.Function[Message, MessageState]
+	queue   chan internalDelivery
+	sub     *Subscription
+}
+
+// internalDelivery is a message queued for a subscriber.
+type internalDelivery struct {
+	ctx     context.Context //nolint:containedctx // the context of the publisher is passed to the subscriber
+	message Message
+	result  chan error // Receives the error of the subscriber if Publish waits.
+}
 
-	// Skip publishing if there are no subscribers.
-	if len(fns) == 0 {
+// NewInternalDispatcher creates a new InternalDispatcher instance.
+func NewInternalDispatcher() *InternalDispatcher {
+	return NewInternalDispatcherWithOptions(InternalDispatcherOptions{})
+}
+
+// NewInternalDispatcherWithOptions creates a new InternalDispatcher instance with the given options.
+func NewInternalDispatcherWithOptions(options InternalDispatcherOptions) *InternalDispatcher {
+	if options.QueueSize <= 0 {
+		options.QueueSize = 100
+	}
+	return &InternalDispatcher{
+		options:     options,
+		subscribers: make(map[string][]*internalSubscriber),
+	}
+}
+
+// Close stops accepting messages and waits until all queued messages have been handled.
+func (a *InternalDispatcher) Close() error {
+	a.mutex.Lock()
+	if a.closed {
+		a.mutex.Unlock()
 		return nil
 	}
+	a.closed = true
+	var subs []*Subscription
+	for _, subscribers := range a.subscribers {
+		for _, s := range subscribers {
+			subs = append(subs, s.sub)
+		}
+	}
+	a.mutex.Unlock()
 
-	errChan := make(chan error)
-	stateChan := make(chan MessageState)
+	// Stop the subscriptions, which drain their queues before stopping.
+	for _, sub := range subs {
+		sub.Stop()
+	}
+	return nil
+}
 
-	// Start processing functions in parallel.
-	for _, fn := range fns {
-		go func() {
-			state, err := fn(ctx, message)
-			if err != nil {
-				errChan <- err
-			}
-			stateChan <- state
-		}()
+// Publish publishes a message to the dispatcher.
+// Depending on the delivery mode, it waits until all subscribers handled the
+// message and returns their aggregated errors, or returns after queuing it.
+// A subscriber must not publish to its own topic while Publish waits for it.
+func (a *InternalDispatcher) Publish(ctx context.Context, message Message) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	// Wait for all functions to finish.
-	for range fns {
+	// Copy the subscribers to avoid holding the lock while waiting.
+	a.mutex.RLock()
+	if a.closed {
+		a.mutex.RUnlock()
+		return ErrDispatcherClosed
+	}
+	subscribers := a.match(message.Topic)
+	a.mutex.RUnlock()
+
+	// Queue the message for each subscriber.
+	wait := a.options.Mode == DeliveryModeWaitForAll
+	results := make([]chan error, 0, len(subscribers))
+	for _, s := range subscribers {
+		d := internalDelivery{ctx: ctx, message: message}
+		if wait {
+			d.result = make(chan error, 1)
+		} else {
+			// Keep the context values, but don't cancel the handling after returning.
+			d.ctx = context.WithoutCancel(ctx)
+		}
 		select {
-		// Handle context cancellation.
+		case s.queue <- d:
+			results = append(results, d.result)
+		case <-s.sub.Done():
+			// Skip subscribers which have been unsubscribed in the meantime.
+			results = append(results, nil)
 		case <-ctx.Done():
 			return ctx.Err()
-		case err := <-errChan:
-			return err
-		case <-stateChan:
 		}
 	}
+	if !wait {
+		return nil
+	}
 
-	return nil
+	// Wait for all subscribers to finish and aggregate their errors.
+	var errs []error
+	for i, result := range results {
+		if result == nil {
+			continue
+		}
+		select {
+		case err := <-result:
+			if err != nil {
+				errs = append(errs, err)
+			}
+		case <-subscribers[i].sub.Done():
+			// The message may have been handled while draining the queue.
+			select {
+			case err := <-result:
+				if err != nil {
+					errs = append(errs, err)
+				}
+			default:
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
 }
 
 // Subscribe adds a function to the list of functions that will be called when a message is published to the given topic.
-func (a *internalDispatcher) Subscribe(ctx context.Context, topic string, fn service.Function[Message, MessageState]) error {
-	// Initialize the slice if it doesn't exist.
-	if _, ok := a.fns[topic]; !ok {
-		a.fns[topic] = make([]service.Function[Message, MessageState], 0)
+// The topic may be a pattern like "orders.*" or "orders.>" to match multiple topics.
+// The subscription runs until the context is canceled or the dispatcher is closed.
+func (a *InternalDispatcher) Subscribe(ctx context.Context, topic string, fn service.Function[Message, MessageState]) error {
+	_, err := a.Listen(ctx, topic, fn)
+	return err
+}
+
+// Listen is like Subscribe, but returns the Subscription to observe it or to remove it by using Unsubscribe.
+func (a *InternalDispatcher) Listen(ctx context.Context, topic string, fn service.Function[Message, MessageState]) (*Subscription, error) {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	pattern, err := ParseTopicPattern(topic)
+	if err != nil {
+		return nil, err
 	}
 
-	// Add the function to the list of functions for the given topic.
-	a.fns[topic] = append(a.fns[topic], fn)
-	return nil
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return nil, ErrDispatcherClosed
+	}
+	s := &internalSubscriber{
+		fn:      fn,
+		pattern: pattern,
+		queue:   make(chan internalDelivery, a.options.QueueSize),
+	}
+	s.sub = newSubscription(ctx, func(ctx context.Context, report func(error)) {
+		defer a.remove(topic, s)
+		s.run(ctx, report)
+	})
+	a.subscribers[topic] = append(a.subscribers[topic], s)
+	return s.sub, nil
+}
+
+// Unsubscribe removes the subscription from the dispatcher.
+// Already queued messages are still handled, which can be awaited by using its Done channel.
+func (a *InternalDispatcher) Unsubscribe(sub *Subscription) {
+	a.mutex.Lock()
+	for topic, subscribers := range a.subscribers {
+		for _, s := range subscribers {
+			if s.sub == sub {
+				a.removeLocked(topic, s)
+			}
+		}
+	}
+	a.mutex.Unlock()
+	sub.cancel()
+}
+
+// match returns the subscribers of all patterns matching the topic. The caller must hold the lock.
+func (a *InternalDispatcher) match(topic string) []*internalSubscriber {
+	var subscribers []*internalSubscriber
+	for _, candidates := range a.subscribers {
+		if len(candidates) > 0 && candidates[0].pattern.Match(topic) {
+			subscribers = append(subscribers, candidates...)
+		}
+	}
+	return subscribers
+}
+
+// remove removes the subscriber from the topic.
+func (a *InternalDispatcher) remove(topic string, s *internalSubscriber) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.removeLocked(topic, s)
+}
+
+// removeLocked removes the subscriber from the topic. The caller must hold the lock.
+func (a *InternalDispatcher) removeLocked(topic string, s *internalSubscriber) {
+	subscribers := slices.DeleteFunc(slices.Clone(a.subscribers[topic]), func(other *internalSubscriber) bool {
+		return other == s
+	})
+	if len(subscribers) == 0 {
+		delete(a.subscribers, topic)
+		return
+	}
+	a.subscribers[topic] = subscribers
+}
+
+// run handles the queued messages until the context is done and drains the queue afterwards.
+func (a *internalSubscriber) run(ctx context.Context, report func(error)) {
+	for {
+		select {
+		case d := <-a.queue:
+			a.handle(d, report)
+		case <-ctx.Done():
+			for {
+				select {
+				case d := <-a.queue:
+					a.handle(d, report)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// handle calls the function and passes its error to the publisher or reports it.
+func (a *internalSubscriber) handle(d internalDelivery, report func(error)) {
+	_, err := a.fn(d.ctx, d.message)
+	if d.result != nil {
+		d.result <- err
+		return
+	}
+	report(err)
 }
@@ -2,6 +2,9 @@ package messaging_test
 
 import (
 	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -77,3 +80,177 @@ func Test_InternalDispatcher_With_SubscribeHandler_Should_Succeed(t *testing.T)
 	// Assert
 	assert.That(t, "err must be nil", err, nil)
 }
+
+func Test_InternalDispatcher_With_MessageMetadata_Should_PreserveMetadata(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	msg := messaging.NewMessage("my topic", []byte("my message")).
+		WithKey("my key").
+		WithHeader(messaging.HeaderContentType, "application/json")
+	var got messaging.Message
+	fn := func(m messaging.Message) (messaging.MessageState, error) {
+		got = m
+		return messaging.MessageStateCompleted, nil
+	}
+
+	// Act
+	_ = dis.Subscribe(ctx, "my topic", service.Wrap(fn))
+	_ = dis.Publish(ctx, msg)
+
+	// Assert
+	assert.That(t, "message must be preserved", got, msg)
+}
+
+func Test_InternalDispatcher_With_ConcurrentSubscribeAndPublish_Should_CallHandlers(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	var calls atomic.Int64
+	fn := func(_ messaging.Message) (messaging.MessageState, error) {
+		calls.Add(1)
+		return messaging.MessageStateCompleted, nil
+	}
+
+	// Act
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			_ = dis.Subscribe(ctx, "my topic", service.Wrap(fn))
+		})
+	}
+	wg.Wait()
+	for range 10 {
+		wg.Go(func() {
+			_ = dis.Publish(ctx, messaging.NewMessage("my topic", []byte("my message")))
+		})
+	}
+	wg.Wait()
+
+	// Assert
+	assert.That(t, "calls must be 100", calls.Load(), int64(100))
+}
+
+func Test_InternalDispatcher_With_FailingHandlers_Should_AggregateErrors(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	errA := errors.New("a")
+	errB := errors.New("b")
+	_ = dis.Subscribe(ctx, "my topic", service.Wrap(func(_ messaging.Message) (messaging.MessageState, error) {
+		return messaging.MessageStateFailed, errA
+	}))
+	_ = dis.Subscribe(ctx, "my topic", service.Wrap(func(_ messaging.Message) (messaging.MessageState, error) {
+		return messaging.MessageStateFailed, errB
+	}))
+
+	// Act
+	err := dis.Publish(ctx, messaging.NewMessage("my topic", []byte("my message")))
+
+	// Assert
+	assert.That(t, "err must contain a", errors.Is(err, errA), true)
+	assert.That(t, "err must contain b", errors.Is(err, errB), true)
+}
+
+func Test_InternalDispatcher_With_Unsubscribe_Should_NotCallHandler(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	val := 0
+	fn := func(_ messaging.Message) (messaging.MessageState, error) {
+		val++
+		return messaging.MessageStateCompleted, nil
+	}
+	sub, _ := dis.Listen(ctx, "my topic", service.Wrap(fn))
+	_ = dis.Publish(ctx, messaging.NewMessage("my topic", []byte("my message")))
+
+	// Act
+	dis.Unsubscribe(sub)
+	<-sub.Done()
+	err := dis.Publish(ctx, messaging.NewMessage("my topic", []byte("my message")))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "val must be 1", val, 1)
+}
+
+func Test_InternalDispatcher_With_FireAndForget_Should_ReportErrors(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcherWithOptions(messaging.InternalDispatcherOptions{
+		Mode: messaging.DeliveryModeFireAndForget,
+	})
+	defer func() { _ = dis.Close() }()
+	errFailed := errors.New("failed")
+	sub, _ := dis.Listen(ctx, "my topic", service.Wrap(func(_ messaging.Message) (messaging.MessageState, error) {
+		return messaging.MessageStateFailed, errFailed
+	}))
+
+	// Act
+	err := dis.Publish(ctx, messaging.NewMessage("my topic", []byte("my message")))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "reported err must be correct", <-sub.Errors(), errFailed)
+}
+
+func Test_InternalDispatcher_With_Close_Should_DrainQueues(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcherWithOptions(messaging.InternalDispatcherOptions{
+		Mode: messaging.DeliveryModeFireAndForget,
+	})
+	var calls atomic.Int64
+	_ = dis.Subscribe(ctx, "my topic", service.Wrap(func(_ messaging.Message) (messaging.MessageState, error) {
+		time.Sleep(time.Millisecond)
+		calls.Add(1)
+		return messaging.MessageStateCompleted, nil
+	}))
+	for range 10 {
+		_ = dis.Publish(ctx, messaging.NewMessage("my topic", []byte("my message")))
+	}
+
+	// Act
+	err := dis.Close()
+	publishErr := dis.Publish(ctx, messaging.NewMessage("my topic", []byte("my message")))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "calls must be 10", calls.Load(), int64(10))
+	assert.That(t, "publish err must be correct", publishErr, messaging.ErrDispatcherClosed)
+}
+
+func Test_InternalDispatcher_With_WildcardSubscription_Should_CallHandler(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	var topics []string
+	fn := func(m messaging.Message) (messaging.MessageState, error) {
+		topics = append(topics, m.Topic)
+		return messaging.MessageStateCompleted, nil
+	}
+	_ = dis.Subscribe(ctx, "orders.>", service.Wrap(fn))
+
+	// Act
+	_ = dis.Publish(ctx, messaging.NewMessage("orders.created", nil))
+	_ = dis.Publish(ctx, messaging.NewMessage("orders.eu.updated", nil))
+	_ = dis.Publish(ctx, messaging.NewMessage("users.created", nil))
+
+	// Assert
+	assert.That(t, "topics must be correct", topics, []string{"orders.created", "orders.eu.updated"})
+}
+
+func Test_InternalDispatcher_With_InvalidPattern_Should_ReturnError(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	fn := func(_ messaging.Message) (messaging.MessageState, error) {
+		return messaging.MessageStateCompleted, nil
+	}
+
+	// Act
+	err := dis.Subscribe(ctx, "orders.>.created", service.Wrap(fn))
+
+	// Assert
+	assert.That(t, "err must be correct", err, messaging.ErrInvalidTopicPattern)
+}
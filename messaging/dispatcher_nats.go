@@ -0,0 +1,383 @@
+package messaging
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/env"
+	"github.com/andygeiss/cloud-native-utils/service"
+	"github.com/andygeiss/cloud-native-utils/stability"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+var (
+	ErrUnsupportedTopicPattern = errors.New("unsupported topic pattern")
+)
+
+const (
+	// natsHeaderMsgID is used by JetStream to deduplicate published messages.
+	natsHeaderMsgID = "Nats-Msg-Id"
+)
+
+// NatsDispatcherOptions configures the NATS connection of a NatsDispatcher.
+type NatsDispatcherOptions struct {
+	// URL is a comma-separated list of NATS server URLs. Default: NATS_URL or nats://127.0.0.1:4222.
+	URL string
+
+	// TLS enables encrypted connections, e.g. by using web.TLSClientConfig. Default: none.
+	TLS *tls.Config
+
+	// JetStream enables persistent at-least-once delivery instead of NATS core. Default: false.
+	JetStream bool
+
+	// Stream is the JetStream stream storing the published messages. Default: NATS_STREAM or MESSAGES.
+	Stream string
+
+	// Subjects are the subjects of the stream. If set, the stream is created or
+	// updated on connect, otherwise it must exist. Default: none.
+	Subjects []string
+
+	// Durable is the prefix of the durable JetStream consumers or the queue group of NATS core
+	// subscriptions. Instances sharing it split the messages among them.
+	// Default: NATS_DURABLE, otherwise each subscription receives all messages.
+	Durable string
+
+	// MaxRetries is the number of retries of a failed publish or handler call. Default: SERVICE_RETRY_MAX or 3.
+	MaxRetries int
+
+	// RetryDelay is the delay between two retries and before a redelivery. Default: SERVICE_RETRY_DELAY or 5s.
+	RetryDelay time.Duration
+
+	// Timeout limits the duration of a publish or handler call. Default: SERVICE_TIMEOUT or 5s.
+	Timeout time.Duration
+}
+
+// NatsDispatcher dispatches messages to external services by using NATS.
+// Topics are mapped to subjects, so that wildcard subscriptions like "orders.*"
+// or "orders.>" are handled by the server.
+//
+// With NATS core, messages are delivered at most once. With JetStream, a message
+// is acknowledged only after the handler returned MessageStateCompleted, and is
+// redelivered after the retry delay otherwise.
+type NatsDispatcher struct {
+	options       NatsDispatcherOptions
+	conn          *nats.Conn
+	js            jetstream.JetStream
+	subscriptions []*Subscription
+	closed        bool
+	mutex         sync.Mutex
+}
+
+// NewNatsDispatcher connects to NATS and creates a new NatsDispatcher instance
+// that is configured by environment variables.
+func NewNatsDispatcher(ctx context.Context) (*NatsDispatcher, error) {
+	return NewNatsDispatcherWithOptions(ctx, NatsDispatcherOptions{})
+}
+
+// NewNatsDispatcherWithOptions connects to NATS and creates a new NatsDispatcher instance
+// with the given options. Unset options are read from the environment.
+func NewNatsDispatcherWithOptions(ctx context.Context, options NatsDispatcherOptions) (*NatsDispatcher, error) {
+	options = options.withDefaults()
+
+	// Connect and reconnect forever, since the subscriptions are bound to the connection.
+	opts := []nats.Option{nats.MaxReconnects(-1)}
+	if options.TLS != nil {
+		opts = append(opts, nats.Secure(options.TLS))
+	}
+	conn, err := nats.Connect(options.URL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	a := &NatsDispatcher{conn: conn, options: options}
+	if !options.JetStream {
+		return a, nil
+	}
+
+	// Ensure that the stream exists if subjects are configured.
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if len(options.Subjects) > 0 {
+		_, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+			Name:     options.Stream,
+			Subjects: options.Subjects,
+		})
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	a.js = js
+	return a, nil
+}
+
+// Close stops all subscriptions and closes the connection after flushing pending messages.
+func (a *NatsDispatcher) Close() error {
+	a.mutex.Lock()
+	if a.closed {
+		a.mutex.Unlock()
+		return nil
+	}
+	a.closed = true
+	subs := a.subscriptions
+	a.subscriptions = nil
+	a.mutex.Unlock()
+
+	// Stop the subscriptions first to finish handling their messages.
+	// The lock is not held, since their handlers may publish messages.
+	for _, sub := range subs {
+		sub.Stop()
+	}
+
+	err := a.conn.Flush()
+	a.conn.Close()
+	return err
+}
+
+// Publish publishes a message to the dispatcher.
+func (a *NatsDispatcher) Publish(ctx context.Context, message Message) error {
+	a.mutex.Lock()
+	closed := a.closed
+	a.mutex.Unlock()
+	if closed {
+		return ErrDispatcherClosed
+	}
+
+	// Define a service.Function to publish the message.
+	var fn service.Function[Message, struct{}] = func(ctx context.Context, in Message) (struct{}, error) {
+		msg := toNatsMessage(in)
+		if a.js == nil {
+			return struct{}{}, a.conn.PublishMsg(msg)
+		}
+		_, err := a.js.PublishMsg(ctx, msg)
+		return struct{}{}, err
+	}
+
+	// Use stability patterns to make the function more robust.
+	fn = stability.Retry(fn, a.options.MaxRetries, a.options.RetryDelay)
+	fn = stability.Timeout(fn, a.options.Timeout)
+
+	_, err := fn(ctx, message)
+	return err
+}
+
+// Subscribe adds a function to the list of functions that will be called when a message is published to the given topic.
+// The topic may be a pattern like "orders.*" or "orders.>", but not a regular expression.
+// The subscription runs until the context is canceled or the dispatcher is closed.
+func (a *NatsDispatcher) Subscribe(ctx context.Context, topic string, fn service.Function[Message, MessageState]) error {
+	_, err := a.Listen(ctx, topic, fn)
+	return err
+}
+
+// Listen is like Subscribe, but returns the Subscription to observe and stop it.
+func (a *NatsDispatcher) Listen(ctx context.Context, topic string, fn service.Function[Message, MessageState]) (*Subscription, error) {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	pattern, err := ParseTopicPattern(topic)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(pattern.String(), "^") {
+		return nil, ErrUnsupportedTopicPattern
+	}
+
+	// Use stability patterns to make the function more robust.
+	fn = stability.Retry(fn, a.options.MaxRetries, a.options.RetryDelay)
+	fn = stability.Timeout(fn, a.options.Timeout)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return nil, ErrDispatcherClosed
+	}
+	var sub *Subscription
+	if a.js == nil {
+		sub, err = a.listenCore(ctx, topic, fn)
+	} else {
+		sub, err = a.listenJetStream(ctx, topic, fn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.subscriptions = append(a.subscriptions, sub)
+	go a.forget(sub)
+	return sub, nil
+}
+
+// forget removes the subscription after it has stopped.
+func (a *NatsDispatcher) forget(sub *Subscription) {
+	<-sub.Done()
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.subscriptions = removeSubscription(a.subscriptions, sub)
+}
+
+// listenCore subscribes to the subject by using NATS core.
+func (a *NatsDispatcher) listenCore(ctx context.Context, subject string, fn service.Function[Message, MessageState]) (*Subscription, error) {
+	msgCh := make(chan *nats.Msg, 100)
+	var natsSub *nats.Subscription
+	var err error
+	if a.options.Durable != "" {
+		natsSub, err = a.conn.ChanQueueSubscribe(subject, a.options.Durable, msgCh)
+	} else {
+		natsSub, err = a.conn.ChanSubscribe(subject, msgCh)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newSubscription(ctx, func(ctx context.Context, report func(error)) {
+		defer func() { _ = natsSub.Unsubscribe() }()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-msgCh:
+				state, err := fn(ctx, fromNatsMessage(msg.Subject, msg.Header, msg.Data))
+				if err == nil && state != MessageStateCompleted {
+					err = ErrMessageNotCompleted
+				}
+				report(err)
+			}
+		}
+	}), nil
+}
+
+// listenJetStream subscribes to the subject by using a JetStream consumer.
+func (a *NatsDispatcher) listenJetStream(ctx context.Context, subject string, fn service.Function[Message, MessageState]) (*Subscription, error) {
+	config := jetstream.ConsumerConfig{
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		FilterSubject: subject,
+	}
+	if a.options.Durable != "" {
+		config.Durable = a.options.Durable + "_" + natsConsumerName(subject)
+	}
+	consumer, err := a.js.CreateOrUpdateConsumer(ctx, a.options.Stream, config)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := consumer.Messages()
+	if err != nil {
+		return nil, err
+	}
+	return newSubscription(ctx, func(ctx context.Context, report func(error)) {
+		defer iter.Stop()
+		for {
+			msg, err := iter.Next(jetstream.NextContext(ctx))
+			if err != nil {
+				// Stop if context is canceled or timed out.
+				if ctx.Err() != nil || errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+					return
+				}
+				report(err)
+				continue
+			}
+			state, err := fn(ctx, fromNatsMessage(msg.Subject(), msg.Headers(), msg.Data()))
+			if err == nil && state != MessageStateCompleted {
+				err = ErrMessageNotCompleted
+			}
+			// Acknowledge completed messages and redeliver the others after the retry delay.
+			if err != nil {
+				report(err)
+				report(msg.NakWithDelay(a.options.RetryDelay))
+				continue
+			}
+			report(msg.Ack())
+		}
+	}), nil
+}
+
+// fromNatsMessage transforms a NATS message into a message.
+// The ID, key and timestamp are restored from their headers.
+func fromNatsMessage(subject string, header nats.Header, data []byte) Message {
+	msg := Message{
+		Data:  data,
+		State: MessageStateCreated,
+		Topic: subject,
+	}
+	for key, values := range header {
+		if len(values) == 0 {
+			continue
+		}
+		switch key {
+		case HeaderMessageID:
+			msg.ID = values[0]
+		case HeaderMessageKey:
+			msg.Key = values[0]
+		case HeaderTimestamp:
+			msg.Timestamp, _ = time.Parse(time.RFC3339Nano, values[0])
+		case natsHeaderMsgID:
+		default:
+			if msg.Headers == nil {
+				msg.Headers = make(map[string]string, len(header))
+			}
+			msg.Headers[key] = values[0]
+		}
+	}
+	return msg
+}
+
+// toNatsMessage transforms a message into a NATS message.
+// The ID, key and timestamp are stored as headers.
+func toNatsMessage(message Message) *nats.Msg {
+	msg := nats.NewMsg(message.Topic)
+	msg.Data = message.Data
+	for key, value := range message.Headers {
+		// Set the header directly to keep the case of the key.
+		msg.Header[key] = []string{value}
+	}
+	if message.ID != "" {
+		msg.Header[HeaderMessageID] = []string{message.ID}
+		msg.Header[natsHeaderMsgID] = []string{message.ID}
+	}
+	if message.Key != "" {
+		msg.Header[HeaderMessageKey] = []string{message.Key}
+	}
+	if !message.Timestamp.IsZero() {
+		msg.Header[HeaderTimestamp] = []string{message.Timestamp.UTC().Format(time.RFC3339Nano)}
+	}
+	return msg
+}
+
+// natsConsumerName replaces the characters of a subject which are invalid in consumer names.
+// A hash of the subject is appended, so that subjects like "a.b" and "a_b" don't share a consumer.
+func natsConsumerName(subject string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(subject))
+	name := strings.NewReplacer(".", "_", "*", "any", ">", "all").Replace(subject)
+	return name + "_" + strconv.FormatUint(uint64(h.Sum32()), 16)
+}
+
+// withDefaults replaces unset options with their default values.
+func (a NatsDispatcherOptions) withDefaults() NatsDispatcherOptions {
+	if a.URL == "" {
+		a.URL = env.Get("NATS_URL", nats.DefaultURL)
+	}
+	if a.Stream == "" {
+		a.Stream = env.Get("NATS_STREAM", "MESSAGES")
+	}
+	if a.Durable == "" {
+		a.Durable = os.Getenv("NATS_DURABLE")
+	}
+	if a.MaxRetries <= 0 {
+		a.MaxRetries = env.Get("SERVICE_RETRY_MAX", 3)
+	}
+	if a.RetryDelay <= 0 {
+		a.RetryDelay = env.Get("SERVICE_RETRY_DELAY", 5*time.Second)
+	}
+	if a.Timeout <= 0 {
+		a.Timeout = env.Get("SERVICE_TIMEOUT", 5*time.Second)
+	}
+	return a
+}
@@ -0,0 +1,165 @@
+package messaging_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+	"github.com/andygeiss/cloud-native-utils/service"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// startNatsServer starts an embedded NATS server with JetStream enabled.
+func startNatsServer(t *testing.T) string {
+	t.Helper()
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		NoLog:     true,
+		NoSigs:    true,
+		StoreDir:  t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	t.Cleanup(ns.Shutdown)
+	return ns.ClientURL()
+}
+
+func Test_NatsDispatcher_With_Roundtrip_Should_CallHandler(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis, err := messaging.NewNatsDispatcherWithOptions(ctx, messaging.NatsDispatcherOptions{URL: startNatsServer(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = dis.Close() }()
+	received := make(chan messaging.Message, 1)
+	fn := func(m messaging.Message) (messaging.MessageState, error) {
+		received <- m
+		return messaging.MessageStateCompleted, nil
+	}
+	msg := messaging.NewMessage("orders.created", []byte("order-1")).
+		WithKey("user-1").
+		WithHeader(messaging.HeaderContentType, "text/plain")
+
+	// Act
+	_ = dis.Subscribe(ctx, "orders.*", service.Wrap(fn))
+	err = dis.Publish(ctx, msg)
+	got := <-received
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "message must be correct", got, msg)
+}
+
+func Test_NatsDispatcher_With_RegexpTopic_Should_ReturnErrUnsupportedTopicPattern(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis, _ := messaging.NewNatsDispatcherWithOptions(ctx, messaging.NatsDispatcherOptions{URL: startNatsServer(t)})
+	defer func() { _ = dis.Close() }()
+	fn := func(_ messaging.Message) (messaging.MessageState, error) {
+		return messaging.MessageStateCompleted, nil
+	}
+
+	// Act
+	err := dis.Subscribe(ctx, `^orders\..*$`, service.Wrap(fn))
+
+	// Assert
+	assert.That(t, "err must be correct", err, messaging.ErrUnsupportedTopicPattern)
+}
+
+func Test_NatsDispatcher_With_JetStream_Should_RedeliverFailedMessage(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis, err := messaging.NewNatsDispatcherWithOptions(ctx, messaging.NatsDispatcherOptions{
+		URL:        startNatsServer(t),
+		JetStream:  true,
+		Stream:     "ORDERS",
+		Subjects:   []string{"orders.>"},
+		Durable:    "billing",
+		RetryDelay: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = dis.Close() }()
+	calls := make(chan int, 2)
+	count := 0
+	fn := func(_ messaging.Message) (messaging.MessageState, error) {
+		count++
+		calls <- count
+		if count == 1 {
+			return messaging.MessageStateFailed, nil
+		}
+		return messaging.MessageStateCompleted, nil
+	}
+
+	// Act
+	err = dis.Publish(ctx, messaging.NewMessage("orders.created", []byte("order-1")))
+	_ = dis.Subscribe(ctx, "orders.>", service.Wrap(fn))
+	<-calls
+	second := <-calls
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "second call must be 2", second, 2)
+}
+
+func Test_NatsDispatcher_With_PublishAfterClose_Should_ReturnErrDispatcherClosed(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis, _ := messaging.NewNatsDispatcherWithOptions(ctx, messaging.NatsDispatcherOptions{URL: startNatsServer(t)})
+	_ = dis.Close()
+
+	// Act
+	err := dis.Publish(ctx, messaging.NewMessage("orders.created", nil))
+
+	// Assert
+	assert.That(t, "err must be correct", err, messaging.ErrDispatcherClosed)
+}
+
+func Test_NatsDispatcher_With_PublishingHandlerDuringClose_Should_NotDeadlock(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis, err := messaging.NewNatsDispatcherWithOptions(ctx, messaging.NatsDispatcherOptions{URL: startNatsServer(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fn := func(_ messaging.Message) (messaging.MessageState, error) {
+		close(started)
+		<-release
+		_ = dis.Publish(ctx, messaging.NewMessage("orders.replied", nil))
+		return messaging.MessageStateCompleted, nil
+	}
+	_ = dis.Subscribe(ctx, "orders.created", service.Wrap(fn))
+	_ = dis.Publish(ctx, messaging.NewMessage("orders.created", nil))
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler must be called")
+	}
+
+	// Act
+	closed := make(chan error, 1)
+	go func() { closed <- dis.Close() }()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	// Assert
+	select {
+	case err := <-closed:
+		assert.That(t, "err must be nil", err, nil)
+	case <-time.After(5 * time.Second):
+		t.Fatal("close must not deadlock")
+	}
+}
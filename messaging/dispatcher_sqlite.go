@@ -0,0 +1,373 @@
+package messaging
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/service"
+)
+
+var (
+	ErrLeaseExpired = errors.New("lease expired")
+)
+
+// SqliteDispatcherOptions configures a SqliteDispatcher.
+type SqliteDispatcherOptions struct {
+	// Group is the consumer group of subscriptions. Subscriptions of the same group
+	// and topic compete for messages, while each group receives every message. Default: default.
+	Group string
+
+	// PollInterval is the delay between two polls of an empty queue. Default: 100ms.
+	PollInterval time.Duration
+
+	// VisibilityTimeout is the duration a received message is hidden from other consumers.
+	// If it is neither completed nor failed in time, e.g. due to a crash, it is redelivered. Default: 30s.
+	VisibilityTimeout time.Duration
+
+	// MaxDeliveries is the number of deliveries before a failed message is kept as failed. Default: 5.
+	MaxDeliveries int
+
+	// RetryDelay is the delay before a failed message is redelivered. Default: 1s.
+	RetryDelay time.Duration
+
+	// Retention is the duration completed and failed messages are kept.
+	// If it is zero, completed messages are deleted immediately and failed
+	// messages are kept until they are deleted manually. Default: 0.
+	Retention time.Duration
+
+	// MaxAge is the duration after which undelivered messages are deleted.
+	// Messages are kept until they are delivered if it is zero. Default: 0.
+	MaxAge time.Duration
+}
+
+// SqliteDispatcher is a durable message queue backed by SQLite.
+// Subscriptions are stored per consumer group, so that messages published while
+// a group is offline are delivered after it subscribed again.
+//
+// A message is received by one subscription of each group at a time and is
+// completed if the handler returned MessageStateCompleted. Otherwise, it is
+// redelivered after the retry delay until MaxDeliveries is reached.
+// The result of a handler is only stored while its lease is held, i.e. if the
+// message has not been redelivered after the visibility timeout meanwhile.
+type SqliteDispatcher struct {
+	db            *sql.DB
+	options       SqliteDispatcherOptions
+	subscriptions []*Subscription
+	closed        bool
+	mutex         sync.Mutex // Mutex to serialize writers of this process.
+}
+
+// NewSqliteDispatcher creates a new SqliteDispatcher instance.
+func NewSqliteDispatcher(db *sql.DB) *SqliteDispatcher {
+	return NewSqliteDispatcherWithOptions(db, SqliteDispatcherOptions{})
+}
+
+// NewSqliteDispatcherWithOptions creates a new SqliteDispatcher instance with the given options.
+func NewSqliteDispatcherWithOptions(db *sql.DB, options SqliteDispatcherOptions) *SqliteDispatcher {
+	return &SqliteDispatcher{
+		db:      db,
+		options: options.withDefaults(),
+	}
+}
+
+// Close stops all subscriptions. The database connection is owned by the caller and stays open.
+func (a *SqliteDispatcher) Close() error {
+	a.mutex.Lock()
+	if a.closed {
+		a.mutex.Unlock()
+		return nil
+	}
+	a.closed = true
+	subs := a.subscriptions
+	a.subscriptions = nil
+	a.mutex.Unlock()
+
+	// Stop the subscriptions without holding the lock, since they finish their messages.
+	for _, sub := range subs {
+		sub.Stop()
+	}
+	return nil
+}
+
+// Init initializes the tables. Pending messages and subscriptions are kept.
+func (a *SqliteDispatcher) Init(ctx context.Context) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := a.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS message_subscriptions (group_name TEXT NOT NULL, pattern TEXT NOT NULL, PRIMARY KEY (group_name, pattern));
+		CREATE TABLE IF NOT EXISTS message_queue (id INTEGER PRIMARY KEY AUTOINCREMENT, group_name TEXT NOT NULL, pattern TEXT NOT NULL, message TEXT NOT NULL, state INTEGER NOT NULL DEFAULT 0, deliveries INTEGER NOT NULL DEFAULT 0, visible_at INTEGER NOT NULL, created_at INTEGER NOT NULL, updated_at INTEGER NOT NULL);
+		CREATE INDEX IF NOT EXISTS idx_message_queue_receive ON message_queue (group_name, pattern, state, visible_at);
+	`)
+	return err
+}
+
+// Publish stores the message for each subscribed group with a matching topic pattern.
+// Messages without subscribed groups are dropped.
+func (a *SqliteDispatcher) Publish(ctx context.Context, message Message) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return ErrDispatcherClosed
+	}
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := a.publishTx(ctx, tx, message); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PublishTx stores the message as part of the given transaction.
+// The message is only delivered if the transaction is committed, which allows
+// callers to publish messages atomically with further changes.
+func (a *SqliteDispatcher) PublishTx(ctx context.Context, tx *sql.Tx, message Message) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.publishTx(ctx, tx, message)
+}
+
+// Subscribe adds a function to the list of functions that will be called when a message is published to the given topic.
+// The topic may be a pattern like "orders.*" or "orders.>" to match multiple topics.
+// The subscription of the group is stored, and runs until the context is canceled or the dispatcher is closed.
+func (a *SqliteDispatcher) Subscribe(ctx context.Context, topic string, fn service.Function[Message, MessageState]) error {
+	_, err := a.Listen(ctx, topic, fn)
+	return err
+}
+
+// Listen is like Subscribe, but returns the Subscription to observe and stop it.
+func (a *SqliteDispatcher) Listen(ctx context.Context, topic string, fn service.Function[Message, MessageState]) (*Subscription, error) {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := ParseTopicPattern(topic); err != nil {
+		return nil, err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return nil, ErrDispatcherClosed
+	}
+
+	// Store the subscription to receive messages published while the group is offline.
+	_, err := a.db.ExecContext(ctx, "INSERT OR IGNORE INTO message_subscriptions (group_name, pattern) VALUES (?, ?)", a.options.Group, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := newSubscription(ctx, func(ctx context.Context, report func(error)) {
+		a.poll(ctx, topic, fn, report)
+	})
+	a.subscriptions = append(a.subscriptions, sub)
+	go a.forget(sub)
+	return sub, nil
+}
+
+// forget removes the subscription after it has stopped.
+func (a *SqliteDispatcher) forget(sub *Subscription) {
+	<-sub.Done()
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.subscriptions = removeSubscription(a.subscriptions, sub)
+}
+
+// poll receives and handles the messages of the pattern until the context is done.
+func (a *SqliteDispatcher) poll(ctx context.Context, pattern string, fn service.Function[Message, MessageState], report func(error)) {
+	for {
+		lease, message, err := a.receive(ctx, pattern)
+		if err == nil {
+			report(a.handle(ctx, lease, message, fn))
+			continue
+		}
+
+		// Stop if context is canceled or timed out.
+		if ctx.Err() != nil {
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			report(err)
+		}
+
+		// Apply the retention policies while the queue is empty.
+		report(a.cleanup(ctx))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(a.options.PollInterval):
+		}
+	}
+}
+
+// sqliteLease identifies a received message until it is redelivered.
+// Each delivery increments the deliveries and sets a new visibility time.
+type sqliteLease struct {
+	id         int64
+	deliveries int
+	visibleAt  int64
+}
+
+// receive hides the oldest visible message of the pattern from other consumers and returns it.
+// It returns sql.ErrNoRows if there is no visible message. A message that cannot be decoded
+// is failed, since it would be redelivered forever otherwise.
+func (a *SqliteDispatcher) receive(ctx context.Context, pattern string) (sqliteLease, Message, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := time.Now()
+	lease := sqliteLease{visibleAt: now.Add(a.options.VisibilityTimeout).UnixNano()}
+	var encoded string
+	err := a.db.QueryRowContext(ctx, `
+		UPDATE message_queue SET visible_at = ?, deliveries = deliveries + 1, updated_at = ?
+		WHERE id = (SELECT id FROM message_queue WHERE group_name = ? AND pattern = ? AND state = ? AND visible_at <= ? ORDER BY id LIMIT 1)
+		RETURNING id, message, deliveries`,
+		lease.visibleAt, now.UnixNano(), a.options.Group, pattern, MessageStateCreated, now.UnixNano(),
+	).Scan(&lease.id, &encoded, &lease.deliveries)
+	if err != nil {
+		return sqliteLease{}, Message{}, err
+	}
+
+	var message Message
+	if err := json.Unmarshal([]byte(encoded), &message); err != nil {
+		_, failErr := a.db.ExecContext(ctx, "UPDATE message_queue SET state = ?, updated_at = ? WHERE id = ? AND deliveries = ? AND visible_at = ?", MessageStateFailed, now.UnixNano(), lease.id, lease.deliveries, lease.visibleAt)
+		return sqliteLease{}, Message{}, errors.Join(err, failErr)
+	}
+	return lease, message, nil
+}
+
+// handle calls the function and completes, fails or redelivers the message depending on the result.
+// It returns ErrLeaseExpired if the message has been redelivered meanwhile.
+func (a *SqliteDispatcher) handle(ctx context.Context, lease sqliteLease, message Message, fn service.Function[Message, MessageState]) error {
+	state, fnErr := fn(ctx, message)
+	if fnErr == nil && state != MessageStateCompleted {
+		fnErr = ErrMessageNotCompleted
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	// Use a context without cancellation to store the result of a handled message.
+	// Each statement only matches the message while the lease is held.
+	ctx = context.WithoutCancel(ctx)
+	now := time.Now().UnixNano()
+	var result sql.Result
+	var err error
+	switch {
+	case fnErr == nil && a.options.Retention == 0:
+		result, err = a.db.ExecContext(ctx, "DELETE FROM message_queue WHERE id = ? AND deliveries = ? AND visible_at = ?", lease.id, lease.deliveries, lease.visibleAt)
+	case fnErr == nil:
+		result, err = a.db.ExecContext(ctx, "UPDATE message_queue SET state = ?, updated_at = ? WHERE id = ? AND deliveries = ? AND visible_at = ?", MessageStateCompleted, now, lease.id, lease.deliveries, lease.visibleAt)
+	case lease.deliveries >= a.options.MaxDeliveries:
+		result, err = a.db.ExecContext(ctx, "UPDATE message_queue SET state = ?, updated_at = ? WHERE id = ? AND deliveries = ? AND visible_at = ?", MessageStateFailed, now, lease.id, lease.deliveries, lease.visibleAt)
+	default:
+		result, err = a.db.ExecContext(ctx, "UPDATE message_queue SET visible_at = ?, updated_at = ? WHERE id = ? AND deliveries = ? AND visible_at = ?", time.Now().Add(a.options.RetryDelay).UnixNano(), now, lease.id, lease.deliveries, lease.visibleAt)
+	}
+	if err != nil {
+		return errors.Join(fnErr, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.Join(fnErr, err)
+	}
+	if affected == 0 {
+		return errors.Join(fnErr, ErrLeaseExpired)
+	}
+	return fnErr
+}
+
+// cleanup deletes messages according to the retention policies.
+func (a *SqliteDispatcher) cleanup(ctx context.Context) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := time.Now()
+	if a.options.Retention > 0 {
+		_, err := a.db.ExecContext(ctx, "DELETE FROM message_queue WHERE state != ? AND updated_at < ?", MessageStateCreated, now.Add(-a.options.Retention).UnixNano())
+		if err != nil {
+			return err
+		}
+	}
+	if a.options.MaxAge == 0 {
+		return nil
+	}
+	_, err := a.db.ExecContext(ctx, "DELETE FROM message_queue WHERE state = ? AND created_at < ?", MessageStateCreated, now.Add(-a.options.MaxAge).UnixNano())
+	return err
+}
+
+// publishTx stores the message for each subscribed group with a matching pattern.
+func (a *SqliteDispatcher) publishTx(ctx context.Context, tx *sql.Tx, message Message) error {
+	// Encode the message as JSON.
+	encoded, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	// Read the subscriptions before inserting to release the rows.
+	rows, err := tx.QueryContext(ctx, "SELECT group_name, pattern FROM message_subscriptions")
+	if err != nil {
+		return err
+	}
+	type subscription struct{ group, pattern string }
+	var matches []subscription
+	for rows.Next() {
+		var s subscription
+		if err := rows.Scan(&s.group, &s.pattern); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		if p, err := ParseTopicPattern(s.pattern); err == nil && p.Match(message.Topic) {
+			matches = append(matches, s)
+		}
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now().UnixNano()
+	for _, s := range matches {
+		_, err := tx.ExecContext(ctx, "INSERT INTO message_queue (group_name, pattern, message, visible_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)", s.group, s.pattern, string(encoded), now, now, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withDefaults replaces unset options with their default values.
+func (a SqliteDispatcherOptions) withDefaults() SqliteDispatcherOptions {
+	if a.Group == "" {
+		a.Group = "default"
+	}
+	if a.PollInterval <= 0 {
+		a.PollInterval = 100 * time.Millisecond
+	}
+	if a.VisibilityTimeout <= 0 {
+		a.VisibilityTimeout = 30 * time.Second
+	}
+	if a.MaxDeliveries <= 0 {
+		a.MaxDeliveries = 5
+	}
+	if a.RetryDelay <= 0 {
+		a.RetryDelay = time.Second
+	}
+	return a
+}
@@ -0,0 +1,363 @@
+package messaging_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+	"github.com/andygeiss/cloud-native-utils/service"
+	_ "modernc.org/sqlite"
+)
+
+func newSqliteDispatcher(t *testing.T, options messaging.SqliteDispatcherOptions) (*messaging.SqliteDispatcher, *sql.DB) {
+	t.Helper()
+	db := openSqlite(t, "queue.sqlite")
+	options.PollInterval = 10 * time.Millisecond
+	dis := messaging.NewSqliteDispatcherWithOptions(db, options)
+	t.Cleanup(func() { _ = dis.Close() })
+	if err := dis.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return dis, db
+}
+
+func countQueuedMessages(db *sql.DB, state messaging.MessageState) int {
+	var count int
+	_ = db.QueryRow("SELECT COUNT(*) FROM message_queue WHERE state = ?", state).Scan(&count)
+	return count
+}
+
+func Test_SqliteDispatcher_With_Subscription_Should_ReceiveMessage(t *testing.T) {
+	// Arrange
+	dis, db := newSqliteDispatcher(t, messaging.SqliteDispatcherOptions{})
+	ctx := context.Background()
+	received := make(chan messaging.Message, 1)
+	_ = dis.Subscribe(ctx, "orders.*", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		received <- msg
+		return messaging.MessageStateCompleted, nil
+	}))
+	message := messaging.NewMessage("orders.created", []byte("order-1")).WithKey("order-1")
+
+	// Act
+	err := dis.Publish(ctx, message)
+	msg := <-received
+	_ = dis.Close()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "msg id must be correct", msg.ID, message.ID)
+	assert.That(t, "msg key must be correct", msg.Key, "order-1")
+	assert.That(t, "msg data must be correct", string(msg.Data), "order-1")
+	assert.That(t, "queue must be empty", countQueuedMessages(db, messaging.MessageStateCreated), 0)
+}
+
+func Test_SqliteDispatcher_With_OfflineGroup_Should_DeliverStoredMessage(t *testing.T) {
+	// Arrange
+	dis, db := newSqliteDispatcher(t, messaging.SqliteDispatcherOptions{})
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, _ := dis.Listen(ctx, "orders.created", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		return messaging.MessageStateCompleted, nil
+	}))
+	cancel()
+	<-sub.Done()
+	_ = dis.Publish(context.Background(), messaging.NewMessage("orders.created", []byte("order-1")))
+	queued := countQueuedMessages(db, messaging.MessageStateCreated)
+	received := make(chan string, 1)
+
+	// Act
+	restarted := messaging.NewSqliteDispatcherWithOptions(db, messaging.SqliteDispatcherOptions{PollInterval: 10 * time.Millisecond})
+	defer func() { _ = restarted.Close() }()
+	err := restarted.Subscribe(context.Background(), "orders.created", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		received <- string(msg.Data)
+		return messaging.MessageStateCompleted, nil
+	}))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "queued must be 1", queued, 1)
+	assert.That(t, "received must be correct", <-received, "order-1")
+}
+
+func Test_SqliteDispatcher_With_CompetingConsumers_Should_DeliverOnce(t *testing.T) {
+	// Arrange
+	dis, _ := newSqliteDispatcher(t, messaging.SqliteDispatcherOptions{})
+	ctx := context.Background()
+	var mutex sync.Mutex
+	counts := make(map[string]int)
+	var wg sync.WaitGroup
+	wg.Add(20)
+	fn := service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		mutex.Lock()
+		counts[string(msg.Data)]++
+		mutex.Unlock()
+		wg.Done()
+		return messaging.MessageStateCompleted, nil
+	})
+	_ = dis.Subscribe(ctx, "jobs", fn)
+	_ = dis.Subscribe(ctx, "jobs", fn)
+
+	// Act
+	for i := range 20 {
+		_ = dis.Publish(ctx, messaging.NewMessage("jobs", []byte{byte('a' + i)}))
+	}
+	wg.Wait()
+	_ = dis.Close()
+
+	// Assert
+	assert.That(t, "counts must have 20 entries", len(counts), 20)
+	for data, count := range counts {
+		assert.That(t, "count of "+data+" must be 1", count, 1)
+	}
+}
+
+func Test_SqliteDispatcher_With_Groups_Should_DeliverToEachGroup(t *testing.T) {
+	// Arrange
+	billing, db := newSqliteDispatcher(t, messaging.SqliteDispatcherOptions{Group: "billing"})
+	shipping := messaging.NewSqliteDispatcherWithOptions(db, messaging.SqliteDispatcherOptions{Group: "shipping", PollInterval: 10 * time.Millisecond})
+	defer func() { _ = shipping.Close() }()
+	ctx := context.Background()
+	received := make(chan string, 2)
+	_ = billing.Subscribe(ctx, "orders.>", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		received <- "billing"
+		return messaging.MessageStateCompleted, nil
+	}))
+	_ = shipping.Subscribe(ctx, "orders.created", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		received <- "shipping"
+		return messaging.MessageStateCompleted, nil
+	}))
+
+	// Act
+	err := billing.Publish(ctx, messaging.NewMessage("orders.created", []byte("order-1")))
+	groups := map[string]bool{<-received: true, <-received: true}
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "groups must be correct", groups, map[string]bool{"billing": true, "shipping": true})
+}
+
+func Test_SqliteDispatcher_With_FailingHandler_Should_RedeliverMessage(t *testing.T) {
+	// Arrange
+	dis, db := newSqliteDispatcher(t, messaging.SqliteDispatcherOptions{RetryDelay: 10 * time.Millisecond, Retention: time.Hour})
+	ctx := context.Background()
+	completed := make(chan int, 1)
+	var deliveries int
+	sub, _ := dis.Listen(ctx, "jobs", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		deliveries++
+		if deliveries < 3 {
+			return messaging.MessageStateFailed, nil
+		}
+		completed <- deliveries
+		return messaging.MessageStateCompleted, nil
+	}))
+
+	// Act
+	_ = dis.Publish(ctx, messaging.NewMessage("jobs", []byte("job-1")))
+	count := <-completed
+	_ = dis.Close()
+	var errs []error
+	for err := range sub.Errors() {
+		errs = append(errs, err)
+	}
+
+	// Assert
+	assert.That(t, "count must be 3", count, 3)
+	assert.That(t, "errs must have 2 entries", len(errs), 2)
+	assert.That(t, "err must be ErrMessageNotCompleted", errors.Is(errs[0], messaging.ErrMessageNotCompleted), true)
+	assert.That(t, "completed message must be retained", countQueuedMessages(db, messaging.MessageStateCompleted), 1)
+}
+
+func Test_SqliteDispatcher_With_MaxDeliveries_Should_KeepFailedMessage(t *testing.T) {
+	// Arrange
+	dis, db := newSqliteDispatcher(t, messaging.SqliteDispatcherOptions{MaxDeliveries: 2, RetryDelay: 10 * time.Millisecond, Retention: time.Hour})
+	ctx := context.Background()
+	failures := make(chan struct{}, 2)
+	_ = dis.Subscribe(ctx, "jobs", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		failures <- struct{}{}
+		return messaging.MessageStateFailed, errors.New("error")
+	}))
+
+	// Act
+	_ = dis.Publish(ctx, messaging.NewMessage("jobs", []byte("job-1")))
+	<-failures
+	<-failures
+	_ = dis.Close()
+
+	// Assert
+	assert.That(t, "pending messages must be 0", countQueuedMessages(db, messaging.MessageStateCreated), 0)
+	assert.That(t, "failed messages must be 1", countQueuedMessages(db, messaging.MessageStateFailed), 1)
+}
+
+func Test_SqliteDispatcher_With_UndecodableMessage_Should_FailMessage(t *testing.T) {
+	// Arrange
+	dis, db := newSqliteDispatcher(t, messaging.SqliteDispatcherOptions{VisibilityTimeout: 10 * time.Millisecond})
+	ctx := context.Background()
+	sub, _ := dis.Listen(ctx, "jobs", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		return messaging.MessageStateCompleted, nil
+	}))
+	now := time.Now().UnixNano()
+	if _, err := db.Exec("INSERT INTO message_queue (group_name, pattern, message, visible_at, created_at, updated_at) VALUES ('default', 'jobs', '{', ?, ?, ?)", now, now, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	err := <-sub.Errors()
+	time.Sleep(50 * time.Millisecond) // Several polls after the visibility timeout.
+	sub.Stop()
+
+	// Assert
+	assert.That(t, "err must not be nil", err != nil, true)
+	assert.That(t, "pending messages must be 0", countQueuedMessages(db, messaging.MessageStateCreated), 0)
+	assert.That(t, "failed messages must be 1", countQueuedMessages(db, messaging.MessageStateFailed), 1)
+}
+
+func Test_SqliteDispatcher_With_ZeroRetention_Should_KeepFailedMessage(t *testing.T) {
+	// Arrange
+	dis, db := newSqliteDispatcher(t, messaging.SqliteDispatcherOptions{MaxDeliveries: 1})
+	ctx := context.Background()
+	failures := make(chan struct{}, 1)
+	_ = dis.Subscribe(ctx, "jobs", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		failures <- struct{}{}
+		return messaging.MessageStateFailed, errors.New("error")
+	}))
+
+	// Act
+	_ = dis.Publish(ctx, messaging.NewMessage("jobs", []byte("job-1")))
+	<-failures
+	time.Sleep(50 * time.Millisecond) // Several polls of the empty queue run the cleanup.
+	_ = dis.Close()
+
+	// Assert
+	assert.That(t, "failed messages must be 1", countQueuedMessages(db, messaging.MessageStateFailed), 1)
+}
+
+func Test_SqliteDispatcher_With_ExpiredLease_Should_NotOverwriteRedelivery(t *testing.T) {
+	// Arrange
+	dis, db := newSqliteDispatcher(t, messaging.SqliteDispatcherOptions{MaxDeliveries: 2, VisibilityTimeout: 50 * time.Millisecond, Retention: time.Hour})
+	ctx := context.Background()
+	var mutex sync.Mutex
+	calls := 0
+	release := make(chan struct{})
+	failed := make(chan struct{}, 1)
+	fn := service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		mutex.Lock()
+		calls++
+		call := calls
+		mutex.Unlock()
+		if call == 1 {
+			<-release
+			return messaging.MessageStateCompleted, nil
+		}
+		failed <- struct{}{}
+		return messaging.MessageStateFailed, errors.New("error")
+	})
+	first, _ := dis.Listen(ctx, "jobs", fn)
+	second, _ := dis.Listen(ctx, "jobs", fn)
+
+	// Act
+	_ = dis.Publish(ctx, messaging.NewMessage("jobs", []byte("job-1")))
+	select {
+	case <-failed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("message must be redelivered")
+	}
+	close(release)
+	_ = dis.Close()
+	var errs []error
+	for err := range first.Errors() {
+		errs = append(errs, err)
+	}
+	for err := range second.Errors() {
+		errs = append(errs, err)
+	}
+
+	// Assert
+	assert.That(t, "lease must be expired", slices.ContainsFunc(errs, func(err error) bool { return errors.Is(err, messaging.ErrLeaseExpired) }), true)
+	assert.That(t, "failed messages must be 1", countQueuedMessages(db, messaging.MessageStateFailed), 1)
+	assert.That(t, "completed messages must be 0", countQueuedMessages(db, messaging.MessageStateCompleted), 0)
+}
+
+func Test_SqliteDispatcher_With_VisibilityTimeout_Should_RedeliverUnfinishedMessage(t *testing.T) {
+	// Arrange
+	dis, _ := newSqliteDispatcher(t, messaging.SqliteDispatcherOptions{VisibilityTimeout: 50 * time.Millisecond})
+	ctx := context.Background()
+	release := make(chan struct{})
+	received := make(chan string, 2)
+	fn := service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		received <- string(msg.Data)
+		<-release
+		return messaging.MessageStateCompleted, nil
+	})
+	_ = dis.Subscribe(ctx, "jobs", fn)
+	_ = dis.Subscribe(ctx, "jobs", fn)
+
+	// Act
+	_ = dis.Publish(ctx, messaging.NewMessage("jobs", []byte("job-1")))
+	first := <-received
+	second := <-received
+	close(release)
+
+	// Assert
+	assert.That(t, "first must be correct", first, "job-1")
+	assert.That(t, "second must be correct", second, "job-1")
+}
+
+func Test_SqliteDispatcher_With_MaxAge_Should_DeleteExpiredMessages(t *testing.T) {
+	// Arrange
+	dis, db := newSqliteDispatcher(t, messaging.SqliteDispatcherOptions{MaxAge: time.Millisecond, VisibilityTimeout: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub, _ := dis.Listen(ctx, "jobs", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		return messaging.MessageStateCompleted, nil
+	}))
+	cancel()
+	<-sub.Done()
+	_ = dis.Publish(context.Background(), messaging.NewMessage("jobs", []byte("job-1")))
+	time.Sleep(10 * time.Millisecond)
+
+	// Act
+	_ = dis.Subscribe(context.Background(), "other", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		return messaging.MessageStateCompleted, nil
+	}))
+	time.Sleep(50 * time.Millisecond)
+
+	// Assert
+	assert.That(t, "pending messages must be 0", countQueuedMessages(db, messaging.MessageStateCreated), 0)
+}
+
+func Test_SqliteDispatcher_With_RolledBackTransaction_Should_NotDeliverMessage(t *testing.T) {
+	// Arrange
+	dis, db := newSqliteDispatcher(t, messaging.SqliteDispatcherOptions{})
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, _ := dis.Listen(ctx, "jobs", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		return messaging.MessageStateCompleted, nil
+	}))
+	cancel()
+	<-sub.Done()
+
+	// Act
+	tx, _ := db.BeginTx(context.Background(), nil)
+	err := dis.PublishTx(context.Background(), tx, messaging.NewMessage("jobs", []byte("job-1")))
+	_ = tx.Rollback()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "pending messages must be 0", countQueuedMessages(db, messaging.MessageStateCreated), 0)
+}
+
+func Test_SqliteDispatcher_With_Closed_Should_ReturnErrDispatcherClosed(t *testing.T) {
+	// Arrange
+	dis, _ := newSqliteDispatcher(t, messaging.SqliteDispatcherOptions{})
+	_ = dis.Close()
+
+	// Act
+	err := dis.Publish(context.Background(), messaging.NewMessage("jobs", nil))
+
+	// Assert
+	assert.That(t, "err must be ErrDispatcherClosed", err, messaging.ErrDispatcherClosed)
+}
@@ -2,6 +2,7 @@ package messaging_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/andygeiss/cloud-native-utils/assert"
 	"github.com/andygeiss/cloud-native-utils/messaging"
@@ -65,3 +66,28 @@ func Test_NewMessage_With_ValidInput_Should_SetCreatedState(t *testing.T) {
 	// Assert
 	assert.That(t, "message state must be created", msg.State, messaging.MessageStateCreated)
 }
+
+func Test_NewMessage_With_Topic_Should_HaveIDAndTimestamp(t *testing.T) {
+	// Arrange
+	before := time.Now()
+
+	// Act
+	msg := messaging.NewMessage("my topic", []byte("test"))
+
+	// Assert
+	assert.That(t, "id must have 64 characters", len(msg.ID), 64)
+	assert.That(t, "timestamp must be current", msg.Timestamp.Before(before), false)
+}
+
+func Test_Message_With_Header_Should_NotModifyOriginal(t *testing.T) {
+	// Arrange
+	msg := messaging.NewMessage("my topic", []byte("test")).WithHeader("a", "1")
+
+	// Act
+	copied := msg.WithHeader("b", "2").WithKey("my key")
+
+	// Assert
+	assert.That(t, "original headers must be correct", msg.Headers, map[string]string{"a": "1"})
+	assert.That(t, "copied headers must be correct", copied.Headers, map[string]string{"a": "1", "b": "2"})
+	assert.That(t, "key must be correct", copied.Key, "my key")
+}
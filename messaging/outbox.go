@@ -0,0 +1,251 @@
+package messaging
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/service"
+	"github.com/andygeiss/cloud-native-utils/stability"
+)
+
+// OutboxOptions configures how an Outbox relays pending messages.
+type OutboxOptions struct {
+	// BatchSize limits the number of messages read per poll. Default: 100.
+	BatchSize int
+
+	// PollInterval is the delay between two polls of the outbox table. Default: 1s.
+	PollInterval time.Duration
+
+	// MaxRetries is the number of immediate retries of a failed publish.
+	// A negative value disables immediate retries. Default: 3.
+	MaxRetries int
+
+	// RetryDelay is the delay between two immediate retries. Default: 100ms.
+	RetryDelay time.Duration
+
+	// MaxAttempts is the number of failed relays after which a message is kept
+	// in the outbox as a dead letter and no longer relayed. Default: 10.
+	MaxAttempts int
+}
+
+// outboxQueries contains the dialect specific statements of an Outbox.
+type outboxQueries struct {
+	create    string
+	enqueue   string
+	pending   string
+	published string
+	failed    string
+	dead      string
+}
+
+// Outbox implements the transactional outbox pattern.
+// Messages are written to the outbox table within the same SQL transaction
+// as the corresponding resource change, and are published afterwards by a relay.
+// This avoids losing messages if the process crashes between committing the
+// change and publishing the message.
+//
+// The relay delivers messages at least once and preserves their order per key.
+// Run a single relay per outbox table, since concurrent relays may publish
+// messages of the same key out of order.
+//
+// Messages that failed MaxAttempts times or cannot be decoded are kept in the
+// outbox table as dead letters with attempts >= MaxAttempts. They are no longer
+// relayed, so that the following messages of their key are relayed again.
+type Outbox struct {
+	db      *sql.DB
+	queries outboxQueries
+}
+
+// NewSqliteOutbox creates a new Outbox for a SQLite database.
+func NewSqliteOutbox(db *sql.DB) *Outbox {
+	return &Outbox{
+		db: db,
+		queries: outboxQueries{
+			create:    "CREATE TABLE IF NOT EXISTS outbox (id INTEGER PRIMARY KEY AUTOINCREMENT, message_key TEXT NOT NULL, message TEXT NOT NULL, attempts INTEGER NOT NULL DEFAULT 0, created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP);",
+			enqueue:   "INSERT INTO outbox (message_key, message) VALUES (?, ?)",
+			pending:   "SELECT id, message_key, message FROM outbox WHERE id > ? AND attempts < ? ORDER BY id LIMIT ?",
+			published: "DELETE FROM outbox WHERE id = ?",
+			failed:    "UPDATE outbox SET attempts = attempts + 1 WHERE id = ?",
+			dead:      "UPDATE outbox SET attempts = ? WHERE id = ?",
+		},
+	}
+}
+
+// NewPostgresOutbox creates a new Outbox for a PostgreSQL database.
+func NewPostgresOutbox(db *sql.DB) *Outbox {
+	return &Outbox{
+		db: db,
+		queries: outboxQueries{
+			create:    "CREATE TABLE IF NOT EXISTS outbox (id BIGSERIAL PRIMARY KEY, message_key TEXT NOT NULL, message TEXT NOT NULL, attempts INTEGER NOT NULL DEFAULT 0, created_at TIMESTAMPTZ NOT NULL DEFAULT now());",
+			enqueue:   "INSERT INTO outbox (message_key, message) VALUES ($1, $2)",
+			pending:   "SELECT id, message_key, message FROM outbox WHERE id > $1 AND attempts < $2 ORDER BY id LIMIT $3",
+			published: "DELETE FROM outbox WHERE id = $1",
+			failed:    "UPDATE outbox SET attempts = attempts + 1 WHERE id = $1",
+			dead:      "UPDATE outbox SET attempts = $1 WHERE id = $2",
+		},
+	}
+}
+
+// Init initializes the outbox table. Pending messages are kept.
+func (a *Outbox) Init(ctx context.Context) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := a.db.ExecContext(ctx, a.queries.create)
+	return err
+}
+
+// Enqueue writes the message to the outbox as part of the given transaction.
+// The message is only relayed if the transaction is committed.
+// Messages with the same key are published in the order they were enqueued.
+func (a *Outbox) Enqueue(ctx context.Context, tx *sql.Tx, key string, message Message) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Encode the message as JSON.
+	encoded, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, a.queries.enqueue, key, string(encoded))
+	return err
+}
+
+// Relay publishes pending messages to the dispatcher until the context is done.
+// Failed messages are kept in the outbox and retried at the next poll.
+func (a *Outbox) Relay(ctx context.Context, dispatcher Dispatcher, options OutboxOptions) error {
+	options = options.withDefaults()
+	ticker := time.NewTicker(options.PollInterval)
+	defer ticker.Stop()
+	for {
+		// Errors are retried at the next poll and can be observed by using RelayOnce.
+		_, _ = a.RelayOnce(ctx, dispatcher, options)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce publishes up to BatchSize pending messages to the dispatcher and
+// returns the number of published messages. If a message cannot be published,
+// the remaining messages with the same key are skipped to preserve their order,
+// and the last error is returned. Skipped messages do not count towards the
+// batch, so that a failing key does not block the other keys.
+func (a *Outbox) RelayOnce(ctx context.Context, dispatcher Dispatcher, options OutboxOptions) (int, error) {
+	options = options.withDefaults()
+
+	// Use stability patterns to make publishing more robust.
+	var fn service.Function[Message, struct{}] = func(ctx context.Context, message Message) (struct{}, error) {
+		return struct{}{}, dispatcher.Publish(ctx, message)
+	}
+	fn = stability.Retry(fn, options.MaxRetries, options.RetryDelay)
+
+	// Publish the messages page by page and skip keys with failed messages.
+	var lastErr error
+	var lastID int64
+	relayed := 0
+	published := 0
+	blocked := make(map[string]bool)
+	for relayed < options.BatchSize {
+		// Read the pending messages before publishing to release the connection.
+		entries, err := a.pending(ctx, lastID, options.MaxAttempts, options.BatchSize)
+		if err != nil {
+			return published, err
+		}
+		if len(entries) == 0 {
+			break
+		}
+		for _, entry := range entries {
+			if relayed == options.BatchSize {
+				break
+			}
+			lastID = entry.id
+			if blocked[entry.key] {
+				continue
+			}
+			relayed++
+			// Keep undecodable messages as dead letters, since they never succeed.
+			if entry.err != nil {
+				lastErr = entry.err
+				if _, err := a.db.ExecContext(ctx, a.queries.dead, options.MaxAttempts, entry.id); err != nil {
+					return published, err
+				}
+				continue
+			}
+			if _, err := fn(ctx, entry.message); err != nil {
+				blocked[entry.key] = true
+				lastErr = err
+				if _, err := a.db.ExecContext(ctx, a.queries.failed, entry.id); err != nil {
+					return published, err
+				}
+				continue
+			}
+			// A crash before the delete leads to a redelivery (at least once).
+			if _, err := a.db.ExecContext(ctx, a.queries.published, entry.id); err != nil {
+				return published, err
+			}
+			published++
+		}
+	}
+
+	return published, lastErr
+}
+
+// outboxEntry is a pending message of the outbox table.
+type outboxEntry struct {
+	id      int64
+	key     string
+	message Message
+	err     error // Error of decoding the message.
+}
+
+// pending reads the oldest pending messages after the given ID.
+func (a *Outbox) pending(ctx context.Context, afterID int64, maxAttempts, limit int) ([]outboxEntry, error) {
+	rows, err := a.db.QueryContext(ctx, a.queries.pending, afterID, maxAttempts, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []outboxEntry
+	for rows.Next() {
+		var entry outboxEntry
+		var encoded string
+		if err := rows.Scan(&entry.id, &entry.key, &encoded); err != nil {
+			return nil, err
+		}
+		entry.err = json.Unmarshal([]byte(encoded), &entry.message)
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// withDefaults replaces unset options with their default values.
+func (a OutboxOptions) withDefaults() OutboxOptions {
+	if a.BatchSize <= 0 {
+		a.BatchSize = 100
+	}
+	if a.PollInterval <= 0 {
+		a.PollInterval = time.Second
+	}
+	if a.MaxRetries == 0 {
+		a.MaxRetries = 3
+	}
+	if a.RetryDelay <= 0 {
+		a.RetryDelay = 100 * time.Millisecond
+	}
+	if a.MaxAttempts <= 0 {
+		a.MaxAttempts = 10
+	}
+	return a
+}
@@ -0,0 +1,211 @@
+package messaging_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+	"github.com/andygeiss/cloud-native-utils/resource"
+	"github.com/andygeiss/cloud-native-utils/service"
+	_ "modernc.org/sqlite"
+)
+
+// openSqlite opens a SQLite database in a temporary directory and fails the test on errors.
+func openSqlite(t *testing.T, name string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func newSqliteOutbox(t *testing.T) (*messaging.Outbox, *resource.SqliteAccess[string, string], *sql.DB) {
+	t.Helper()
+	db := openSqlite(t, "outbox.sqlite")
+	ctx := context.Background()
+	outbox := messaging.NewSqliteOutbox(db)
+	if err := outbox.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	access := resource.NewSqliteAccess[string, string](db)
+	if err := access.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return outbox, access, db
+}
+
+func Test_Outbox_With_CommittedTransaction_Should_PublishMessage(t *testing.T) {
+	// Arrange
+	outbox, access, db := newSqliteOutbox(t)
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	var received []string
+	_ = dis.Subscribe(ctx, "user.created", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		received = append(received, string(msg.Data))
+		return messaging.MessageStateCompleted, nil
+	}))
+	tx, _ := db.BeginTx(ctx, nil)
+	_ = access.CreateTx(ctx, tx, "user-1", "Jane")
+	_ = outbox.Enqueue(ctx, tx, "user-1", messaging.NewMessage("user.created", []byte("user-1")))
+	_ = tx.Commit()
+
+	// Act
+	published, err := outbox.RelayOnce(ctx, dis, messaging.OutboxOptions{})
+	published2, err2 := outbox.RelayOnce(ctx, dis, messaging.OutboxOptions{})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "published must be 1", published, 1)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "published2 must be 0", published2, 0)
+	assert.That(t, "received must be correct", received, []string{"user-1"})
+	value, _ := access.Read(ctx, "user-1")
+	assert.That(t, "value must be committed", *value, "Jane")
+}
+
+func Test_Outbox_With_FailingDispatcher_Should_PreserveOrderPerKey(t *testing.T) {
+	// Arrange
+	outbox, _, db := newSqliteOutbox(t)
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	var mutex sync.Mutex
+	var received []string
+	fail := true
+	_ = dis.Subscribe(ctx, "orders", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if fail && string(msg.Data) == "a1" {
+			return messaging.MessageStateFailed, errors.New("unavailable")
+		}
+		received = append(received, string(msg.Data))
+		return messaging.MessageStateCompleted, nil
+	}))
+	tx, _ := db.BeginTx(ctx, nil)
+	_ = outbox.Enqueue(ctx, tx, "a", messaging.NewMessage("orders", []byte("a1")))
+	_ = outbox.Enqueue(ctx, tx, "b", messaging.NewMessage("orders", []byte("b1")))
+	_ = outbox.Enqueue(ctx, tx, "a", messaging.NewMessage("orders", []byte("a2")))
+	_ = tx.Commit()
+	options := messaging.OutboxOptions{MaxRetries: -1}
+
+	// Act
+	published, err := outbox.RelayOnce(ctx, dis, options)
+	fail = false
+	published2, err2 := outbox.RelayOnce(ctx, dis, options)
+
+	// Assert
+	assert.That(t, "err must not be nil", err != nil, true)
+	assert.That(t, "published must be 1", published, 1)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "published2 must be 2", published2, 2)
+	assert.That(t, "received must be ordered per key", received, []string{"b1", "a1", "a2"})
+}
+
+func Test_Outbox_With_RolledBackTransaction_Should_NotPublishMessage(t *testing.T) {
+	// Arrange
+	outbox, access, db := newSqliteOutbox(t)
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	tx, _ := db.BeginTx(ctx, nil)
+	_ = access.CreateTx(ctx, tx, "user-1", "Jane")
+	_ = outbox.Enqueue(ctx, tx, "user-1", messaging.NewMessage("user.created", []byte("user-1")))
+	_ = tx.Rollback()
+
+	// Act
+	published, err := outbox.RelayOnce(ctx, dis, messaging.OutboxOptions{})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "published must be 0", published, 0)
+	_, errRead := access.Read(ctx, "user-1")
+	assert.That(t, "value must not exist", errRead != nil, true)
+}
+
+func Test_Outbox_With_BlockedKeyFillingBatch_Should_RelayOtherKeys(t *testing.T) {
+	// Arrange
+	outbox, _, db := newSqliteOutbox(t)
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	var received []string
+	_ = dis.Subscribe(ctx, "orders", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		if string(msg.Data) == "a" {
+			return messaging.MessageStateFailed, errors.New("unavailable")
+		}
+		received = append(received, string(msg.Data))
+		return messaging.MessageStateCompleted, nil
+	}))
+	tx, _ := db.BeginTx(ctx, nil)
+	for range 3 {
+		_ = outbox.Enqueue(ctx, tx, "a", messaging.NewMessage("orders", []byte("a")))
+	}
+	_ = outbox.Enqueue(ctx, tx, "b", messaging.NewMessage("orders", []byte("b")))
+	_ = tx.Commit()
+
+	// Act
+	published, err := outbox.RelayOnce(ctx, dis, messaging.OutboxOptions{BatchSize: 2, MaxRetries: -1})
+
+	// Assert
+	assert.That(t, "err must not be nil", err != nil, true)
+	assert.That(t, "published must be 1", published, 1)
+	assert.That(t, "received must be correct", received, []string{"b"})
+}
+
+func Test_Outbox_With_UndecodableMessage_Should_KeepDeadLetter(t *testing.T) {
+	// Arrange
+	outbox, _, db := newSqliteOutbox(t)
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	var received []string
+	_ = dis.Subscribe(ctx, "orders", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		received = append(received, string(msg.Data))
+		return messaging.MessageStateCompleted, nil
+	}))
+	_, _ = db.ExecContext(ctx, "INSERT INTO outbox (message_key, message) VALUES ('a', 'invalid')")
+	tx, _ := db.BeginTx(ctx, nil)
+	_ = outbox.Enqueue(ctx, tx, "a", messaging.NewMessage("orders", []byte("a2")))
+	_ = tx.Commit()
+
+	// Act
+	published, err := outbox.RelayOnce(ctx, dis, messaging.OutboxOptions{})
+	published2, err2 := outbox.RelayOnce(ctx, dis, messaging.OutboxOptions{})
+
+	// Assert
+	assert.That(t, "err must not be nil", err != nil, true)
+	assert.That(t, "published must be 1", published, 1)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "published2 must be 0", published2, 0)
+	assert.That(t, "received must be correct", received, []string{"a2"})
+	var dead int
+	_ = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM outbox").Scan(&dead)
+	assert.That(t, "dead letter must be kept", dead, 1)
+}
+
+func Test_Outbox_With_MaxAttemptsReached_Should_StopRelaying(t *testing.T) {
+	// Arrange
+	outbox, _, db := newSqliteOutbox(t)
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	calls := 0
+	_ = dis.Subscribe(ctx, "orders", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		calls++
+		return messaging.MessageStateFailed, errors.New("unavailable")
+	}))
+	tx, _ := db.BeginTx(ctx, nil)
+	_ = outbox.Enqueue(ctx, tx, "a", messaging.NewMessage("orders", []byte("a1")))
+	_ = tx.Commit()
+	options := messaging.OutboxOptions{MaxRetries: -1, MaxAttempts: 2}
+
+	// Act
+	for range 3 {
+		_, _ = outbox.RelayOnce(ctx, dis, options)
+	}
+
+	// Assert
+	assert.That(t, "calls must be 2", calls, 2)
+}
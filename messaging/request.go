@@ -0,0 +1,169 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/security"
+	"github.com/andygeiss/cloud-native-utils/service"
+)
+
+var (
+	ErrNoReplyTo          = errors.New("message has no reply-to header")
+	ErrReplyTopicRequired = errors.New("reply topic required")
+	ErrRequestFailed      = errors.New("request failed")
+)
+
+const (
+	// HeaderReplyTo contains the topic a reply to a request is published to.
+	HeaderReplyTo = "x-reply-to"
+	// HeaderRequestID contains the ID correlating a reply with its request.
+	HeaderRequestID = "x-request-id"
+)
+
+// RequesterOptions configures a Requester.
+type RequesterOptions struct {
+	// ReplyTopic is a topic shared by all requests to receive their replies.
+	// It must be unique per service instance, since replies are routed by topic.
+	// It is required for the Kafka, AMQP and SQLite dispatchers, since their
+	// subscriptions create topics, queues or rows in the broker.
+	// Default: a generated topic for the internal and NATS dispatchers.
+	ReplyTopic string
+
+	// Timeout limits the duration of a request. Default: 5s.
+	Timeout time.Duration
+}
+
+// Requester sends requests over a dispatcher and waits for their replies.
+// Replies are correlated with their requests by using the request ID header.
+type Requester struct {
+	dispatcher Dispatcher
+	options    RequesterOptions
+	pending    map[string]chan Message
+	mutex      sync.Mutex
+}
+
+// NewRequester creates a new Requester instance.
+// The reply topic is subscribed until the context is canceled.
+// It returns ErrReplyTopicRequired if the dispatcher requires a configured reply topic.
+func NewRequester(ctx context.Context, dispatcher Dispatcher, options RequesterOptions) (*Requester, error) {
+	if options.ReplyTopic == "" {
+		switch dispatcher.(type) {
+		case *ExternalDispatcher, *AmqpDispatcher, *SqliteDispatcher:
+			return nil, ErrReplyTopicRequired
+		}
+		options.ReplyTopic = "_reply." + security.GenerateID()
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = 5 * time.Second
+	}
+	a := &Requester{
+		dispatcher: dispatcher,
+		options:    options,
+		pending:    make(map[string]chan Message),
+	}
+	if err := dispatcher.Subscribe(ctx, options.ReplyTopic, a.receive); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Request publishes the data to the topic and waits for the reply.
+// It returns ErrRequestFailed if the responder replied with an error.
+func (a *Requester) Request(ctx context.Context, topic string, data []byte) (Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.options.Timeout)
+	defer cancel()
+
+	// Register the request before publishing to avoid missing a fast reply.
+	request := InjectContext(ctx, NewMessage(topic, data))
+	replyCh := make(chan Message, 1)
+	a.mutex.Lock()
+	a.pending[request.ID] = replyCh
+	a.mutex.Unlock()
+	defer func() {
+		a.mutex.Lock()
+		delete(a.pending, request.ID)
+		a.mutex.Unlock()
+	}()
+
+	request = request.
+		WithHeader(HeaderReplyTo, a.options.ReplyTopic).
+		WithHeader(HeaderRequestID, request.ID)
+	if err := a.dispatcher.Publish(ctx, request); err != nil {
+		return Message{}, err
+	}
+
+	select {
+	case reply := <-replyCh:
+		if reason := reply.Headers[HeaderError]; reason != "" {
+			return reply, errors.Join(ErrRequestFailed, errors.New(reason))
+		}
+		return reply, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	}
+}
+
+// receive passes a reply to the waiting request. Replies of unknown requests are ignored.
+func (a *Requester) receive(_ context.Context, reply Message) (MessageState, error) {
+	a.mutex.Lock()
+	replyCh, ok := a.pending[reply.Headers[HeaderRequestID]]
+	a.mutex.Unlock()
+	if ok {
+		select {
+		case replyCh <- reply:
+		default:
+		}
+	}
+	return MessageStateCompleted, nil
+}
+
+// Request publishes the data to the topic by using a generated reply topic
+// and waits for the reply until the context is done. Since it subscribes to
+// a new reply topic per call, it returns ErrReplyTopicRequired for dispatchers
+// which require a configured one. Use a Requester for them instead.
+func Request(ctx context.Context, dispatcher Dispatcher, topic string, data []byte) (Message, error) {
+	// Stop the subscription of the reply topic after the request.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	requester, err := NewRequester(ctx, dispatcher, RequesterOptions{})
+	if err != nil {
+		return Message{}, err
+	}
+	return requester.Request(ctx, topic, data)
+}
+
+// Respond publishes the data as the reply to the given request.
+// It returns ErrNoReplyTo if the request has no reply-to header.
+func Respond(ctx context.Context, dispatcher Dispatcher, request Message, data []byte) error {
+	return respond(ctx, dispatcher, request, data, nil)
+}
+
+// Responder returns a function which handles requests by using the given function
+// and publishes its result as the reply. Errors are sent to the requester.
+func Responder(dispatcher Dispatcher, fn func(ctx context.Context, request Message) ([]byte, error)) service.Function[Message, MessageState] {
+	return func(ctx context.Context, request Message) (MessageState, error) {
+		data, fnErr := fn(ctx, request)
+		if err := respond(ctx, dispatcher, request, data, fnErr); err != nil {
+			return MessageStateFailed, err
+		}
+		return MessageStateCompleted, nil
+	}
+}
+
+// respond publishes the data or the error of a handler as the reply to the given request.
+func respond(ctx context.Context, dispatcher Dispatcher, request Message, data []byte, fnErr error) error {
+	replyTo := request.Headers[HeaderReplyTo]
+	if replyTo == "" {
+		return ErrNoReplyTo
+	}
+	reply := NewMessage(replyTo, data).
+		WithHeader(HeaderRequestID, request.Headers[HeaderRequestID]).
+		WithHeader(HeaderCausationID, request.ID)
+	if fnErr != nil {
+		reply = reply.WithHeader(HeaderError, fnErr.Error())
+	}
+	return dispatcher.Publish(ctx, reply)
+}
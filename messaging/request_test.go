@@ -0,0 +1,150 @@
+package messaging_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+)
+
+func Test_Request_With_Responder_Should_ReturnReply(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	_ = dis.Subscribe(ctx, "greet", messaging.Responder(dis, func(_ context.Context, request messaging.Message) ([]byte, error) {
+		return []byte("hello " + string(request.Data)), nil
+	}))
+
+	// Act
+	reply, err := messaging.Request(ctx, dis, "greet", []byte("world"))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "data must be correct", string(reply.Data), "hello world")
+}
+
+func Test_Request_With_FailingResponder_Should_ReturnErrRequestFailed(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	_ = dis.Subscribe(ctx, "greet", messaging.Responder(dis, func(_ context.Context, _ messaging.Message) ([]byte, error) {
+		return nil, errors.New("unknown name")
+	}))
+
+	// Act
+	_, err := messaging.Request(ctx, dis, "greet", []byte("world"))
+
+	// Assert
+	assert.That(t, "err must be correct", errors.Is(err, messaging.ErrRequestFailed), true)
+	assert.That(t, "err must contain the reason", err.Error(), "request failed\nunknown name")
+}
+
+func Test_Request_With_NoResponder_Should_ReturnDeadlineExceeded(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	requester, _ := messaging.NewRequester(ctx, dis, messaging.RequesterOptions{Timeout: 10 * time.Millisecond})
+
+	// Act
+	_, err := requester.Request(ctx, "greet", []byte("world"))
+
+	// Assert
+	assert.That(t, "err must be correct", err, context.DeadlineExceeded)
+}
+
+func Test_Requester_With_SharedReplyTopic_Should_CorrelateReplies(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dis := messaging.NewInternalDispatcher()
+	_ = dis.Subscribe(ctx, "echo", messaging.Responder(dis, func(_ context.Context, request messaging.Message) ([]byte, error) {
+		return request.Data, nil
+	}))
+	requester, err := messaging.NewRequester(ctx, dis, messaging.RequesterOptions{ReplyTopic: "replies.instance-1"})
+
+	// Act
+	first, _ := requester.Request(ctx, "echo", []byte("first"))
+	second, _ := requester.Request(ctx, "echo", []byte("second"))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "first reply must be correct", string(first.Data), "first")
+	assert.That(t, "second reply must be correct", string(second.Data), "second")
+	assert.That(t, "reply topic must be correct", first.Topic, "replies.instance-1")
+}
+
+func Test_Respond_With_MissingReplyTo_Should_ReturnErrNoReplyTo(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+
+	// Act
+	err := messaging.Respond(ctx, dis, messaging.NewMessage("greet", nil), []byte("hello"))
+
+	// Assert
+	assert.That(t, "err must be correct", err, messaging.ErrNoReplyTo)
+}
+
+func Test_Request_With_ExternalDispatcher_Should_ReturnErrReplyTopicRequired(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewExternalDispatcherWithOptions(messaging.ExternalDispatcherOptions{Brokers: []string{"localhost:1"}})
+	defer func() { _ = dis.Close() }()
+
+	// Act
+	_, err := messaging.Request(ctx, dis, "greet", []byte("world"))
+
+	// Assert
+	assert.That(t, "err must be correct", err, messaging.ErrReplyTopicRequired)
+}
+
+func Test_Requester_With_NatsDispatcher_Should_ReturnReply(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dis, err := messaging.NewNatsDispatcherWithOptions(ctx, messaging.NatsDispatcherOptions{URL: startNatsServer(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = dis.Close() }()
+	_ = dis.Subscribe(ctx, "greet", messaging.Responder(dis, func(_ context.Context, request messaging.Message) ([]byte, error) {
+		return []byte("hello " + string(request.Data)), nil
+	}))
+	requester, err := messaging.NewRequester(ctx, dis, messaging.RequesterOptions{})
+
+	// Act
+	first, err2 := requester.Request(ctx, "greet", []byte("world"))
+	second, err3 := requester.Request(ctx, "greet", []byte("again"))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "err3 must be nil", err3, nil)
+	assert.That(t, "first reply must be correct", string(first.Data), "hello world")
+	assert.That(t, "second reply must be correct", string(second.Data), "hello again")
+	assert.That(t, "reply topics must be shared", first.Topic, second.Topic)
+}
+
+func Test_Requester_With_SqliteDispatcherAndReplyTopic_Should_ReturnReply(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dis, _ := newSqliteDispatcher(t, messaging.SqliteDispatcherOptions{})
+	_ = dis.Subscribe(ctx, "greet", messaging.Responder(dis, func(_ context.Context, request messaging.Message) ([]byte, error) {
+		return []byte("hello " + string(request.Data)), nil
+	}))
+	_, errDefault := messaging.NewRequester(ctx, dis, messaging.RequesterOptions{})
+	requester, err := messaging.NewRequester(ctx, dis, messaging.RequesterOptions{ReplyTopic: "replies.instance-1"})
+
+	// Act
+	reply, err2 := requester.Request(ctx, "greet", []byte("world"))
+
+	// Assert
+	assert.That(t, "default err must be correct", errDefault, messaging.ErrReplyTopicRequired)
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "reply must be correct", string(reply.Data), "hello world")
+}
@@ -0,0 +1,196 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/security"
+)
+
+var (
+	ErrInvalidScheduledMessage  = errors.New("invalid scheduled message")
+	ErrScheduledMessageNotFound = errors.New("scheduled message not found")
+)
+
+// SchedulerOptions configures a Scheduler.
+type SchedulerOptions struct {
+	// Owner identifies the scheduler when leasing messages. Default: a generated ID.
+	Owner string
+
+	// BatchSize limits the number of due messages leased per poll. Default: 100.
+	BatchSize int
+
+	// PollInterval is the delay between two polls of the store. Default: 1s.
+	PollInterval time.Duration
+
+	// LeaseDuration is the duration leased messages are hidden from other schedulers.
+	// If a message is neither delivered nor released in time, e.g. due to a crash, it
+	// is leased again. It should exceed the duration of publishing a batch. Default: 30s.
+	LeaseDuration time.Duration
+
+	// RetryDelay is the delay before a message that could not be published is leased again. Default: 1s.
+	RetryDelay time.Duration
+
+	// OnError is called by Run with the errors of leasing, publishing and completing messages. Default: none.
+	OnError func(ctx context.Context, err error)
+}
+
+// ScheduledMessage is a message with the time it is due for delivery.
+type ScheduledMessage struct {
+	ID      string    `json:"id"`
+	Message Message   `json:"message"`
+	DueAt   time.Time `json:"due_at"`
+}
+
+// ScheduleStore persists the scheduled messages of a Scheduler.
+type ScheduleStore interface {
+	// Save stores a scheduled message. A pending message with the same ID is replaced.
+	Save(ctx context.Context, message ScheduledMessage) error
+	// Cancel deletes a pending message. It returns ErrScheduledMessageNotFound if the message does not exist.
+	Cancel(ctx context.Context, id string) error
+	// Lease hides up to limit due messages, which are not leased by others, from other owners
+	// until the lease expires and returns them ordered by their due time. Messages that cannot
+	// be decoded are no longer leased and reported as an error wrapping ErrInvalidScheduledMessage,
+	// which is returned together with the other messages.
+	Lease(ctx context.Context, owner string, now time.Time, lease time.Duration, limit int) ([]ScheduledMessage, error)
+	// Complete deletes a delivered message if it is still leased by the owner.
+	Complete(ctx context.Context, owner, id string) error
+	// Release hides a message leased by the owner until the given time, after which it can be leased again.
+	Release(ctx context.Context, owner, id string, until time.Time) error
+}
+
+// Scheduler delivers messages to a Dispatcher at their due time.
+// Scheduled messages are kept in a ScheduleStore, so that pending messages
+// survive restarts if the store is persistent. Schedulers of several replicas
+// may share a store, since each due message is leased by a single scheduler.
+//
+// Messages are delivered at least once: if a scheduler crashes after publishing
+// a message but before completing it, the message is delivered again after the
+// lease expired.
+type Scheduler struct {
+	dispatcher Dispatcher
+	options    SchedulerOptions
+	store      ScheduleStore
+}
+
+// NewScheduler creates a new Scheduler instance.
+func NewScheduler(dispatcher Dispatcher, store ScheduleStore) *Scheduler {
+	return NewSchedulerWithOptions(dispatcher, store, SchedulerOptions{})
+}
+
+// NewSchedulerWithOptions creates a new Scheduler instance with the given options.
+func NewSchedulerWithOptions(dispatcher Dispatcher, store ScheduleStore, options SchedulerOptions) *Scheduler {
+	return &Scheduler{
+		dispatcher: dispatcher,
+		options:    options.withDefaults(),
+		store:      store,
+	}
+}
+
+// Cancel cancels the delivery of a pending message.
+// It returns ErrScheduledMessageNotFound if the message does not exist or has already been delivered.
+func (a *Scheduler) Cancel(ctx context.Context, id string) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.store.Cancel(ctx, id)
+}
+
+// Run delivers due messages to the dispatcher until the context is done.
+// Failed messages are released and retried after the retry delay, and the errors are passed to OnError.
+func (a *Scheduler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(a.options.PollInterval)
+	defer ticker.Stop()
+	for {
+		// Errors are retried at the next poll.
+		if _, err := a.RunOnce(ctx); err != nil && ctx.Err() == nil && a.options.OnError != nil {
+			a.options.OnError(ctx, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce delivers a single batch of due messages to the dispatcher and returns
+// the number of delivered messages. Messages that cannot be published are released
+// until the retry delay elapsed, and the last error is returned together with the
+// errors of messages that cannot be decoded.
+func (a *Scheduler) RunOnce(ctx context.Context) (int, error) {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	// The messages that can be decoded are delivered, even if others cannot.
+	messages, leaseErr := a.store.Lease(ctx, a.options.Owner, time.Now(), a.options.LeaseDuration, a.options.BatchSize)
+	if leaseErr != nil && len(messages) == 0 {
+		return 0, leaseErr
+	}
+
+	var lastErr error
+	delivered := 0
+	for _, message := range messages {
+		if err := a.dispatcher.Publish(ctx, message.Message); err != nil {
+			lastErr = err
+			if err := a.store.Release(ctx, a.options.Owner, message.ID, time.Now().Add(a.options.RetryDelay)); err != nil {
+				return delivered, errors.Join(leaseErr, err)
+			}
+			continue
+		}
+		// A crash before the completion leads to a redelivery (at least once).
+		if err := a.store.Complete(ctx, a.options.Owner, message.ID); err != nil {
+			return delivered, errors.Join(leaseErr, err)
+		}
+		delivered++
+	}
+	return delivered, errors.Join(leaseErr, lastErr)
+}
+
+// Schedule stores the message for delivery at the due time and returns its ID,
+// which is the ID of the message or a generated one. Scheduling a message with
+// the ID of a pending message reschedules it.
+func (a *Scheduler) Schedule(ctx context.Context, message Message, dueAt time.Time) (string, error) {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	id := message.ID
+	if id == "" {
+		id = security.GenerateID()
+	}
+	if err := a.store.Save(ctx, ScheduledMessage{ID: id, Message: message, DueAt: dueAt.UTC()}); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// ScheduleAfter stores the message for delivery after the delay and returns its ID.
+func (a *Scheduler) ScheduleAfter(ctx context.Context, message Message, delay time.Duration) (string, error) {
+	return a.Schedule(ctx, message, time.Now().Add(delay))
+}
+
+// withDefaults replaces unset options with their default values.
+func (a SchedulerOptions) withDefaults() SchedulerOptions {
+	if a.Owner == "" {
+		a.Owner = security.GenerateID()
+	}
+	if a.BatchSize <= 0 {
+		a.BatchSize = 100
+	}
+	if a.PollInterval <= 0 {
+		a.PollInterval = time.Second
+	}
+	if a.LeaseDuration <= 0 {
+		a.LeaseDuration = 30 * time.Second
+	}
+	if a.RetryDelay <= 0 {
+		a.RetryDelay = time.Second
+	}
+	return a
+}
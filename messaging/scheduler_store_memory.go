@@ -0,0 +1,118 @@
+package messaging
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// InMemoryScheduleStore is a ScheduleStore that keeps the scheduled messages in memory.
+// Pending messages are lost on restart, and the store cannot be shared by replicas.
+type InMemoryScheduleStore struct {
+	entries map[string]*scheduleEntry
+	mutex   sync.Mutex
+}
+
+// scheduleEntry is a scheduled message with its lease.
+type scheduleEntry struct {
+	message     ScheduledMessage
+	owner       string
+	leasedUntil time.Time
+}
+
+// NewInMemoryScheduleStore creates a new InMemoryScheduleStore instance.
+func NewInMemoryScheduleStore() *InMemoryScheduleStore {
+	return &InMemoryScheduleStore{entries: make(map[string]*scheduleEntry)}
+}
+
+// Cancel deletes a pending message.
+func (a *InMemoryScheduleStore) Cancel(ctx context.Context, id string) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if _, ok := a.entries[id]; !ok {
+		return ErrScheduledMessageNotFound
+	}
+	delete(a.entries, id)
+	return nil
+}
+
+// Complete deletes a delivered message if it is still leased by the owner.
+func (a *InMemoryScheduleStore) Complete(ctx context.Context, owner, id string) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if entry, ok := a.entries[id]; ok && entry.owner == owner {
+		delete(a.entries, id)
+	}
+	return nil
+}
+
+// Lease hides up to limit due messages from other owners until the lease expires and returns them.
+func (a *InMemoryScheduleStore) Lease(ctx context.Context, owner string, now time.Time, lease time.Duration, limit int) ([]ScheduledMessage, error) {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var due []*scheduleEntry
+	for _, entry := range a.entries {
+		if !entry.message.DueAt.After(now) && !entry.leasedUntil.After(now) {
+			due = append(due, entry)
+		}
+	}
+	slices.SortFunc(due, func(x, y *scheduleEntry) int {
+		return x.message.DueAt.Compare(y.message.DueAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	messages := make([]ScheduledMessage, 0, len(due))
+	for _, entry := range due {
+		entry.owner = owner
+		entry.leasedUntil = now.Add(lease)
+		messages = append(messages, entry.message)
+	}
+	return messages, nil
+}
+
+// Release hides a message leased by the owner until the given time.
+func (a *InMemoryScheduleStore) Release(ctx context.Context, owner, id string, until time.Time) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if entry, ok := a.entries[id]; ok && entry.owner == owner {
+		entry.leasedUntil = until
+	}
+	return nil
+}
+
+// Save stores a scheduled message and replaces a pending message with the same ID.
+func (a *InMemoryScheduleStore) Save(ctx context.Context, message ScheduledMessage) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.entries[message.ID] = &scheduleEntry{message: message}
+	return nil
+}
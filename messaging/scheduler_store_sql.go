@@ -0,0 +1,204 @@
+package messaging
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// scheduleQueries contains the dialect specific statements of a SqlScheduleStore.
+type scheduleQueries struct {
+	create   string
+	save     string
+	cancel   string
+	lease    string
+	complete string
+	release  string
+	dead     string
+}
+
+// SqlScheduleStore is a ScheduleStore backed by a SQLite or PostgreSQL database.
+// Pending messages survive restarts, and the store can be shared by the schedulers
+// of several replicas, since leases are acquired by a single statement.
+//
+// Messages that cannot be decoded are kept in the table as dead letters, which are
+// no longer leased, until they are canceled or rescheduled.
+type SqlScheduleStore struct {
+	db      *sql.DB
+	queries scheduleQueries
+}
+
+// NewSqliteScheduleStore creates a new SqlScheduleStore for a SQLite database.
+func NewSqliteScheduleStore(db *sql.DB) *SqlScheduleStore {
+	return &SqlScheduleStore{
+		db: db,
+		queries: scheduleQueries{
+			create: `
+				CREATE TABLE IF NOT EXISTS scheduled_messages (id TEXT PRIMARY KEY, message TEXT NOT NULL, due_at INTEGER NOT NULL, owner TEXT NOT NULL DEFAULT '', leased_until INTEGER NOT NULL DEFAULT 0);
+				CREATE INDEX IF NOT EXISTS idx_scheduled_messages_due ON scheduled_messages (due_at);
+			`,
+			save:     "INSERT INTO scheduled_messages (id, message, due_at) VALUES (?, ?, ?) ON CONFLICT (id) DO UPDATE SET message = excluded.message, due_at = excluded.due_at, owner = '', leased_until = 0",
+			cancel:   "DELETE FROM scheduled_messages WHERE id = ?",
+			lease:    "UPDATE scheduled_messages SET owner = ?, leased_until = ? WHERE id IN (SELECT id FROM scheduled_messages WHERE due_at <= ? AND leased_until <= ? ORDER BY due_at, id LIMIT ?) RETURNING id, message, due_at",
+			complete: "DELETE FROM scheduled_messages WHERE id = ? AND owner = ?",
+			release:  "UPDATE scheduled_messages SET leased_until = ? WHERE id = ? AND owner = ?",
+			dead:     "UPDATE scheduled_messages SET owner = '', leased_until = ? WHERE id = ? AND owner = ?",
+		},
+	}
+}
+
+// NewPostgresScheduleStore creates a new SqlScheduleStore for a PostgreSQL database.
+func NewPostgresScheduleStore(db *sql.DB) *SqlScheduleStore {
+	return &SqlScheduleStore{
+		db: db,
+		queries: scheduleQueries{
+			create: `
+				CREATE TABLE IF NOT EXISTS scheduled_messages (id TEXT PRIMARY KEY, message TEXT NOT NULL, due_at BIGINT NOT NULL, owner TEXT NOT NULL DEFAULT '', leased_until BIGINT NOT NULL DEFAULT 0);
+				CREATE INDEX IF NOT EXISTS idx_scheduled_messages_due ON scheduled_messages (due_at);
+			`,
+			save:     "INSERT INTO scheduled_messages (id, message, due_at) VALUES ($1, $2, $3) ON CONFLICT (id) DO UPDATE SET message = excluded.message, due_at = excluded.due_at, owner = '', leased_until = 0",
+			cancel:   "DELETE FROM scheduled_messages WHERE id = $1",
+			lease:    "UPDATE scheduled_messages SET owner = $1, leased_until = $2 WHERE id IN (SELECT id FROM scheduled_messages WHERE due_at <= $3 AND leased_until <= $4 ORDER BY due_at, id LIMIT $5 FOR UPDATE SKIP LOCKED) RETURNING id, message, due_at",
+			complete: "DELETE FROM scheduled_messages WHERE id = $1 AND owner = $2",
+			release:  "UPDATE scheduled_messages SET leased_until = $1 WHERE id = $2 AND owner = $3",
+			dead:     "UPDATE scheduled_messages SET owner = '', leased_until = $1 WHERE id = $2 AND owner = $3",
+		},
+	}
+}
+
+// Cancel deletes a pending message.
+func (a *SqlScheduleStore) Cancel(ctx context.Context, id string) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	result, err := a.db.ExecContext(ctx, a.queries.cancel, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrScheduledMessageNotFound
+	}
+	return nil
+}
+
+// Complete deletes a delivered message if it is still leased by the owner.
+func (a *SqlScheduleStore) Complete(ctx context.Context, owner, id string) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := a.db.ExecContext(ctx, a.queries.complete, id, owner)
+	return err
+}
+
+// Init initializes the table. Pending messages are kept.
+func (a *SqlScheduleStore) Init(ctx context.Context) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := a.db.ExecContext(ctx, a.queries.create)
+	return err
+}
+
+// Lease hides up to limit due messages from other owners until the lease expires and returns them.
+// Messages that cannot be decoded are kept as dead letters and reported as an error
+// wrapping ErrInvalidScheduledMessage together with the other messages.
+func (a *SqlScheduleStore) Lease(ctx context.Context, owner string, now time.Time, lease time.Duration, limit int) ([]ScheduledMessage, error) {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	messages, invalid, err := a.lease(ctx, owner, now, lease, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep undecodable messages as dead letters, since they would be leased first forever.
+	var errs []error
+	for id, decodeErr := range invalid {
+		if _, err := a.db.ExecContext(ctx, a.queries.dead, int64(math.MaxInt64), id, owner); err != nil {
+			return nil, err
+		}
+		errs = append(errs, fmt.Errorf("%w: %s: %w", ErrInvalidScheduledMessage, id, decodeErr))
+	}
+
+	// The order of the returned rows is undefined.
+	slices.SortFunc(messages, func(x, y ScheduledMessage) int {
+		return x.DueAt.Compare(y.DueAt)
+	})
+	return messages, errors.Join(errs...)
+}
+
+// lease leases the due messages and returns the decoded messages and the decoding errors by ID.
+// The rows are closed before returning, so that the dead letters can be updated afterwards.
+func (a *SqlScheduleStore) lease(ctx context.Context, owner string, now time.Time, lease time.Duration, limit int) ([]ScheduledMessage, map[string]error, error) {
+	rows, err := a.db.QueryContext(ctx, a.queries.lease, owner, now.Add(lease).UnixNano(), now.UnixNano(), now.UnixNano(), limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var messages []ScheduledMessage
+	invalid := make(map[string]error)
+	for rows.Next() {
+		var message ScheduledMessage
+		var encoded string
+		var dueAt int64
+		if err := rows.Scan(&message.ID, &encoded, &dueAt); err != nil {
+			return nil, nil, err
+		}
+		if err := json.Unmarshal([]byte(encoded), &message.Message); err != nil {
+			invalid[message.ID] = err
+			continue
+		}
+		message.DueAt = time.Unix(0, dueAt).UTC()
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return messages, invalid, nil
+}
+
+// Release hides a message leased by the owner until the given time.
+func (a *SqlScheduleStore) Release(ctx context.Context, owner, id string, until time.Time) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := a.db.ExecContext(ctx, a.queries.release, until.UnixNano(), id, owner)
+	return err
+}
+
+// Save stores a scheduled message and replaces a pending message with the same ID.
+func (a *SqlScheduleStore) Save(ctx context.Context, message ScheduledMessage) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Encode the message as JSON.
+	encoded, err := json.Marshal(message.Message)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.db.ExecContext(ctx, a.queries.save, message.ID, string(encoded), message.DueAt.UnixNano())
+	return err
+}
@@ -0,0 +1,90 @@
+package messaging_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+	_ "modernc.org/sqlite"
+)
+
+func newSqliteScheduleStore(t *testing.T) (*messaging.SqlScheduleStore, *sql.DB) {
+	t.Helper()
+	db := openSqlite(t, "scheduler.sqlite")
+	store := messaging.NewSqliteScheduleStore(db)
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return store, db
+}
+
+func Test_SqlScheduleStore_With_ConcurrentOwners_Should_LeaseOnce(t *testing.T) {
+	// Arrange
+	store, _ := newSqliteScheduleStore(t)
+	ctx := context.Background()
+	now := time.Now()
+	_ = store.Save(ctx, messaging.ScheduledMessage{ID: "2", Message: messaging.NewMessage("reminder.send", nil), DueAt: now.Add(-time.Second)})
+	_ = store.Save(ctx, messaging.ScheduledMessage{ID: "1", Message: messaging.NewMessage("reminder.send", nil), DueAt: now.Add(-time.Minute)})
+
+	// Act
+	leased, err := store.Lease(ctx, "replica-1", now, time.Minute, 10)
+	leased2, err2 := store.Lease(ctx, "replica-2", now, time.Minute, 10)
+	leased3, err3 := store.Lease(ctx, "replica-2", now.Add(2*time.Minute), time.Minute, 10)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "leased length must be 2", len(leased), 2)
+	assert.That(t, "leased must be ordered by due time", leased[0].ID, "1")
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "leased2 length must be 0", len(leased2), 0)
+	assert.That(t, "err3 must be nil", err3, nil)
+	assert.That(t, "expired leases must be leased again", len(leased3), 2)
+}
+
+func Test_SqlScheduleStore_With_Restart_Should_DeliverPendingMessage(t *testing.T) {
+	// Arrange
+	store, db := newSqliteScheduleStore(t)
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	scheduler := messaging.NewScheduler(dis, store)
+	id, _ := scheduler.Schedule(ctx, messaging.NewMessage("reminder.send", []byte("hello")), time.Now().Add(-time.Second))
+
+	// Act
+	restarted := messaging.NewScheduler(dis, messaging.NewSqliteScheduleStore(db))
+	delivered, err := restarted.RunOnce(ctx)
+	err2 := restarted.Cancel(ctx, id)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "delivered must be 1", delivered, 1)
+	assert.That(t, "err2 must be ErrScheduledMessageNotFound", err2, messaging.ErrScheduledMessageNotFound)
+}
+
+func Test_SqlScheduleStore_With_UndecodableMessage_Should_DeliverOtherMessages(t *testing.T) {
+	// Arrange
+	store, db := newSqliteScheduleStore(t)
+	ctx := context.Background()
+	now := time.Now()
+	_ = store.Save(ctx, messaging.ScheduledMessage{ID: "1", Message: messaging.NewMessage("reminder.send", nil), DueAt: now.Add(-time.Minute)})
+	_ = store.Save(ctx, messaging.ScheduledMessage{ID: "2", Message: messaging.NewMessage("reminder.send", nil), DueAt: now.Add(-time.Second)})
+	if _, err := db.ExecContext(ctx, "UPDATE scheduled_messages SET message = '{' WHERE id = '1'"); err != nil {
+		t.Fatal(err)
+	}
+	scheduler := messaging.NewScheduler(messaging.NewInternalDispatcher(), store)
+
+	// Act
+	delivered, err := scheduler.RunOnce(ctx)
+	leased, err2 := store.Lease(ctx, "replica-1", now.Add(time.Hour), time.Minute, 10)
+	err3 := store.Cancel(ctx, "1")
+
+	// Assert
+	assert.That(t, "err must be ErrInvalidScheduledMessage", errors.Is(err, messaging.ErrInvalidScheduledMessage), true)
+	assert.That(t, "delivered must be 1", delivered, 1)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "undecodable message must not be leased again", len(leased), 0)
+	assert.That(t, "err3 must be nil", err3, nil)
+}
@@ -0,0 +1,116 @@
+package messaging_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+	"github.com/andygeiss/cloud-native-utils/service"
+)
+
+// failingDispatcher fails to publish messages until it is healed.
+type failingDispatcher struct {
+	messaging.Dispatcher
+	failing bool
+}
+
+func (a *failingDispatcher) Publish(ctx context.Context, message messaging.Message) error {
+	if a.failing {
+		return errors.New("unavailable")
+	}
+	return a.Dispatcher.Publish(ctx, message)
+}
+
+func Test_Scheduler_With_DueMessage_Should_DeliverOnce(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	received := make(chan string, 10)
+	_ = dis.Subscribe(ctx, "reminder.send", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		received <- string(msg.Data)
+		return messaging.MessageStateCompleted, nil
+	}))
+	scheduler := messaging.NewScheduler(dis, messaging.NewInMemoryScheduleStore())
+	_, _ = scheduler.Schedule(ctx, messaging.NewMessage("reminder.send", []byte("due")), time.Now().Add(-time.Second))
+	_, _ = scheduler.ScheduleAfter(ctx, messaging.NewMessage("reminder.send", []byte("later")), time.Hour)
+
+	// Act
+	delivered, err := scheduler.RunOnce(ctx)
+	delivered2, err2 := scheduler.RunOnce(ctx)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "delivered must be 1", delivered, 1)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "delivered2 must be 0", delivered2, 0)
+	assert.That(t, "received must be correct", <-received, "due")
+}
+
+func Test_Scheduler_With_CanceledMessage_Should_NotDeliver(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	scheduler := messaging.NewScheduler(messaging.NewInternalDispatcher(), messaging.NewInMemoryScheduleStore())
+	id, _ := scheduler.Schedule(ctx, messaging.NewMessage("reminder.send", nil), time.Now())
+
+	// Act
+	err := scheduler.Cancel(ctx, id)
+	err2 := scheduler.Cancel(ctx, id)
+	delivered, _ := scheduler.RunOnce(ctx)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be ErrScheduledMessageNotFound", err2, messaging.ErrScheduledMessageNotFound)
+	assert.That(t, "delivered must be 0", delivered, 0)
+}
+
+func Test_Scheduler_With_FailingDispatcher_Should_RetryAfterDelay(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := &failingDispatcher{Dispatcher: messaging.NewInternalDispatcher(), failing: true}
+	scheduler := messaging.NewSchedulerWithOptions(dis, messaging.NewInMemoryScheduleStore(), messaging.SchedulerOptions{RetryDelay: 20 * time.Millisecond})
+	_, _ = scheduler.Schedule(ctx, messaging.NewMessage("reminder.send", nil), time.Now())
+
+	// Act
+	delivered, err := scheduler.RunOnce(ctx)
+	dis.failing = false
+	delivered2, _ := scheduler.RunOnce(ctx)
+	time.Sleep(30 * time.Millisecond)
+	delivered3, err3 := scheduler.RunOnce(ctx)
+
+	// Assert
+	assert.That(t, "err must not be nil", err != nil, true)
+	assert.That(t, "delivered must be 0", delivered, 0)
+	assert.That(t, "delivered2 must be 0 before the retry delay", delivered2, 0)
+	assert.That(t, "err3 must be nil", err3, nil)
+	assert.That(t, "delivered3 must be 1", delivered3, 1)
+}
+
+func Test_Scheduler_With_FailingDispatcher_Should_ReportErrorsOfRun(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dis := &failingDispatcher{Dispatcher: messaging.NewInternalDispatcher(), failing: true}
+	errs := make(chan error, 10)
+	scheduler := messaging.NewSchedulerWithOptions(dis, messaging.NewInMemoryScheduleStore(), messaging.SchedulerOptions{
+		PollInterval: 10 * time.Millisecond,
+		OnError: func(ctx context.Context, err error) {
+			errs <- err
+		},
+	})
+	_, _ = scheduler.Schedule(ctx, messaging.NewMessage("reminder.send", nil), time.Now())
+
+	// Act
+	go func() { _ = scheduler.Run(ctx) }()
+	message := ""
+	select {
+	case err := <-errs:
+		message = err.Error()
+	case <-time.After(time.Second):
+	}
+
+	// Assert
+	assert.That(t, "err must be correct", message, "unavailable")
+}
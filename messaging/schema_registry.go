@@ -0,0 +1,146 @@
+package messaging
+
+import (
+	"errors"
+	"slices"
+	"sync"
+)
+
+var (
+	ErrIncompatibleSchema   = errors.New("incompatible schema")
+	ErrInvalidSchemaVersion = errors.New("invalid schema version")
+	ErrSchemaNotRegistered  = errors.New("schema not registered")
+)
+
+// Compatibility defines which schema versions of a subject a consumer accepts.
+type Compatibility int
+
+const (
+	// CompatibilityBackward accepts messages of the consumer version and older versions.
+	CompatibilityBackward Compatibility = iota
+	// CompatibilityForward accepts messages of the consumer version and newer versions.
+	CompatibilityForward
+	// CompatibilityFull accepts messages of all registered versions.
+	CompatibilityFull
+	// CompatibilityNone accepts messages of the consumer version only.
+	CompatibilityNone
+)
+
+// SchemaRegistry is an in-memory stand-in for a schema registry.
+// It stores the registered versions and content types of schema subjects,
+// and validates published and received messages against them.
+type SchemaRegistry struct {
+	subjects map[string]*schemaSubject
+	mutex    sync.RWMutex
+}
+
+// schemaSubject stores the registered versions of a subject.
+type schemaSubject struct {
+	compatibility Compatibility
+	versions      map[int]string
+}
+
+// NewSchemaRegistry creates a new SchemaRegistry instance.
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{subjects: make(map[string]*schemaSubject)}
+}
+
+// Compatible returns ErrIncompatibleSchema if a consumer of the reader version
+// must reject messages of the writer version.
+func (a *SchemaRegistry) Compatible(subject string, reader, writer int) error {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	s, ok := a.subjects[subject]
+	if !ok {
+		return ErrSchemaNotRegistered
+	}
+	if _, ok := s.versions[writer]; !ok {
+		return ErrIncompatibleSchema
+	}
+	switch s.compatibility {
+	case CompatibilityBackward:
+		if writer > reader {
+			return ErrIncompatibleSchema
+		}
+	case CompatibilityForward:
+		if writer < reader {
+			return ErrIncompatibleSchema
+		}
+	case CompatibilityFull:
+	case CompatibilityNone:
+		if writer != reader {
+			return ErrIncompatibleSchema
+		}
+	}
+	return nil
+}
+
+// Register adds a version of a subject with the content type of its payloads.
+// Registering an existing version again updates its content type.
+func (a *SchemaRegistry) Register(subject string, version int, contentType string) error {
+	if version < 1 {
+		return ErrInvalidSchemaVersion
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	s, ok := a.subjects[subject]
+	if !ok {
+		s = &schemaSubject{versions: make(map[int]string)}
+		a.subjects[subject] = s
+	}
+	s.versions[version] = contentType
+	return nil
+}
+
+// SetCompatibility sets the compatibility of a subject. Default: CompatibilityBackward.
+func (a *SchemaRegistry) SetCompatibility(subject string, compatibility Compatibility) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	s, ok := a.subjects[subject]
+	if !ok {
+		s = &schemaSubject{versions: make(map[int]string)}
+		a.subjects[subject] = s
+	}
+	s.compatibility = compatibility
+}
+
+// Validate returns an error if the version of the subject is not registered
+// or its payloads use another content type.
+func (a *SchemaRegistry) Validate(subject string, version int, contentType string) error {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	s, ok := a.subjects[subject]
+	if !ok {
+		return ErrSchemaNotRegistered
+	}
+	registered, ok := s.versions[version]
+	if !ok {
+		return ErrSchemaNotRegistered
+	}
+	if registered != contentType {
+		return ErrIncompatibleSchema
+	}
+	return nil
+}
+
+// Versions returns the registered versions of a subject in ascending order.
+func (a *SchemaRegistry) Versions(subject string) []int {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	s, ok := a.subjects[subject]
+	if !ok {
+		return nil
+	}
+	versions := make([]int, 0, len(s.versions))
+	for version := range s.versions {
+		versions = append(versions, version)
+	}
+	slices.Sort(versions)
+	return versions
+}
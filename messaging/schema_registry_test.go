@@ -0,0 +1,90 @@
+package messaging_test
+
+import (
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+)
+
+func newTestSchemaRegistry(compatibility messaging.Compatibility) *messaging.SchemaRegistry {
+	registry := messaging.NewSchemaRegistry()
+	for version := 1; version <= 3; version++ {
+		_ = registry.Register("order", version, messaging.ContentTypeJson)
+	}
+	registry.SetCompatibility("order", compatibility)
+	return registry
+}
+
+func Test_SchemaRegistry_With_Backward_Should_AcceptOlderVersions(t *testing.T) {
+	// Arrange
+	registry := newTestSchemaRegistry(messaging.CompatibilityBackward)
+
+	// Act
+	older := registry.Compatible("order", 2, 1)
+	newer := registry.Compatible("order", 2, 3)
+
+	// Assert
+	assert.That(t, "older must be nil", older, nil)
+	assert.That(t, "newer must be ErrIncompatibleSchema", newer, messaging.ErrIncompatibleSchema)
+}
+
+func Test_SchemaRegistry_With_Forward_Should_AcceptNewerVersions(t *testing.T) {
+	// Arrange
+	registry := newTestSchemaRegistry(messaging.CompatibilityForward)
+
+	// Act
+	older := registry.Compatible("order", 2, 1)
+	newer := registry.Compatible("order", 2, 3)
+
+	// Assert
+	assert.That(t, "older must be ErrIncompatibleSchema", older, messaging.ErrIncompatibleSchema)
+	assert.That(t, "newer must be nil", newer, nil)
+}
+
+func Test_SchemaRegistry_With_Full_Should_AcceptRegisteredVersions(t *testing.T) {
+	// Arrange
+	registry := newTestSchemaRegistry(messaging.CompatibilityFull)
+
+	// Act
+	older := registry.Compatible("order", 2, 1)
+	newer := registry.Compatible("order", 2, 3)
+	unknown := registry.Compatible("order", 2, 4)
+
+	// Assert
+	assert.That(t, "older must be nil", older, nil)
+	assert.That(t, "newer must be nil", newer, nil)
+	assert.That(t, "unknown must be ErrIncompatibleSchema", unknown, messaging.ErrIncompatibleSchema)
+}
+
+func Test_SchemaRegistry_With_None_Should_AcceptSameVersion(t *testing.T) {
+	// Arrange
+	registry := newTestSchemaRegistry(messaging.CompatibilityNone)
+
+	// Act
+	same := registry.Compatible("order", 2, 2)
+	older := registry.Compatible("order", 2, 1)
+
+	// Assert
+	assert.That(t, "same must be nil", same, nil)
+	assert.That(t, "older must be ErrIncompatibleSchema", older, messaging.ErrIncompatibleSchema)
+}
+
+func Test_SchemaRegistry_With_Register_Should_ValidateVersions(t *testing.T) {
+	// Arrange
+	registry := messaging.NewSchemaRegistry()
+
+	// Act
+	invalid := registry.Register("order", 0, messaging.ContentTypeJson)
+	_ = registry.Register("order", 2, messaging.ContentTypeBinary)
+	_ = registry.Register("order", 1, messaging.ContentTypeJson)
+	versions := registry.Versions("order")
+	mismatch := registry.Validate("order", 2, messaging.ContentTypeJson)
+	unknown := registry.Validate("user", 1, messaging.ContentTypeJson)
+
+	// Assert
+	assert.That(t, "invalid must be ErrInvalidSchemaVersion", invalid, messaging.ErrInvalidSchemaVersion)
+	assert.That(t, "versions must be correct", versions, []int{1, 2})
+	assert.That(t, "mismatch must be ErrIncompatibleSchema", mismatch, messaging.ErrIncompatibleSchema)
+	assert.That(t, "unknown must be ErrSchemaNotRegistered", unknown, messaging.ErrSchemaNotRegistered)
+}
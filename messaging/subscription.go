@@ -0,0 +1,83 @@
+package messaging
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Subscription represents a running subscription of a topic.
+// It can be used to observe the errors of the subscription goroutine and to stop it.
+type Subscription struct {
+	cancel   context.CancelFunc
+	done     chan struct{}
+	errorCh  chan error
+	mutex    sync.Mutex
+	finished bool
+}
+
+// newSubscription starts the given function in a goroutine, which is stopped
+// by canceling the context or by calling Stop.
+// The function reports errors without stopping by using the report function.
+func newSubscription(ctx context.Context, fn func(ctx context.Context, report func(error))) *Subscription {
+	ctx, cancel := context.WithCancel(ctx)
+	sub := &Subscription{
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		errorCh: make(chan error, 100),
+	}
+	go func() {
+		defer close(sub.done)
+		defer sub.finish()
+		fn(ctx, sub.report)
+	}()
+	return sub
+}
+
+// Done returns a channel that is closed after the subscription goroutine has stopped.
+func (a *Subscription) Done() <-chan struct{} {
+	return a.done
+}
+
+// Errors returns a read-only channel for retrieving errors of the subscription,
+// like failed reads, handler errors or uncompleted messages.
+// Errors are dropped if the channel is full. The channel is closed after the
+// subscription goroutine has stopped.
+func (a *Subscription) Errors() <-chan error {
+	return a.errorCh
+}
+
+// Stop stops the subscription and waits until its goroutine has stopped.
+func (a *Subscription) Stop() {
+	a.cancel()
+	<-a.done
+}
+
+// finish closes the error channel.
+func (a *Subscription) finish() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.finished = true
+	close(a.errorCh)
+}
+
+// report propagates an error to the caller without blocking.
+func (a *Subscription) report(err error) {
+	if err == nil {
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.finished {
+		return
+	}
+	select {
+	case a.errorCh <- err:
+	default:
+	}
+}
+
+// removeSubscription removes the subscription from the list of subscriptions.
+func removeSubscription(subs []*Subscription, sub *Subscription) []*Subscription {
+	return slices.DeleteFunc(subs, func(s *Subscription) bool { return s == sub })
+}
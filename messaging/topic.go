@@ -0,0 +1,143 @@
+package messaging
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/andygeiss/cloud-native-utils/service"
+)
+
+const (
+	// HeaderSchema contains the schema subject of the message data.
+	HeaderSchema = "x-schema"
+	// HeaderSchemaVersion contains the schema version of the message data.
+	HeaderSchemaVersion = "x-schema-version"
+)
+
+// TopicOptions configures the encoding and schema validation of a Topic.
+type TopicOptions[T any] struct {
+	// Codec encodes and decodes the payloads. Default: JsonCodec.
+	Codec Codec[T]
+
+	// Registry validates the schema versions of published and received messages. Default: none.
+	Registry *SchemaRegistry
+
+	// Schema is the schema subject of the payloads. Default: the topic name.
+	Schema string
+
+	// Version is the schema version of published payloads and of the consumer. Default: 1.
+	Version int
+}
+
+// Topic publishes and receives typed payloads on a topic of a dispatcher.
+// The payloads are encoded by a codec, and their schema subject and version
+// are stored as headers. If a schema registry is used, messages of
+// unregistered or incompatible versions are rejected.
+type Topic[T any] struct {
+	dispatcher Dispatcher
+	name       string
+	options    TopicOptions[T]
+}
+
+// NewTopic creates a new Topic instance that encodes payloads as JSON.
+func NewTopic[T any](dispatcher Dispatcher, name string) *Topic[T] {
+	return NewTopicWithOptions(dispatcher, name, TopicOptions[T]{})
+}
+
+// NewTopicWithOptions creates a new Topic instance with the given options.
+func NewTopicWithOptions[T any](dispatcher Dispatcher, name string, options TopicOptions[T]) *Topic[T] {
+	if options.Codec == nil {
+		options.Codec = NewJsonCodec[T]()
+	}
+	if options.Schema == "" {
+		options.Schema = name
+	}
+	if options.Version <= 0 {
+		options.Version = 1
+	}
+	return &Topic[T]{dispatcher: dispatcher, name: name, options: options}
+}
+
+// Decode validates the schema of the message and decodes its payload.
+// A missing content type or schema header is accepted for messages of other producers.
+func (a *Topic[T]) Decode(message Message) (T, error) {
+	var zero T
+	contentType := a.options.Codec.ContentType()
+	if ct := message.Headers[HeaderContentType]; ct != "" && ct != contentType {
+		return zero, ErrIncompatibleSchema
+	}
+	if a.options.Registry != nil {
+		if schema := message.Headers[HeaderSchema]; schema != "" && schema != a.options.Schema {
+			return zero, ErrIncompatibleSchema
+		}
+		version := a.options.Version
+		if value := message.Headers[HeaderSchemaVersion]; value != "" {
+			v, err := strconv.Atoi(value)
+			if err != nil {
+				return zero, ErrInvalidSchemaVersion
+			}
+			version = v
+		}
+		if err := a.options.Registry.Compatible(a.options.Schema, a.options.Version, version); err != nil {
+			return zero, err
+		}
+		if err := a.options.Registry.Validate(a.options.Schema, version, contentType); err != nil {
+			return zero, err
+		}
+	}
+	return a.options.Codec.Unmarshal(message.Data)
+}
+
+// Encode validates the schema and creates a message with the encoded payload.
+func (a *Topic[T]) Encode(value T) (Message, error) {
+	contentType := a.options.Codec.ContentType()
+	if a.options.Registry != nil {
+		if err := a.options.Registry.Validate(a.options.Schema, a.options.Version, contentType); err != nil {
+			return Message{}, err
+		}
+	}
+	data, err := a.options.Codec.Marshal(value)
+	if err != nil {
+		return Message{}, err
+	}
+	return NewMessage(a.name, data).
+		WithHeader(HeaderContentType, contentType).
+		WithHeader(HeaderSchema, a.options.Schema).
+		WithHeader(HeaderSchemaVersion, strconv.Itoa(a.options.Version)), nil
+}
+
+// Handler returns a function that decodes messages and calls fn with their payloads.
+// It fails messages that cannot be decoded, so that it can be wrapped by DeadLetter.
+func (a *Topic[T]) Handler(fn service.Function[T, MessageState]) service.Function[Message, MessageState] {
+	return func(ctx context.Context, message Message) (MessageState, error) {
+		value, err := a.Decode(message)
+		if err != nil {
+			return MessageStateFailed, err
+		}
+		return fn(ExtractContext(ctx, message), value)
+	}
+}
+
+// Name returns the name of the topic.
+func (a *Topic[T]) Name() string {
+	return a.name
+}
+
+// Publish encodes the value and publishes it to the topic.
+// The values of the context are propagated by using InjectContext.
+func (a *Topic[T]) Publish(ctx context.Context, value T) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	message, err := a.Encode(value)
+	if err != nil {
+		return err
+	}
+	return a.dispatcher.Publish(ctx, InjectContext(ctx, message))
+}
+
+// Subscribe calls fn with the decoded payloads of the messages published to the topic.
+func (a *Topic[T]) Subscribe(ctx context.Context, fn service.Function[T, MessageState]) error {
+	return a.dispatcher.Subscribe(ctx, a.name, a.Handler(fn))
+}
@@ -0,0 +1,95 @@
+package messaging
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrInvalidTopicPattern = errors.New("invalid topic pattern")
+)
+
+// TopicPattern matches topics by using NATS-style wildcards or a regular expression.
+// Topics are hierarchical and consist of tokens separated by dots like "orders.eu.created".
+//
+//   - "*" matches exactly one token, e.g. "orders.*" matches "orders.created".
+//   - ">" matches one or more trailing tokens, e.g. "orders.>" matches "orders.eu.created".
+//   - A pattern starting with "^" is a regular expression, e.g. "^orders\..*$".
+//
+// Other patterns match a topic exactly.
+type TopicPattern struct {
+	pattern  string
+	regexp   *regexp.Regexp
+	wildcard bool
+}
+
+// ParseTopicPattern parses the given pattern.
+// It returns ErrInvalidTopicPattern if the pattern is empty, a wildcard pattern
+// contains empty tokens or uses ">" before the last token, or if the regular
+// expression is invalid.
+func ParseTopicPattern(pattern string) (TopicPattern, error) {
+	if pattern == "" {
+		return TopicPattern{}, ErrInvalidTopicPattern
+	}
+
+	// Compile regular expressions as they are.
+	if strings.HasPrefix(pattern, "^") {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return TopicPattern{}, errors.Join(ErrInvalidTopicPattern, err)
+		}
+		return TopicPattern{pattern: pattern, regexp: re, wildcard: true}, nil
+	}
+
+	// Match topics without wildcards exactly.
+	tokens := strings.Split(pattern, ".")
+	if !slices.Contains(tokens, "*") && !slices.Contains(tokens, ">") {
+		re := regexp.MustCompile("^" + regexp.QuoteMeta(pattern) + "$")
+		return TopicPattern{pattern: pattern, regexp: re}, nil
+	}
+
+	// Translate the wildcards into an equivalent regular expression.
+	parts := make([]string, len(tokens))
+	for i, token := range tokens {
+		switch token {
+		case "":
+			return TopicPattern{}, ErrInvalidTopicPattern
+		case "*":
+			parts[i] = `[^.]+`
+		case ">":
+			if i != len(tokens)-1 {
+				return TopicPattern{}, ErrInvalidTopicPattern
+			}
+			parts[i] = `[^.]+(\.[^.]+)*`
+		default:
+			parts[i] = regexp.QuoteMeta(token)
+		}
+	}
+	re := regexp.MustCompile("^" + strings.Join(parts, `\.`) + "$")
+	return TopicPattern{pattern: pattern, regexp: re, wildcard: true}, nil
+}
+
+// IsWildcard reports whether the pattern may match other topics than itself.
+func (a TopicPattern) IsWildcard() bool {
+	return a.wildcard
+}
+
+// Match reports whether the topic matches the pattern.
+func (a TopicPattern) Match(topic string) bool {
+	if !a.wildcard {
+		return a.pattern != "" && topic == a.pattern
+	}
+	return a.regexp.MatchString(topic)
+}
+
+// Regexp returns the regular expression matching the same topics as the pattern.
+func (a TopicPattern) Regexp() *regexp.Regexp {
+	return a.regexp
+}
+
+// String returns the pattern.
+func (a TopicPattern) String() string {
+	return a.pattern
+}
@@ -0,0 +1,102 @@
+package messaging_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+)
+
+func Test_TopicPattern_With_ExactTopic_Should_MatchOnlyItself(t *testing.T) {
+	// Arrange
+	pattern, err := messaging.ParseTopicPattern("orders.created")
+
+	// Act
+	same := pattern.Match("orders.created")
+	other := pattern.Match("orders.updated")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "pattern must not be a wildcard", pattern.IsWildcard(), false)
+	assert.That(t, "same topic must match", same, true)
+	assert.That(t, "other topic must not match", other, false)
+}
+
+func Test_TopicPattern_With_SingleTokenWildcard_Should_MatchOneToken(t *testing.T) {
+	// Arrange
+	pattern, err := messaging.ParseTopicPattern("orders.*.created")
+
+	// Act
+	one := pattern.Match("orders.eu.created")
+	none := pattern.Match("orders.created")
+	two := pattern.Match("orders.eu.west.created")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "pattern must be a wildcard", pattern.IsWildcard(), true)
+	assert.That(t, "one token must match", one, true)
+	assert.That(t, "no token must not match", none, false)
+	assert.That(t, "two tokens must not match", two, false)
+}
+
+func Test_TopicPattern_With_TrailingWildcard_Should_MatchOneOrMoreTokens(t *testing.T) {
+	// Arrange
+	pattern, err := messaging.ParseTopicPattern("orders.>")
+
+	// Act
+	one := pattern.Match("orders.created")
+	two := pattern.Match("orders.eu.created")
+	none := pattern.Match("orders")
+	empty := pattern.Match("orders.")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "one token must match", one, true)
+	assert.That(t, "two tokens must match", two, true)
+	assert.That(t, "no token must not match", none, false)
+	assert.That(t, "empty token must not match", empty, false)
+}
+
+func Test_TopicPattern_With_RegularExpression_Should_MatchRegexp(t *testing.T) {
+	// Arrange
+	pattern, err := messaging.ParseTopicPattern(`^orders\.(created|updated)$`)
+
+	// Act
+	created := pattern.Match("orders.created")
+	deleted := pattern.Match("orders.deleted")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "created must match", created, true)
+	assert.That(t, "deleted must not match", deleted, false)
+}
+
+func Test_TopicPattern_With_Wildcard_Should_ReturnEquivalentRegexp(t *testing.T) {
+	// Arrange
+	pattern, _ := messaging.ParseTopicPattern("orders.*.>")
+
+	// Act
+	re := pattern.Regexp()
+
+	// Assert
+	assert.That(t, "regexp must be correct", re.String(), `^orders\.[^.]+\.[^.]+(\.[^.]+)*$`)
+	assert.That(t, "regexp must match", re.MatchString("orders.eu.created.v1"), true)
+}
+
+func Test_TopicPattern_With_InvalidPattern_Should_ReturnError(t *testing.T) {
+	// Arrange
+	patterns := []string{"", "orders.>.created", "orders..*", "^orders.(created"}
+
+	// Act
+	var errs []error
+	for _, p := range patterns {
+		_, err := messaging.ParseTopicPattern(p)
+		errs = append(errs, err)
+	}
+
+	// Assert
+	for i, err := range errs {
+		assert.That(t, "err must be correct for "+patterns[i], errors.Is(err, messaging.ErrInvalidTopicPattern), true)
+	}
+}
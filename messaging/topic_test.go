@@ -0,0 +1,175 @@
+package messaging_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+	"github.com/andygeiss/cloud-native-utils/service"
+)
+
+type testOrder struct {
+	ID     string `json:"id"`
+	Amount uint64 `json:"amount"`
+}
+
+// MarshalBinary encodes the order as amount followed by the ID.
+func (a *testOrder) MarshalBinary() ([]byte, error) {
+	return append(binary.BigEndian.AppendUint64(nil, a.Amount), a.ID...), nil
+}
+
+// UnmarshalBinary decodes the order.
+func (a *testOrder) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return errors.New("invalid order")
+	}
+	a.Amount = binary.BigEndian.Uint64(data[:8])
+	a.ID = string(data[8:])
+	return nil
+}
+
+func Test_Topic_With_JsonCodec_Should_ReceivePayload(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	topic := messaging.NewTopic[testOrder](dis, "orders.created")
+	var received testOrder
+	_ = topic.Subscribe(ctx, service.Wrap(func(order testOrder) (messaging.MessageState, error) {
+		received = order
+		return messaging.MessageStateCompleted, nil
+	}))
+
+	// Act
+	err := topic.Publish(ctx, testOrder{ID: "order-1", Amount: 42})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "received must be correct", received, testOrder{ID: "order-1", Amount: 42})
+}
+
+func Test_Topic_With_BinaryCodec_Should_ReceivePayload(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	topic := messaging.NewTopicWithOptions(dis, "orders.created", messaging.TopicOptions[testOrder]{
+		Codec: messaging.NewBinaryCodec[testOrder](),
+	})
+	var received testOrder
+	var data []byte
+	_ = dis.Subscribe(ctx, "orders.created", service.Wrap(func(msg messaging.Message) (messaging.MessageState, error) {
+		data = msg.Data
+		return messaging.MessageStateCompleted, nil
+	}))
+	_ = topic.Subscribe(ctx, service.Wrap(func(order testOrder) (messaging.MessageState, error) {
+		received = order
+		return messaging.MessageStateCompleted, nil
+	}))
+
+	// Act
+	err := topic.Publish(ctx, testOrder{ID: "order-1", Amount: 42})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "data length must be correct", len(data), 15)
+	assert.That(t, "received must be correct", received, testOrder{ID: "order-1", Amount: 42})
+}
+
+func Test_Topic_With_Encode_Should_SetSchemaHeaders(t *testing.T) {
+	// Arrange
+	topic := messaging.NewTopicWithOptions(messaging.NewInternalDispatcher(), "orders.created", messaging.TopicOptions[testOrder]{
+		Schema:  "order",
+		Version: 2,
+	})
+
+	// Act
+	msg, err := topic.Encode(testOrder{ID: "order-1"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "topic must be correct", msg.Topic, "orders.created")
+	assert.That(t, "content type must be correct", msg.Headers[messaging.HeaderContentType], messaging.ContentTypeJson)
+	assert.That(t, "schema must be correct", msg.Headers[messaging.HeaderSchema], "order")
+	assert.That(t, "schema version must be correct", msg.Headers[messaging.HeaderSchemaVersion], "2")
+}
+
+func Test_Topic_With_UnregisteredVersion_Should_RejectPublish(t *testing.T) {
+	// Arrange
+	registry := messaging.NewSchemaRegistry()
+	_ = registry.Register("orders.created", 1, messaging.ContentTypeJson)
+	topic := messaging.NewTopicWithOptions(messaging.NewInternalDispatcher(), "orders.created", messaging.TopicOptions[testOrder]{
+		Registry: registry,
+		Version:  2,
+	})
+
+	// Act
+	err := topic.Publish(context.Background(), testOrder{ID: "order-1"})
+
+	// Assert
+	assert.That(t, "err must be ErrSchemaNotRegistered", err, messaging.ErrSchemaNotRegistered)
+}
+
+func Test_Topic_With_NewerVersion_Should_RejectMessage(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	registry := messaging.NewSchemaRegistry()
+	_ = registry.Register("orders.created", 1, messaging.ContentTypeJson)
+	_ = registry.Register("orders.created", 2, messaging.ContentTypeJson)
+	consumer := messaging.NewTopicWithOptions(dis, "orders.created", messaging.TopicOptions[testOrder]{Registry: registry, Version: 1})
+	producer := messaging.NewTopicWithOptions(dis, "orders.created", messaging.TopicOptions[testOrder]{Registry: registry, Version: 2})
+	var calls int
+	_ = consumer.Subscribe(ctx, service.Wrap(func(order testOrder) (messaging.MessageState, error) {
+		calls++
+		return messaging.MessageStateCompleted, nil
+	}))
+
+	// Act
+	err := producer.Publish(ctx, testOrder{ID: "order-1"})
+
+	// Assert
+	assert.That(t, "err must be ErrIncompatibleSchema", errors.Is(err, messaging.ErrIncompatibleSchema), true)
+	assert.That(t, "calls must be 0", calls, 0)
+}
+
+func Test_Topic_With_OlderVersion_Should_AcceptMessage(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dis := messaging.NewInternalDispatcher()
+	registry := messaging.NewSchemaRegistry()
+	_ = registry.Register("orders.created", 1, messaging.ContentTypeJson)
+	_ = registry.Register("orders.created", 2, messaging.ContentTypeJson)
+	consumer := messaging.NewTopicWithOptions(dis, "orders.created", messaging.TopicOptions[testOrder]{Registry: registry, Version: 2})
+	producer := messaging.NewTopicWithOptions(dis, "orders.created", messaging.TopicOptions[testOrder]{Registry: registry, Version: 1})
+	var received testOrder
+	_ = consumer.Subscribe(ctx, service.Wrap(func(order testOrder) (messaging.MessageState, error) {
+		received = order
+		return messaging.MessageStateCompleted, nil
+	}))
+
+	// Act
+	err := producer.Publish(ctx, testOrder{ID: "order-1"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "received must be correct", received.ID, "order-1")
+}
+
+func Test_Topic_With_OtherContentType_Should_RejectMessage(t *testing.T) {
+	// Arrange
+	topic := messaging.NewTopicWithOptions(messaging.NewInternalDispatcher(), "orders.created", messaging.TopicOptions[testOrder]{
+		Codec: messaging.NewBinaryCodec[testOrder](),
+	})
+	msg := messaging.NewMessage("orders.created", []byte(`{"id":"order-1"}`)).WithHeader(messaging.HeaderContentType, messaging.ContentTypeJson)
+
+	// Act
+	state, err := topic.Handler(service.Wrap(func(order testOrder) (messaging.MessageState, error) {
+		return messaging.MessageStateCompleted, nil
+	}))(context.Background(), msg)
+
+	// Assert
+	assert.That(t, "err must be ErrIncompatibleSchema", err, messaging.ErrIncompatibleSchema)
+	assert.That(t, "state must be failed", state, messaging.MessageStateFailed)
+}
@@ -36,7 +36,7 @@ func Test_PostgresAccess_With_CreateDuplicateKey_Should_ReturnError(t *testing.T
 	err := a.Create(ctx, "key", "value")
 
 	// Assert
-	assert.That(t, "err must not be nil", err != nil, true)
+	assert.That(t, "err must be correct", err.Error(), resource.ErrorResourceAlreadyExists)
 }
 
 func Test_PostgresAccess_With_CreateValidKey_Should_Succeed(t *testing.T) {
@@ -120,7 +120,7 @@ func Test_PostgresAccess_With_ReadMissingKey_Should_ReturnError(t *testing.T) {
 	_, err := a.Read(ctx, "key2")
 
 	// Assert
-	assert.That(t, "err must not be nil", err != nil, true)
+	assert.That(t, "err must be correct", err.Error(), resource.ErrorResourceNotFound)
 }
 
 func Test_PostgresAccess_With_ReadValidKey_Should_ReturnValue(t *testing.T) {
@@ -165,3 +165,41 @@ func Test_PostgresAccess_With_UpdateValidKey_Should_UpdateValue(t *testing.T) {
 	assert.That(t, "err must be nil", err, nil)
 	assert.That(t, "value must be 'value2'", *value, "value2")
 }
+
+func Test_PostgresAccess_With_UpdateMissingKey_Should_ReturnErrorResourceNotFound(t *testing.T) {
+	// Arrange
+	dsn := getPostgresDSN()
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set, skipping PostgreSQL tests")
+	}
+	db, _ := sql.Open("pgx", dsn)
+	defer func() { _ = db.Close() }()
+	a := resource.NewPostgresAccess[string, string](db)
+	ctx := context.Background()
+	_ = a.Init(ctx)
+
+	// Act
+	err := a.Update(ctx, "key", "value")
+
+	// Assert
+	assert.That(t, "err must be correct", err.Error(), resource.ErrorResourceNotFound)
+}
+
+func Test_PostgresAccess_With_DeleteMissingKey_Should_ReturnErrorResourceNotFound(t *testing.T) {
+	// Arrange
+	dsn := getPostgresDSN()
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set, skipping PostgreSQL tests")
+	}
+	db, _ := sql.Open("pgx", dsn)
+	defer func() { _ = db.Close() }()
+	a := resource.NewPostgresAccess[string, string](db)
+	ctx := context.Background()
+	_ = a.Init(ctx)
+
+	// Act
+	err := a.Delete(ctx, "key")
+
+	// Assert
+	assert.That(t, "err must be correct", err.Error(), resource.ErrorResourceNotFound)
+}
@@ -0,0 +1,25 @@
+package resource
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// execAffected executes the statement as part of the given transaction and
+// returns an error with the given message if no row has been affected.
+// This lets the SQL stores report missing and existing keys like the other stores.
+func execAffected(ctx context.Context, tx *sql.Tx, message, query string, args ...any) error {
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New(message)
+	}
+	return nil
+}
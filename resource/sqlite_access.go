@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"sync"
 )
 
@@ -22,6 +23,15 @@ func NewSqliteAccess[K comparable, V any](db *sql.DB) *SqliteAccess[K, V] {
 
 // Create inserts a new key-value pair into the table.
 func (a *SqliteAccess[K, V]) Create(ctx context.Context, key K, value V) error {
+	return a.transact(ctx, func(tx *sql.Tx) error {
+		return a.CreateTx(ctx, tx, key, value)
+	})
+}
+
+// CreateTx inserts a new key-value pair into the table as part of the given transaction.
+// This allows callers to commit further changes, like outbox messages, atomically.
+// It returns ErrorResourceAlreadyExists if the key exists.
+func (a *SqliteAccess[K, V]) CreateTx(ctx context.Context, tx *sql.Tx, key K, value V) error {
 	// Skip if context is canceled or timed out.
 	if err := ctx.Err(); err != nil {
 		return err
@@ -38,23 +48,19 @@ func (a *SqliteAccess[K, V]) Create(ctx context.Context, key K, value V) error {
 	}
 	valueAsString := string(encoded)
 
-	// Ensure that the value is inserted atomically by using a transaction.
-	tx, err := a.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer func() { _ = tx.Rollback() }()
-
-	_, err = tx.Exec("INSERT INTO kv_store (key, value) VALUES (?, ?)", key, valueAsString)
-	if err != nil {
-		return err
-	}
-
-	return tx.Commit()
+	return execAffected(ctx, tx, ErrorResourceAlreadyExists, "INSERT INTO kv_store (key, value) VALUES (?, ?) ON CONFLICT (key) DO NOTHING", key, valueAsString)
 }
 
 // Delete removes the key-value pair associated with the given key.
 func (a *SqliteAccess[K, V]) Delete(ctx context.Context, key K) error {
+	return a.transact(ctx, func(tx *sql.Tx) error {
+		return a.DeleteTx(ctx, tx, key)
+	})
+}
+
+// DeleteTx removes the key-value pair associated with the given key as part of the given transaction.
+// It returns ErrorResourceNotFound if the key does not exist.
+func (a *SqliteAccess[K, V]) DeleteTx(ctx context.Context, tx *sql.Tx, key K) error {
 	// Skip if context is canceled or timed out.
 	if err := ctx.Err(); err != nil {
 		return err
@@ -64,19 +70,7 @@ func (a *SqliteAccess[K, V]) Delete(ctx context.Context, key K) error {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
-	// Ensure that the value is deleted atomically by using a transaction.
-	tx, err := a.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer func() { _ = tx.Rollback() }()
-
-	_, err = tx.ExecContext(ctx, "DELETE FROM kv_store WHERE key = ?", key)
-	if err != nil {
-		return err
-	}
-
-	return tx.Commit()
+	return execAffected(ctx, tx, ErrorResourceNotFound, "DELETE FROM kv_store WHERE key = ?", key)
 }
 
 // Init initializes the table and index.
@@ -112,6 +106,7 @@ func (a *SqliteAccess[K, V]) Init(ctx context.Context) error {
 }
 
 // Read returns the value associated with the given key.
+// It returns ErrorResourceNotFound if the key does not exist.
 func (a *SqliteAccess[K, V]) Read(ctx context.Context, key K) (*V, error) {
 	// Skip if context is canceled or timed out.
 	if err := ctx.Err(); err != nil {
@@ -125,6 +120,9 @@ func (a *SqliteAccess[K, V]) Read(ctx context.Context, key K) (*V, error) {
 	// Query the value from the table.
 	var valueAsString string
 	err := a.db.QueryRowContext(ctx, "SELECT value FROM kv_store WHERE key = ?", key).Scan(&valueAsString)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New(ErrorResourceNotFound)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -172,6 +170,14 @@ func (a *SqliteAccess[K, V]) ReadAll(ctx context.Context) ([]V, error) {
 
 // Update updates the value associated with the given key.
 func (a *SqliteAccess[K, V]) Update(ctx context.Context, key K, value V) error {
+	return a.transact(ctx, func(tx *sql.Tx) error {
+		return a.UpdateTx(ctx, tx, key, value)
+	})
+}
+
+// UpdateTx updates the value associated with the given key as part of the given transaction.
+// It returns ErrorResourceNotFound if the key does not exist.
+func (a *SqliteAccess[K, V]) UpdateTx(ctx context.Context, tx *sql.Tx, key K, value V) error {
 	// Skip if context is canceled or timed out.
 	if err := ctx.Err(); err != nil {
 		return err
@@ -187,15 +193,24 @@ func (a *SqliteAccess[K, V]) Update(ctx context.Context, key K, value V) error {
 		return err
 	}
 
-	// Ensure that the value is updated atomically by using a transaction.
-	tx, err := a.db.Begin()
+	return execAffected(ctx, tx, ErrorResourceNotFound, "UPDATE kv_store SET value = ? WHERE key = ?", valueAsString, key)
+}
+
+// transact runs the function within a new transaction, which is committed if the function succeeds.
+func (a *SqliteAccess[K, V]) transact(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	// Skip if context is canceled or timed out.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Ensure that the value is modified atomically by using a transaction.
+	tx, err := a.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	_, err = tx.ExecContext(ctx, "UPDATE kv_store SET value = ? WHERE key = ?", valueAsString, key)
-	if err != nil {
+	if err := fn(tx); err != nil {
 		return err
 	}
 
@@ -32,7 +32,7 @@ func Test_SqliteAccess_With_CreateDuplicateKey_Should_ReturnError(t *testing.T)
 	err := a.Create(ctx, "key", "value")
 
 	// Assert
-	assert.That(t, "err must be correct", err.Error(), "constraint failed: UNIQUE constraint failed: kv_store.key (1555)")
+	assert.That(t, "err must be correct", err.Error(), resource.ErrorResourceAlreadyExists)
 }
 
 func Test_SqliteAccess_With_CreateValidKey_Should_Succeed(t *testing.T) {
@@ -104,7 +104,7 @@ func Test_SqliteAccess_With_ReadMissingKey_Should_ReturnError(t *testing.T) {
 	_, err := a.Read(ctx, "key2")
 
 	// Assert
-	assert.That(t, "err must not be nil", err != nil, true)
+	assert.That(t, "err must be correct", err.Error(), resource.ErrorResourceNotFound)
 }
 
 func Test_SqliteAccess_With_ReadValidKey_Should_ReturnValue(t *testing.T) {
@@ -143,3 +143,57 @@ func Test_SqliteAccess_With_UpdateValidKey_Should_UpdateValue(t *testing.T) {
 	assert.That(t, "err must be nil", err, nil)
 	assert.That(t, "value must be 'value2'", *value, "value2")
 }
+
+func Test_SqliteAccess_With_RolledBackUpdateTx_Should_KeepValue(t *testing.T) {
+	// Arrange
+	path := testSqlitePath
+	db, _ := sql.Open("sqlite", path)
+	defer func() { _ = db.Close() }()
+	a := resource.NewSqliteAccess[string, string](db)
+	ctx := context.Background()
+	_ = a.Init(ctx)
+	_ = a.Create(ctx, "key", "value")
+	tx, _ := db.BeginTx(ctx, nil)
+
+	// Act
+	err := a.UpdateTx(ctx, tx, "key", "updated")
+	_ = tx.Rollback()
+	value, err2 := a.Read(ctx, "key")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "value must be unchanged", *value, "value")
+}
+
+func Test_SqliteAccess_With_UpdateMissingKey_Should_ReturnErrorResourceNotFound(t *testing.T) {
+	// Arrange
+	path := testSqlitePath
+	db, _ := sql.Open("sqlite", path)
+	defer func() { _ = db.Close() }()
+	a := resource.NewSqliteAccess[string, string](db)
+	ctx := context.Background()
+	_ = a.Init(ctx)
+
+	// Act
+	err := a.Update(ctx, "key", "value")
+
+	// Assert
+	assert.That(t, "err must be correct", err.Error(), resource.ErrorResourceNotFound)
+}
+
+func Test_SqliteAccess_With_DeleteMissingKey_Should_ReturnErrorResourceNotFound(t *testing.T) {
+	// Arrange
+	path := testSqlitePath
+	db, _ := sql.Open("sqlite", path)
+	defer func() { _ = db.Close() }()
+	a := resource.NewSqliteAccess[string, string](db)
+	ctx := context.Background()
+	_ = a.Init(ctx)
+
+	// Act
+	err := a.Delete(ctx, "key")
+
+	// Assert
+	assert.That(t, "err must be correct", err.Error(), resource.ErrorResourceNotFound)
+}
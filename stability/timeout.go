@@ -26,7 +26,8 @@ func Timeout[IN, OUT any](fn service.Function[IN, OUT], duration time.Duration)
 		defer cancel() // Ensure the timeout context is properly cleaned up to avoid resource leaks.
 
 		// Create a channel to capture the result of the function execution.
-		resCh := make(chan result)
+		// It is buffered, so that the goroutine does not block forever after a timeout.
+		resCh := make(chan result, 1)
 
 		// Run the wrapped function in a separate goroutine.
 		// This allows us to listen for both the function's result and the timeout in parallel.
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/andygeiss/cloud-native-utils/event"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+)
+
+// CloudEventsHandlerOptions configures a handler created by NewCloudEventsHandlerWithOptions.
+type CloudEventsHandlerOptions struct {
+	// MaxBytes limits the size of the request body. Default: messaging.CloudEventsMaxBytes.
+	MaxBytes int64
+}
+
+// NewCloudEventsHandler returns a handler receiving CloudEvents webhooks in structured or binary mode.
+// The data of each CloudEvent is decoded by the factory of its type and passed to the handler.
+// It responds with 202 if the handler succeeded, 400 for invalid or unknown events, 413 for
+// bodies exceeding the limit and 500 if the handler failed. OPTIONS requests of the webhook
+// validation are allowed.
+func NewCloudEventsHandler(factories map[string]event.EventFactoryFn, handler event.EventHandlerFn) http.HandlerFunc {
+	return NewCloudEventsHandlerWithOptions(factories, handler, CloudEventsHandlerOptions{})
+}
+
+// NewCloudEventsHandlerWithOptions returns a handler receiving CloudEvents webhooks with the given options.
+func NewCloudEventsHandlerWithOptions(factories map[string]event.EventFactoryFn, handler event.EventHandlerFn, options CloudEventsHandlerOptions) http.HandlerFunc {
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = messaging.CloudEventsMaxBytes
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() { _ = r.Body.Close() }()
+
+		switch r.Method {
+		case http.MethodOptions:
+			// Confirm the webhook for the requesting origin.
+			if origin := r.Header.Get("WebHook-Request-Origin"); origin != "" {
+				w.Header().Set("WebHook-Allowed-Origin", origin)
+			}
+			w.Header().Set("Allow", "OPTIONS, POST")
+			w.WriteHeader(http.StatusOK)
+			return
+		case http.MethodPost:
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Decode the CloudEvent and its data.
+		// Let the server close the connection if the body exceeds the limit.
+		r.Body = http.MaxBytesReader(w, r.Body, options.MaxBytes)
+		ce, err := messaging.ReadCloudEventRequestWithLimit(r, options.MaxBytes)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				http.Error(w, "cloud event too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "invalid cloud event", http.StatusBadRequest)
+			return
+		}
+		factory, ok := factories[ce.Type]
+		if !ok {
+			http.Error(w, "unknown event type", http.StatusBadRequest)
+			return
+		}
+		e, err := event.FromCloudEvent(ce, factory)
+		if err != nil {
+			http.Error(w, "invalid event data", http.StatusBadRequest)
+			return
+		}
+
+		// Forward the event to the handler.
+		if err := handler(e); err != nil {
+			http.Error(w, "failed to handle event", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
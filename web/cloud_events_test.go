@@ -0,0 +1,149 @@
+package web_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/cloud-native-utils/event"
+	"github.com/andygeiss/cloud-native-utils/messaging"
+	"github.com/andygeiss/cloud-native-utils/web"
+)
+
+type userCreated struct {
+	UserID string `json:"user_id"`
+}
+
+func (e userCreated) Topic() string {
+	return "user.created"
+}
+
+func newCloudEventsHandler(received *[]event.Event, handlerErr error) http.HandlerFunc {
+	factories := map[string]event.EventFactoryFn{
+		"user.created": func() event.Event { return userCreated{} },
+	}
+	return web.NewCloudEventsHandler(factories, func(e event.Event) error {
+		*received = append(*received, e)
+		return handlerErr
+	})
+}
+
+func newCloudEventRequest(t *testing.T, structured bool) *http.Request {
+	t.Helper()
+	ce, _ := event.ToCloudEvent("/users", userCreated{UserID: "user-1"})
+	req, _ := messaging.NewCloudEventRequest(context.Background(), "/events", ce, structured)
+	return req
+}
+
+func Test_CloudEventsHandler_With_StructuredMode_Should_HandleEvent(t *testing.T) {
+	// Arrange
+	var received []event.Event
+	handler := newCloudEventsHandler(&received, nil)
+	rec := httptest.NewRecorder()
+
+	// Act
+	handler(rec, newCloudEventRequest(t, true))
+
+	// Assert
+	assert.That(t, "status must be 202", rec.Code, http.StatusAccepted)
+	assert.That(t, "received must be correct", received, []event.Event{userCreated{UserID: "user-1"}})
+}
+
+func Test_CloudEventsHandler_With_BinaryMode_Should_HandleEvent(t *testing.T) {
+	// Arrange
+	var received []event.Event
+	handler := newCloudEventsHandler(&received, nil)
+	rec := httptest.NewRecorder()
+
+	// Act
+	handler(rec, newCloudEventRequest(t, false))
+
+	// Assert
+	assert.That(t, "status must be 202", rec.Code, http.StatusAccepted)
+	assert.That(t, "received must be correct", received, []event.Event{userCreated{UserID: "user-1"}})
+}
+
+func Test_CloudEventsHandler_With_UnknownType_Should_ReturnBadRequest(t *testing.T) {
+	// Arrange
+	var received []event.Event
+	handler := newCloudEventsHandler(&received, nil)
+	rec := httptest.NewRecorder()
+	body := `{"specversion":"1.0","id":"1","source":"/users","type":"user.deleted","data":{}}`
+	req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body))
+	req.Header.Set("Content-Type", messaging.ContentTypeCloudEvents)
+
+	// Act
+	handler(rec, req)
+
+	// Assert
+	assert.That(t, "status must be 400", rec.Code, http.StatusBadRequest)
+	assert.That(t, "received must be empty", len(received), 0)
+}
+
+func Test_CloudEventsHandler_With_MissingAttributes_Should_ReturnBadRequest(t *testing.T) {
+	// Arrange
+	var received []event.Event
+	handler := newCloudEventsHandler(&received, nil)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"user_id":"user-1"}`))
+	req.Header.Set("Content-Type", messaging.ContentTypeJson)
+
+	// Act
+	handler(rec, req)
+
+	// Assert
+	assert.That(t, "status must be 400", rec.Code, http.StatusBadRequest)
+}
+
+func Test_CloudEventsHandler_With_LargeBody_Should_ReturnRequestEntityTooLarge(t *testing.T) {
+	// Arrange
+	var received []event.Event
+	factories := map[string]event.EventFactoryFn{
+		"user.created": func() event.Event { return userCreated{} },
+	}
+	handler := web.NewCloudEventsHandlerWithOptions(factories, func(e event.Event) error {
+		received = append(received, e)
+		return nil
+	}, web.CloudEventsHandlerOptions{MaxBytes: 16})
+	rec := httptest.NewRecorder()
+
+	// Act
+	handler(rec, newCloudEventRequest(t, true))
+
+	// Assert
+	assert.That(t, "status must be 413", rec.Code, http.StatusRequestEntityTooLarge)
+	assert.That(t, "received must be empty", len(received), 0)
+}
+
+func Test_CloudEventsHandler_With_FailingHandler_Should_ReturnInternalServerError(t *testing.T) {
+	// Arrange
+	var received []event.Event
+	handler := newCloudEventsHandler(&received, errors.New("error"))
+	rec := httptest.NewRecorder()
+
+	// Act
+	handler(rec, newCloudEventRequest(t, true))
+
+	// Assert
+	assert.That(t, "status must be 500", rec.Code, http.StatusInternalServerError)
+}
+
+func Test_CloudEventsHandler_With_Options_Should_AllowOrigin(t *testing.T) {
+	// Arrange
+	var received []event.Event
+	handler := newCloudEventsHandler(&received, nil)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodOptions, "/events", nil)
+	req.Header.Set("WebHook-Request-Origin", "events.example.com")
+
+	// Act
+	handler(rec, req)
+
+	// Assert
+	assert.That(t, "status must be 200", rec.Code, http.StatusOK)
+	assert.That(t, "allowed origin must be correct", rec.Header().Get("WebHook-Allowed-Origin"), "events.example.com")
+}